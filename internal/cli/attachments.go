@@ -0,0 +1,140 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/danewalton/imessage-cli/internal/database"
+)
+
+// attachmentsOptions are the flags of the attachments command.
+type attachmentsOptions struct {
+	// Out is the directory attachments are copied into; "" only lists them
+	Out string
+}
+
+func cmdAttachments(conversation string, opts attachmentsOptions) {
+	identifier, err := resolveChatIdentifier(conversation)
+	if err != nil {
+		printError(err)
+		os.Exit(1)
+	}
+
+	attachments, err := database.GetChatAttachments(identifier)
+	if err != nil {
+		printError(err)
+		os.Exit(1)
+	}
+	if len(attachments) == 0 {
+		fmt.Println("No attachments in that conversation.")
+		return
+	}
+
+	if opts.Out == "" {
+		printAttachmentList(attachments)
+		return
+	}
+	saveAttachments(attachments, opts.Out)
+}
+
+// printAttachmentList shows each attachment's size, type and path, marking
+// files that are no longer on disk.
+func printAttachmentList(attachments []database.Attachment) {
+	printDecoration(colored(fmt.Sprintf("\n%-10s %-24s %s", "Size", "Type", "File"), colorBold))
+	printDecoration(strings.Repeat("-", 70))
+	for _, att := range attachments {
+		path := att.FilePath
+		if _, err := os.Stat(path); err != nil {
+			path += colored(" (missing)", colorDim)
+		}
+		fmt.Printf("%-10s %-24s %s\n", formatSize(att.TotalBytes), truncate(att.MIMEType, 24), path)
+	}
+	printDecoration(fmt.Sprintf("\n%d attachment(s). Use --out <dir> to save them.", len(attachments)))
+}
+
+// saveAttachments copies the attachment files into dir, creating it if
+// needed. Files that are missing (e.g. offloaded to iCloud) are skipped with
+// a warning, and existing files in dir are never overwritten.
+func saveAttachments(attachments []database.Attachment, dir string) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		printError(err)
+		os.Exit(1)
+	}
+
+	used := make(map[string]bool)
+	copied, skipped := 0, 0
+	for _, att := range attachments {
+		if _, err := os.Stat(att.FilePath); err != nil {
+			fmt.Fprintln(os.Stderr, colored(fmt.Sprintf("⚠ Skipping %s: file not found (%s)", att.Filename, att.FilePath), colorYellow))
+			skipped++
+			continue
+		}
+		dest := uniqueFilePath(dir, att.Filename, used)
+		if err := copyFile(att.FilePath, dest); err != nil {
+			fmt.Fprintln(os.Stderr, colored(fmt.Sprintf("⚠ Skipping %s: %v", att.Filename, err), colorYellow))
+			skipped++
+			continue
+		}
+		copied++
+	}
+
+	summary := fmt.Sprintf("✓ Saved %d attachment(s) to %s", copied, dir)
+	if skipped > 0 {
+		summary += fmt.Sprintf(" (%d skipped)", skipped)
+	}
+	fmt.Println(colored(summary, colorGreen))
+}
+
+// uniqueFilePath returns a path for name in dir that neither exists nor is
+// in used, adding " (2)", " (3)", ... before the extension as needed, and
+// records it in used.
+func uniqueFilePath(dir, name string, used map[string]bool) string {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	path := filepath.Join(dir, name)
+	for n := 2; ; n++ {
+		if _, err := os.Stat(path); os.IsNotExist(err) && !used[path] {
+			break
+		}
+		path = filepath.Join(dir, fmt.Sprintf("%s (%d)%s", base, n, ext))
+	}
+	used[path] = true
+	return path
+}
+
+// copyFile copies src to a new file dst.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	return out.Close()
+}
+
+// formatSize formats a byte count for display, e.g. "2.4 MB".
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
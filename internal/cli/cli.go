@@ -3,16 +3,33 @@ package cli
 
 import (
 	"bufio"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"os"
+	"os/signal"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
+	"github.com/danewalton/imessage-cli/internal/config"
 	"github.com/danewalton/imessage-cli/internal/database"
+	"github.com/danewalton/imessage-cli/internal/outbox"
 	"github.com/danewalton/imessage-cli/internal/sender"
+	"github.com/danewalton/imessage-cli/internal/timefmt"
 	"github.com/danewalton/imessage-cli/internal/tui"
+	"github.com/danewalton/imessage-cli/internal/watcher"
+	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 )
 
 const version = "0.1.0"
@@ -29,13 +46,47 @@ const (
 	colorCyan   = "\033[96m"
 )
 
+// absoluteTimes is the --absolute setting: show full timestamps instead of
+// relative ones.
+var absoluteTimes bool
+
+// colorMode is the --color setting: "auto", "always" or "never".
+var colorMode = "auto"
+
+// quiet is the --quiet setting: headers, separators, tips and summaries are
+// left out so only the data is printed.
+var quiet bool
+
+// printDecoration prints a line that only dresses up the output (a header,
+// separator, tip or summary) unless --quiet is set.
+func printDecoration(line string) {
+	if !quiet {
+		fmt.Println(line)
+	}
+}
+
 func colored(text string, colors ...string) string {
-	if !isTerminal() {
+	if !useColor() {
 		return text
 	}
 	return strings.Join(colors, "") + text + colorReset
 }
 
+// useColor reports whether output should be colored. Flags win over the
+// NO_COLOR convention, which wins over TTY detection.
+func useColor() bool {
+	switch colorMode {
+	case "always":
+		return true
+	case "never":
+		return false
+	}
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	return isTerminal()
+}
+
 func isTerminal() bool {
 	fileInfo, _ := os.Stdout.Stat()
 	return (fileInfo.Mode() & os.ModeCharDevice) != 0
@@ -45,18 +96,10 @@ func formatDate(t *time.Time) string {
 	if t == nil {
 		return "Unknown"
 	}
-
-	now := time.Now()
-	diff := now.Sub(*t)
-
-	if diff.Hours() < 24 {
-		return t.Format("03:04 PM")
-	} else if diff.Hours() < 48 {
-		return "Yesterday " + t.Format("03:04 PM")
-	} else if diff.Hours() < 168 { // 7 days
-		return t.Format("Monday 03:04 PM")
+	if absoluteTimes {
+		return timefmt.FormatAbsoluteTime(t)
 	}
-	return t.Format("2006-01-02 03:04 PM")
+	return timefmt.FormatRelativeTime(t, time.Now())
 }
 
 func truncate(text string, maxLen int) string {
@@ -65,10 +108,16 @@ func truncate(text string, maxLen int) string {
 	}
 	text = strings.ReplaceAll(text, "\n", " ")
 	text = strings.TrimSpace(text)
-	if len(text) <= maxLen {
+	if runewidth.StringWidth(text) <= maxLen {
 		return text
 	}
-	return text[:maxLen-3] + "..."
+	return runewidth.Truncate(text, maxLen, "...")
+}
+
+// padRight pads text with spaces to the given display width. Unlike "%-*s",
+// it counts wide characters such as emoji and CJK as two columns.
+func padRight(text string, width int) string {
+	return runewidth.FillRight(text, width)
 }
 
 var rootCmd = &cobra.Command{
@@ -86,8 +135,48 @@ Examples:
   imessage search "meeting"        Search for messages containing "meeting"
 
 Note: This tool requires macOS with Messages configured and proper permissions.`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		mode, _ := cmd.Flags().GetString("color")
+		switch mode {
+		case "auto", "always", "never":
+		default:
+			return fmt.Errorf("invalid --color value %q (want auto, always or never)", mode)
+		}
+		if noColor, _ := cmd.Flags().GetBool("no-color"); noColor {
+			mode = "never"
+		}
+		colorMode = mode
+		quiet, _ = cmd.Flags().GetBool("quiet")
+		absoluteTimes, _ = cmd.Flags().GetBool("absolute")
+		busyTimeout, _ := cmd.Flags().GetDuration("busy-timeout")
+		if busyTimeout < 0 {
+			return fmt.Errorf("invalid --busy-timeout %s", busyTimeout)
+		}
+		database.SetBusyTimeout(busyTimeout)
+		if dbPath, _ := cmd.Flags().GetString("db"); dbPath != "" {
+			database.SetDBPath(dbPath)
+		}
+		clock24, _ := cmd.Flags().GetBool("24h")
+		clock12, _ := cmd.Flags().GetBool("12h")
+		switch {
+		case clock24:
+			timefmt.SetClock24(true)
+		case clock12:
+			timefmt.SetClock24(false)
+		}
+		tui.SetAbsoluteTimes(absoluteTimes)
+		if err := setDefaultCountry(cmd); err != nil {
+			return err
+		}
+		if verbose, _ := cmd.Flags().GetBool("verbose"); verbose {
+			database.SetLogger(log.New(os.Stderr, "database: ", log.Ltime|log.Lmicroseconds))
+			database.SetLogQueries(true)
+			sender.SetLogger(log.New(os.Stderr, "sender: ", log.Ltime|log.Lmicroseconds))
+		}
+		return nil
+	},
 	Run: func(cmd *cobra.Command, args []string) {
-		cmdList(20)
+		cmdList(listOptions{Limit: 20, Sort: "recent"})
 	},
 }
 
@@ -97,39 +186,268 @@ var listCmd = &cobra.Command{
 	Short:   "List recent conversations",
 	Run: func(cmd *cobra.Command, args []string) {
 		limit, _ := cmd.Flags().GetInt("limit")
-		cmdList(limit)
+		showIDs, _ := cmd.Flags().GetBool("show-identifiers")
+		hideMuted, _ := cmd.Flags().GetBool("hide-muted")
+		sortBy, _ := cmd.Flags().GetString("sort")
+		includeArchived, _ := cmd.Flags().GetBool("include-archived")
+		days, _ := cmd.Flags().GetInt("days")
+		unreadOnly, _ := cmd.Flags().GetBool("unread")
+		if !slices.Contains(listSortOrders, sortBy) {
+			fmt.Println(colored(fmt.Sprintf("Error: invalid --sort %q (want %s)", sortBy, strings.Join(listSortOrders, ", ")), colorRed))
+			os.Exit(1)
+		}
+		if days < 0 {
+			fmt.Println(colored("Error: --days must be positive", colorRed))
+			os.Exit(1)
+		}
+		cmdList(listOptions{
+			Limit:           limit,
+			ShowIdentifiers: showIDs,
+			HideMuted:       hideMuted,
+			Sort:            sortBy,
+			IncludeArchived: includeArchived,
+			Days:            days,
+			UnreadOnly:      unreadOnly,
+		})
 	},
 }
 
 var readCmd = &cobra.Command{
-	Use:     "read <conversation>",
-	Aliases: []string{"r", "view"},
-	Short:   "Read messages from a conversation",
-	Args:    cobra.ExactArgs(1),
+	Use:               "read [conversation]",
+	Aliases:           []string{"r", "view"},
+	Short:             "Read messages from a conversation",
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeConversations,
 	Run: func(cmd *cobra.Command, args []string) {
 		limit, _ := cmd.Flags().GetInt("limit")
-		cmdRead(args[0], limit)
+		follow, _ := cmd.Flags().GetBool("follow")
+		showIDs, _ := cmd.Flags().GetBool("show-identifiers")
+		conversation, ok := conversationArg(cmd, args)
+		if !ok {
+			return
+		}
+		beforeID, afterID := cursorFlags(cmd)
+		raw, _ := cmd.Flags().GetBool("raw")
+		cmdRead(conversation, readOptions{Limit: limit, Follow: follow, ShowIdentifiers: showIDs, Direction: directionFlag(cmd), BeforeID: beforeID, AfterID: afterID, Raw: raw})
 	},
 }
 
 var sendCmd = &cobra.Command{
-	Use:     "send <recipient> <message>",
-	Aliases: []string{"s"},
-	Short:   "Send a message",
-	Args:    cobra.ExactArgs(2),
+	Use:               "send <recipient>[,<recipient>...] <message>",
+	Aliases:           []string{"s"},
+	Short:             "Send a message",
+	Long:              "Send a message to one or more recipients, given as a comma-separated list or with repeated --to flags.",
+	Args:              sendArgs,
+	ValidArgsFunction: completeRecipients,
+	Run: func(cmd *cobra.Command, args []string) {
+		yes, _ := cmd.Flags().GetBool("yes")
+		to, _ := cmd.Flags().GetStringArray("to")
+		message := args[len(args)-1]
+		if len(args) == 2 {
+			to = append(to, args[0])
+		}
+		at, _ := cmd.Flags().GetString("at")
+		var sendAt time.Time
+		if at != "" {
+			t, err := parseSendTime(at, time.Now())
+			if err != nil {
+				printError(err)
+				os.Exit(1)
+			}
+			sendAt = t
+		}
+		delay, _ := cmd.Flags().GetDuration("delay")
+		from, _ := cmd.Flags().GetString("from")
+		cmdSend(splitRecipients(to), message, sendOptions{SkipConfirm: yes, At: sendAt, Delay: delay, From: from})
+	},
+}
+
+// sendArgs accepts "<recipients> <message>", or just "<message>" when
+// recipients are given with --to.
+func sendArgs(cmd *cobra.Command, args []string) error {
+	to, _ := cmd.Flags().GetStringArray("to")
+	if len(to) > 0 {
+		return cobra.RangeArgs(1, 2)(cmd, args)
+	}
+	return cobra.ExactArgs(2)(cmd, args)
+}
+
+// sendTimeLayouts are the formats accepted by send --at, in local time.
+var sendTimeLayouts = []string{
+	"2006-01-02 15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02T15:04:05",
+}
+
+// parseSendTime parses a send --at value. Besides full dates it accepts a
+// bare "15:04", meaning that time today, and RFC 3339 timestamps. The result
+// must be after now.
+func parseSendTime(value string, now time.Time) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		for _, layout := range sendTimeLayouts {
+			if t, err = time.ParseInLocation(layout, value, time.Local); err == nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		clock, clockErr := time.ParseInLocation("15:04", value, time.Local)
+		if clockErr != nil {
+			return time.Time{}, fmt.Errorf("invalid time %q (use \"YYYY-MM-DD HH:MM\" or \"HH:MM\")", value)
+		}
+		t = time.Date(now.Year(), now.Month(), now.Day(), clock.Hour(), clock.Minute(), 0, 0, time.Local)
+	}
+
+	if !t.After(now) {
+		return time.Time{}, fmt.Errorf("scheduled time %s is in the past", t.Format("2006-01-02 15:04"))
+	}
+	return t, nil
+}
+
+// waitUntil blocks until t, returning false if interrupted first.
+func waitUntil(t time.Time) bool {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(sigCh)
+
+	timer := time.NewTimer(time.Until(t))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-sigCh:
+		return false
+	}
+}
+
+// splitRecipients splits comma-separated recipient lists, dropping blanks
+// and duplicates.
+func splitRecipients(values []string) []string {
+	seen := make(map[string]bool)
+	var recipients []string
+	for _, v := range values {
+		for _, r := range strings.Split(v, ",") {
+			r = strings.TrimSpace(r)
+			if r == "" || seen[r] {
+				continue
+			}
+			seen[r] = true
+			recipients = append(recipients, r)
+		}
+	}
+	return recipients
+}
+
+var replyCmd = &cobra.Command{
+	Use:   "reply <message>",
+	Short: "Reply to the most recently active conversation",
+	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		yes, _ := cmd.Flags().GetBool("yes")
-		cmdSend(args[0], args[1], yes)
+		cmdReply(args[0], yes)
 	},
 }
 
 var chatCmd = &cobra.Command{
-	Use:     "chat <contact>",
-	Aliases: []string{"c"},
-	Short:   "Interactive chat mode",
-	Args:    cobra.ExactArgs(1),
+	Use:               "chat [contact]",
+	Aliases:           []string{"c"},
+	Short:             "Interactive chat mode",
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeConversations,
+	Run: func(cmd *cobra.Command, args []string) {
+		conversation, ok := conversationArg(cmd, args)
+		if !ok {
+			return
+		}
+		limit, _ := cmd.Flags().GetInt("limit")
+		live, _ := cmd.Flags().GetBool("live")
+		cmdChat(conversation, chatOptions{Limit: limit, Live: live})
+	},
+}
+
+var openCmd = &cobra.Command{
+	Use:               "open [conversation]",
+	Short:             "Open a conversation in Messages.app",
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeConversations,
+	Run: func(cmd *cobra.Command, args []string) {
+		conversation, ok := conversationArg(cmd, args)
+		if !ok {
+			return
+		}
+		cmdOpen(conversation)
+	},
+}
+
+var exportCmd = &cobra.Command{
+	Use:               "export [conversation]",
+	Short:             "Export a conversation as text or an HTML page with chat bubbles",
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeConversations,
+	Run: func(cmd *cobra.Command, args []string) {
+		format, _ := cmd.Flags().GetString("format")
+		output, _ := cmd.Flags().GetString("output")
+		limit, _ := cmd.Flags().GetInt("limit")
+		if !slices.Contains(exportFormats, format) {
+			fmt.Println(colored(fmt.Sprintf("Error: invalid --format %q (want %s)", format, strings.Join(exportFormats, " or ")), colorRed))
+			os.Exit(1)
+		}
+		conversation, ok := conversationArg(cmd, args)
+		if !ok {
+			return
+		}
+		beforeID, afterID := cursorFlags(cmd)
+		cmdExport(conversation, exportOptions{Format: format, Output: output, Limit: limit, Location: timezoneFlag(cmd), BeforeID: beforeID, AfterID: afterID})
+	},
+}
+
+var attachmentsCmd = &cobra.Command{
+	Use:               "attachments [conversation]",
+	Aliases:           []string{"files"},
+	Short:             "List a conversation's attachments or save them to a directory",
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeConversations,
+	Run: func(cmd *cobra.Command, args []string) {
+		out, _ := cmd.Flags().GetString("out")
+		conversation, ok := conversationArg(cmd, args)
+		if !ok {
+			return
+		}
+		cmdAttachments(conversation, attachmentsOptions{Out: out})
+	},
+}
+
+var reactCmd = &cobra.Command{
+	Use:               "react <conversation> <reaction>",
+	Short:             "React to the last message in a conversation (love, like, dislike, laugh, emphasize, question)",
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: completeConversations,
+	Run: func(cmd *cobra.Command, args []string) {
+		yes, _ := cmd.Flags().GetBool("yes")
+		cmdReact(args[0], args[1], yes)
+	},
+}
+
+var muteCmd = &cobra.Command{
+	Use:               "mute <conversation>",
+	Short:             "Mute a conversation (hide with list --hide-muted, no TUI notifications)",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeConversations,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmdMute(args[0], true)
+	},
+}
+
+var unmuteCmd = &cobra.Command{
+	Use:               "unmute <conversation>",
+	Short:             "Unmute a conversation",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeConversations,
 	Run: func(cmd *cobra.Command, args []string) {
-		cmdChat(args[0])
+		cmdMute(args[0], false)
 	},
 }
 
@@ -140,7 +458,111 @@ var searchCmd = &cobra.Command{
 	Args:    cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		limit, _ := cmd.Flags().GetInt("limit")
-		cmdSearch(args[0], limit)
+		caseSensitive, _ := cmd.Flags().GetBool("case-sensitive")
+		word, _ := cmd.Flags().GetBool("word")
+		jsonOut, _ := cmd.Flags().GetBool("json")
+		csvOut, _ := cmd.Flags().GetBool("csv")
+		count, _ := cmd.Flags().GetBool("count")
+		attachments, _ := cmd.Flags().GetBool("attachments")
+		contextLines, _ := cmd.Flags().GetInt("context")
+		groupByChat, _ := cmd.Flags().GetBool("group-by-chat")
+		filter := database.SearchOptions{IgnoreCase: !caseSensitive, WholeWord: word, Attachments: attachments, Direction: directionFlag(cmd)}
+		if count {
+			cmdSearchCount(args[0], filter)
+			return
+		}
+		cmdSearch(args[0], filter, searchOptions{
+			Limit:       limit,
+			JSON:        jsonOut,
+			CSV:         csvOut,
+			Context:     contextLines,
+			GroupByChat: groupByChat,
+			Location:    timezoneFlag(cmd),
+		})
+	},
+}
+
+var recentCmd = &cobra.Command{
+	Use:   "recent",
+	Short: "Show the latest messages across all conversations",
+	Long: `Show the latest messages across all conversations as one feed, newest
+first, one line each: time, conversation and a snippet of the text. Unlike
+'list', which shows one line per conversation, every message gets a line.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		limit, _ := cmd.Flags().GetInt("limit")
+		cmdRecent(limit)
+	},
+}
+
+var deletedCmd = &cobra.Command{
+	Use:   "deleted",
+	Short: "List messages in Recently Deleted",
+	Long: `List the messages in Messages' Recently Deleted (macOS 13 and later),
+most recently deleted first, to review them before Messages purges them
+after about 30 days. Recover them in Messages under View > Recently Deleted.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		limit, _ := cmd.Flags().GetInt("limit")
+		cmdDeleted(limit)
+	},
+}
+
+var showCmd = &cobra.Command{
+	Use:   "show <message-id|guid>",
+	Short: "Show every field of a single message",
+	Long: `Show every field of a single message, looked up by its ROWID or GUID.
+
+Use --raw to also dump the attributedBody blob the text is extracted from,
+which helps diagnose messages whose text comes out wrong.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		raw, _ := cmd.Flags().GetBool("raw")
+		cmdShow(args[0], raw)
+	},
+}
+
+var outboxCmd = &cobra.Command{
+	Use:   "outbox",
+	Short: "List messages that failed to send",
+	Long: `Messages that fail to send are saved to ~/.imessage-outbox.json so they
+aren't lost. List them with 'outbox', retry them with 'outbox flush' and
+drop them with 'outbox clear'.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmdOutbox()
+	},
+}
+
+var outboxFlushCmd = &cobra.Command{
+	Use:   "flush",
+	Short: "Try to send the queued messages again",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		delay, _ := cmd.Flags().GetDuration("delay")
+		cmdOutboxFlush(delay)
+	},
+}
+
+var outboxClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Drop the queued messages without sending them",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmdOutboxClear()
+	},
+}
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check everything reading and sending needs, without sending",
+	Long: `Check database access, contacts, the Messages app, the permission to
+control it and the accounts signed in, and print how to fix each problem
+found. Nothing is sent; talking to Messages starts it if it isn't running.
+Exits with status 1 if a required check fails.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmdDoctor()
 	},
 }
 
@@ -152,6 +574,33 @@ var statusCmd = &cobra.Command{
 	},
 }
 
+var accountsCmd = &cobra.Command{
+	Use:     "accounts",
+	Aliases: []string{"whoami"},
+	Short:   "List the accounts configured in Messages",
+	Run: func(cmd *cobra.Command, args []string) {
+		cmdAccounts()
+	},
+}
+
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show message analytics",
+	Run: func(cmd *cobra.Command, args []string) {
+		jsonOut, _ := cmd.Flags().GetBool("json")
+		cmdStats(jsonOut)
+	},
+}
+
+var previewCmd = &cobra.Command{
+	Use:   "preview <path>",
+	Short: "Render an image in the terminal",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cmdPreview(args[0])
+	},
+}
+
 var tuiCmd = &cobra.Command{
 	Use:     "tui",
 	Aliases: []string{"ui", "watch"},
@@ -159,6 +608,23 @@ var tuiCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		// Read debug flag from the command's flags to avoid init-time cycles
 		debug, _ := cmd.Flags().GetBool("debug")
+		// Logging to stderr would draw over the TUI, so --verbose goes to
+		// the debug log instead
+		if verbose, _ := cmd.Flags().GetBool("verbose"); verbose {
+			debug = true
+		}
+		lockFile, _ := cmd.Flags().GetString("lock-file")
+		tui.SetLockPath(lockFile)
+		colors, err := tuiColors(cmd)
+		if err != nil {
+			printError(err)
+			os.Exit(1)
+		}
+		tui.SetColors(colors)
+		contactRefresh, _ := cmd.Flags().GetDuration("contacts-refresh")
+		tui.SetContactRefresh(contactRefresh)
+		showIDs, _ := cmd.Flags().GetBool("show-identifiers")
+		database.SetShowSenderIdentifiers(showIDs)
 		if debug {
 			if err := tui.RunWithDebug(true, ""); err != nil {
 				fmt.Println(colored(fmt.Sprintf("Error launching TUI: %v", err), colorRed))
@@ -174,6 +640,21 @@ var tuiCmd = &cobra.Command{
 	},
 }
 
+var completionCmd = &cobra.Command{
+	Use:   "completion [bash|zsh|fish]",
+	Short: "Generate a shell completion script",
+	Long: `Generate a shell completion script.
+
+  bash:  source <(imessage completion bash)
+  zsh:   imessage completion zsh > "${fpath[1]}/_imessage"
+  fish:  imessage completion fish > ~/.config/fish/completions/imessage.fish`,
+	ValidArgs: []string{"bash", "zsh", "fish"},
+	Args:      cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+	Run: func(cmd *cobra.Command, args []string) {
+		cmdCompletion(cmd.Root(), args[0])
+	},
+}
+
 var versionCmd = &cobra.Command{
 	Use:   "version",
 	Short: "Print version information",
@@ -183,20 +664,112 @@ var versionCmd = &cobra.Command{
 }
 
 func init() {
-	listCmd.Flags().IntP("limit", "n", 20, "Number of conversations to show")
-	readCmd.Flags().IntP("limit", "n", 30, "Number of messages to show")
+	rootCmd.PersistentFlags().String("color", "auto", "When to color output: auto, always or never")
+	rootCmd.PersistentFlags().Bool("no-color", false, "Disable colored output (same as --color=never)")
+	rootCmd.PersistentFlags().Bool("24h", false, "Show times on a 24-hour clock (15:04)")
+	rootCmd.PersistentFlags().Bool("12h", false, "Show times on a 12-hour clock (03:04 PM, the default)")
+	rootCmd.MarkFlagsMutuallyExclusive("24h", "12h")
+	rootCmd.PersistentFlags().String("db", "", "Path to chat.db (default $IMESSAGE_DB or ~/Library/Messages/chat.db)")
+	rootCmd.PersistentFlags().Duration("busy-timeout", database.DefaultBusyTimeout, "How long reads wait while Messages has the database locked")
+	rootCmd.PersistentFlags().BoolP("quiet", "q", false, "Print only the data: no headers, separators, tips or summaries")
+	rootCmd.PersistentFlags().BoolP("verbose", "v", false, "Log SQL queries, contact lookups and osascript runs to stderr")
+	rootCmd.PersistentFlags().String("country", "", "Country of phone numbers saved without a country code, e.g. GB or +44 (default US, or country in the config file)")
+	rootCmd.PersistentFlags().Bool("absolute", false, "Show full timestamps instead of relative ones (\"Yesterday\", \"Monday\")")
+
+	listCmd.Flags().IntP("limit", "n", 20, "Number of conversations to show (0 for all)")
+	readCmd.Flags().IntP("limit", "n", 30, "Number of messages to show (0 for all)")
+	readCmd.Flags().BoolP("follow", "f", false, "Keep running and print new messages as they arrive")
+	readCmd.Flags().Bool("no-interactive", false, "Fail instead of prompting when no conversation is given")
+	listCmd.Flags().Bool("show-identifiers", false, "Show the raw phone number/email after each name")
+	listCmd.Flags().Bool("hide-muted", false, "Hide muted conversations")
+	listCmd.Flags().Bool("include-archived", false, "Also show conversations archived in Messages")
+	listCmd.Flags().String("sort", "recent", "Order by recent, name or unread")
+	listCmd.Flags().Int("days", 0, "Only conversations with activity in the last N days (0 for any)")
+	listCmd.Flags().Bool("unread", false, "Only conversations with unread messages")
+	readCmd.Flags().Bool("show-identifiers", false, "Show the raw phone number/email after names, senders included")
+	addDirectionFlags(readCmd)
+	addCursorFlags(readCmd)
+	readCmd.Flags().Bool("raw", false, "Hex-dump each message's attributedBody after its text, for bug reports")
+	readCmd.MarkFlagsMutuallyExclusive("follow", "before-id")
+	readCmd.MarkFlagsMutuallyExclusive("follow", "raw")
+	chatCmd.Flags().Bool("no-interactive", false, "Fail instead of prompting when no conversation is given")
+	chatCmd.Flags().IntP("limit", "n", 10, "Number of recent messages to show")
+	chatCmd.Flags().Bool("live", false, "Print incoming messages as they arrive (redraws the prompt)")
 	sendCmd.Flags().BoolP("yes", "y", false, "Skip confirmation prompt")
-	searchCmd.Flags().IntP("limit", "n", 20, "Maximum results")
+	sendCmd.Flags().StringArray("to", nil, "Recipient (repeatable)")
+	sendCmd.Flags().Duration("delay", sender.DefaultSendDelay, "Pause between recipients when sending to several")
+	sendCmd.Flags().String("from", "", "Send from this account's handle (see 'imessage accounts') instead of the default")
+	sendCmd.Flags().String("at", "", "Send at a later time, e.g. \"2025-06-01 09:00\" or \"21:30\" (process must stay running)")
+	searchCmd.Flags().IntP("limit", "n", 20, "Maximum results (0 for no limit)")
+	searchCmd.Flags().BoolP("case-sensitive", "s", false, "Only match the query's exact case")
+	searchCmd.Flags().BoolP("ignore-case", "i", false, "Match regardless of case")
+	searchCmd.Flags().MarkDeprecated("ignore-case", "searches ignore case by default; use --case-sensitive for exact case")
+	searchCmd.Flags().BoolP("word", "w", false, "Match whole words only (filtered in Go, slower)")
+	searchCmd.Flags().Bool("json", false, "Output as JSON")
+	searchCmd.Flags().Bool("csv", false, "Output as CSV")
+	searchCmd.Flags().BoolP("count", "c", false, "Only print the number of matching messages")
+	searchCmd.Flags().BoolP("attachments", "a", false, "Also match attachment filenames")
+	searchCmd.Flags().String("timezone", "Local", "Time zone for dates in --json and --csv output (IANA name, UTC or Local)")
+	searchCmd.Flags().IntP("context", "C", 0, "Show this many messages before and after each match, from the same conversation")
+	searchCmd.Flags().BoolP("group-by-chat", "g", false, "Group matches under their conversation, oldest first within each")
+	for _, other := range []string{"context", "json", "csv"} {
+		searchCmd.MarkFlagsMutuallyExclusive("group-by-chat", other)
+	}
+	addDirectionFlags(searchCmd)
+	searchCmd.MarkFlagsMutuallyExclusive("json", "csv", "count")
+	searchCmd.MarkFlagsMutuallyExclusive("context", "json")
+	searchCmd.MarkFlagsMutuallyExclusive("context", "csv")
+	searchCmd.MarkFlagsMutuallyExclusive("context", "count")
 
 	rootCmd.AddCommand(listCmd)
 	rootCmd.AddCommand(readCmd)
 	rootCmd.AddCommand(sendCmd)
+	replyCmd.Flags().BoolP("yes", "y", false, "Skip confirmation prompt")
+	rootCmd.AddCommand(replyCmd)
 	rootCmd.AddCommand(chatCmd)
+	openCmd.Flags().Bool("no-interactive", false, "Fail instead of prompting when no conversation is given")
+	rootCmd.AddCommand(openCmd)
+	exportCmd.Flags().String("format", "txt", "Output format: txt or html")
+	exportCmd.Flags().StringP("output", "o", "", "Write to a file instead of stdout")
+	exportCmd.Flags().IntP("limit", "n", 0, "Number of most recent messages to export (0 for all)")
+	exportCmd.Flags().Bool("no-interactive", false, "Fail instead of prompting when no conversation is given")
+	addCursorFlags(exportCmd)
+	exportCmd.Flags().String("timezone", "Local", "Time zone for message times (IANA name such as Europe/London, UTC or Local)")
+	rootCmd.AddCommand(exportCmd)
+	attachmentsCmd.Flags().StringP("out", "o", "", "Copy the attachment files into this directory")
+	attachmentsCmd.Flags().Bool("no-interactive", false, "Fail instead of prompting when no conversation is given")
+	rootCmd.AddCommand(attachmentsCmd)
+	reactCmd.Flags().BoolP("yes", "y", false, "Skip confirmation prompt")
+	rootCmd.AddCommand(reactCmd)
+	rootCmd.AddCommand(muteCmd)
+	rootCmd.AddCommand(unmuteCmd)
 	rootCmd.AddCommand(searchCmd)
+	recentCmd.Flags().IntP("limit", "n", 20, "Number of messages to show (0 for all)")
+	rootCmd.AddCommand(recentCmd)
+	deletedCmd.Flags().IntP("limit", "n", 20, "Number of messages to show (0 for all)")
+	rootCmd.AddCommand(deletedCmd)
+	showCmd.Flags().Bool("raw", false, "Also hex-dump the raw attributedBody")
+	rootCmd.AddCommand(showCmd)
+	outboxFlushCmd.Flags().Duration("delay", sender.DefaultSendDelay, "Pause between messages")
+	outboxCmd.AddCommand(outboxFlushCmd)
+	outboxCmd.AddCommand(outboxClearCmd)
+	rootCmd.AddCommand(outboxCmd)
 	rootCmd.AddCommand(statusCmd)
+	rootCmd.AddCommand(doctorCmd)
+	rootCmd.AddCommand(accountsCmd)
+	statsCmd.Flags().Bool("json", false, "Output as JSON")
+	rootCmd.AddCommand(statsCmd)
+	rootCmd.AddCommand(previewCmd)
 	// Add tui command with debug flag
-	tuiCmd.Flags().BoolP("debug", "d", false, "Enable TUI debug logging to /tmp/imessage-tui.log")
+	tuiCmd.Flags().BoolP("debug", "d", false, "Enable TUI debug logging to /tmp/imessage-tui.log (also with --verbose)")
+	tuiCmd.Flags().String("lock-file", "", "Lock file path (default $IMESSAGE_TUI_LOCK or ~/.imessage-tui.lock)")
+	tuiCmd.Flags().String("me-color", "", "Color of my messages, by name or #rrggbb (default green)")
+	tuiCmd.Flags().String("them-color", "", "Color of other people's messages (default cyan)")
+	tuiCmd.Flags().String("status-color", "", "Status bar background color (default darkgreen)")
+	tuiCmd.Flags().Bool("show-identifiers", false, "Show the raw phone number/email after sender names")
+	tuiCmd.Flags().Duration("contacts-refresh", 0, "Re-read contacts this often, e.g. 10m (default off; press C to reload)")
 	rootCmd.AddCommand(tuiCmd)
+	rootCmd.AddCommand(completionCmd)
 	rootCmd.AddCommand(versionCmd)
 }
 
@@ -205,24 +778,167 @@ func Execute() error {
 	return rootCmd.Execute()
 }
 
-func cmdList(limit int) {
-	conversations, err := database.GetConversations(limit)
+// listOptions are the flags of the list command.
+type listOptions struct {
+	Limit           int
+	ShowIdentifiers bool
+	HideMuted       bool
+	// Sort is "recent", "name" or "unread"
+	Sort            string
+	IncludeArchived bool
+	// Days keeps conversations active in the last Days days; 0 keeps all
+	Days       int
+	UnreadOnly bool
+}
+
+func cmdList(opts listOptions) {
+	var muted *config.Config
+	limit := opts.Limit
+	if opts.HideMuted {
+		cfg, err := config.Load()
+		if err != nil {
+			printError(err)
+			os.Exit(1)
+		}
+		muted = cfg
+		// Fetch extra rows so the list is still full after filtering.
+		if limit > 0 {
+			limit += len(cfg.Muted)
+		}
+	}
+	// Other orders and filters need every conversation before picking the
+	// top ones.
+	if opts.Sort != "recent" || opts.Days > 0 || opts.UnreadOnly {
+		limit = 0
+	}
+	var since time.Time
+	if opts.Days > 0 {
+		since = time.Now().AddDate(0, 0, -opts.Days)
+	}
+
+	conversations, err := database.ListConversations(limit, database.ConversationOptions{IncludeArchived: opts.IncludeArchived})
 	if err != nil {
-		fmt.Println(colored(fmt.Sprintf("Error: %v", err), colorRed))
+		printError(err)
 		os.Exit(1)
 	}
 
+	// Keep each conversation's position in the recent order so the numbers
+	// shown still work with 'imessage read <number>'. Archived chats aren't
+	// numbered there, so they get 0.
+	numbers := make([]int, 0, len(conversations))
+	visible := conversations[:0]
+	position := 0
+	for _, conv := range conversations {
+		number := 0
+		if !conv.IsArchived {
+			position++
+			number = position
+		}
+		if muted != nil && muted.IsMuted(conv.ChatIdentifier) {
+			continue
+		}
+		if opts.Days > 0 && (conv.LastMessageDate == nil || conv.LastMessageDate.Before(since)) {
+			continue
+		}
+		if opts.UnreadOnly && conv.UnreadCount == 0 {
+			continue
+		}
+		visible = append(visible, conv)
+		numbers = append(numbers, number)
+	}
+	conversations = visible
+	sortConversations(conversations, numbers, opts.Sort)
+	if opts.Limit > 0 && len(conversations) > opts.Limit {
+		conversations = conversations[:opts.Limit]
+		numbers = numbers[:opts.Limit]
+	}
+
 	if len(conversations) == 0 {
-		fmt.Println("No conversations found.")
+		switch {
+		case opts.UnreadOnly:
+			fmt.Println("No conversations with unread messages.")
+		case opts.Days > 0:
+			fmt.Printf("No conversations with activity in the last %d day(s).\n", opts.Days)
+		default:
+			fmt.Println("No conversations found.")
+		}
+		return
+	}
+
+	printConversationTable(conversations, numbers, opts.ShowIdentifiers)
+
+	unread, _ := database.GetUnreadCount()
+	if unread > 0 {
+		printDecoration(colored(fmt.Sprintf("\n📬 %d unread message(s)", unread), colorYellow, colorBold))
+	}
+
+	printDecoration(colored("\nTip: Use 'imessage read <number>' to view messages from a conversation", colorDim))
+}
+
+// listSortOrders are the values accepted by list --sort.
+var listSortOrders = []string{"recent", "name", "unread"}
+
+// sortConversations orders conversations (and their list numbers) by name,
+// case-insensitively, or by unread count, most first. "recent" keeps the
+// database order. Ties keep their recent order.
+func sortConversations(conversations []database.Conversation, numbers []int, by string) {
+	var less func(a, b database.Conversation) bool
+	switch by {
+	case "name":
+		less = func(a, b database.Conversation) bool {
+			return strings.ToLower(a.DisplayName) < strings.ToLower(b.DisplayName)
+		}
+	case "unread":
+		less = func(a, b database.Conversation) bool {
+			return a.UnreadCount > b.UnreadCount
+		}
+	default:
 		return
 	}
 
-	header := fmt.Sprintf("\n%-4s %-30s %-20s %-10s", "#", "Contact", "Last Message", "Service")
-	fmt.Println(colored(header, colorBold, colorCyan))
-	fmt.Println(strings.Repeat("-", 70))
+	idx := make([]int, len(conversations))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(i, j int) bool {
+		return less(conversations[idx[i]], conversations[idx[j]])
+	})
+
+	sortedConvs := make([]database.Conversation, len(conversations))
+	sortedNums := make([]int, len(numbers))
+	for i, k := range idx {
+		sortedConvs[i] = conversations[k]
+		sortedNums[i] = numbers[k]
+	}
+	copy(conversations, sortedConvs)
+	copy(numbers, sortedNums)
+}
+
+// printConversationTable prints the numbered conversation table used by list.
+// numbers gives each row's number (0 for none); nil numbers the rows from 1.
+// With showIdentifiers the name column is widened to fit the raw identifier.
+func printConversationTable(conversations []database.Conversation, numbers []int, showIdentifiers bool) {
+	nameWidth := 30
+	if showIdentifiers {
+		nameWidth = 55
+	}
+
+	header := fmt.Sprintf("\n%-4s %s %-20s %-10s", "#", padRight("Contact", nameWidth), "Last Message", "Service")
+	printDecoration(colored(header, colorBold, colorCyan))
+	printDecoration(strings.Repeat("-", nameWidth+40))
 
 	for i, conv := range conversations {
-		name := truncate(conv.DisplayName, 28)
+		name := conv.DisplayName
+		if showIdentifiers {
+			name = withIdentifier(name, conv.ChatIdentifier)
+		}
+		if conv.UnreadCount > 0 {
+			name = fmt.Sprintf("(%d) %s", conv.UnreadCount, name)
+		}
+		if conv.IsArchived {
+			name += " [archived]"
+		}
+		name = truncate(name, nameWidth-2)
 		dateStr := formatDate(conv.LastMessageDate)
 		service := conv.Service
 		if service == "" {
@@ -234,105 +950,784 @@ func cmdList(limit int) {
 			serviceColor = colorGreen
 		}
 
-		fmt.Printf("%-4d %-30s %-20s %s\n", i+1, name, dateStr, colored(service, serviceColor))
+		number := strconv.Itoa(i + 1)
+		if numbers != nil {
+			number = strconv.Itoa(numbers[i])
+			if numbers[i] == 0 {
+				number = "-"
+			}
+		}
+		fmt.Printf("%-4s %s %-20s %s\n", number, padRight(name, nameWidth), dateStr, colored(service, serviceColor))
 	}
+}
 
-	unread, _ := database.GetUnreadCount()
-	if unread > 0 {
-		fmt.Println(colored(fmt.Sprintf("\n📬 %d unread message(s)", unread), colorYellow, colorBold))
+// withIdentifier appends the raw identifier to a resolved name, e.g.
+// "Jane Doe (+15551234567)". Names that are just the identifier are unchanged.
+func withIdentifier(name, identifier string) string {
+	if identifier == "" || name == identifier {
+		return name
+	}
+	return fmt.Sprintf("%s (%s)", name, identifier)
+}
+
+// conversationArg returns the conversation argument, prompting for one when
+// it was omitted. ok is false if the user entered nothing.
+func conversationArg(cmd *cobra.Command, args []string) (conversation string, ok bool) {
+	if len(args) > 0 {
+		return args[0], true
 	}
 
-	fmt.Println(colored("\nTip: Use 'imessage read <number>' to view messages from a conversation", colorDim))
+	noInteractive, _ := cmd.Flags().GetBool("no-interactive")
+	if noInteractive || !term.IsTerminal(int(os.Stdin.Fd())) {
+		fmt.Println(colored("Error: a conversation number or identifier is required", colorRed))
+		os.Exit(1)
+	}
+	conversation = pickConversation()
+	if conversation == "" {
+		fmt.Println("No conversation selected.")
+		return "", false
+	}
+	return conversation, true
 }
 
-func cmdRead(conversation string, limit int) {
-	conversations, err := database.GetConversations(100)
+// pickConversation shows the recent conversations and asks which one to use.
+func pickConversation() string {
+	conversations, err := database.GetConversations(20)
 	if err != nil {
-		fmt.Println(colored(fmt.Sprintf("Error: %v", err), colorRed))
+		printError(err)
+		os.Exit(1)
+	}
+	if len(conversations) == 0 {
+		fmt.Println("No conversations found.")
 		os.Exit(1)
 	}
 
-	var chatID int64
-	var chatIdentifier string
-	var chatName string
+	printConversationTable(conversations, nil, false)
 
-	if idx, err := strconv.Atoi(conversation); err == nil {
-		// User provided a number from the list
-		idx--
-		if idx >= 0 && idx < len(conversations) {
-			conv := conversations[idx]
-			chatID = conv.ChatID
-			chatName = conv.DisplayName
-		} else {
-			fmt.Println(colored(fmt.Sprintf("Invalid conversation number. Use 1-%d", len(conversations)), colorRed))
-			os.Exit(1)
+	reader := bufio.NewReader(os.Stdin)
+	fmt.Print(colored("\nWhich conversation? ", colorYellow))
+	answer, _ := reader.ReadString('\n')
+	return strings.TrimSpace(answer)
+}
+
+// resolveChatIdentifier turns a conversation number from list, a phone
+// number or an email into the chat identifier used by the database.
+func resolveChatIdentifier(conversation string) (string, error) {
+	if idx, err := strconv.Atoi(conversation); err == nil && !strings.HasPrefix(conversation, "+") {
+		conversations, err := database.GetConversations(100)
+		if err != nil {
+			return "", err
 		}
-	} else {
-		// User provided a phone number or identifier
-		chatIdentifier = conversation
-		contact, _ := database.GetContactByIdentifier(chatIdentifier)
-		if contact != nil {
-			if contact.ChatIdentifier != "" {
-				chatIdentifier = contact.ChatIdentifier
-			}
-			if contact.DisplayName != "" {
-				chatName = contact.DisplayName
-			} else {
-				chatName = chatIdentifier
-			}
-		} else {
-			chatName = chatIdentifier
+		if idx < 1 || idx > len(conversations) {
+			return "", fmt.Errorf("invalid conversation number. Use 1-%d", len(conversations))
 		}
+		return conversations[idx-1].ChatIdentifier, nil
 	}
 
-	var messages []database.Message
-	if chatID > 0 {
-		messages, err = database.GetMessages(chatID, "", limit)
-	} else {
-		messages, err = database.GetMessages(0, chatIdentifier, limit)
+	identifier := resolveName(conversation)
+	contact, err := database.GetContactByIdentifier(identifier)
+	if err != nil {
+		return "", err
 	}
+	if contact != nil && contact.ChatIdentifier != "" {
+		return contact.ChatIdentifier, nil
+	}
+	return identifier, nil
+}
 
+// cmdOpen shows the conversation in Messages.app.
+func cmdOpen(conversation string) {
+	identifier, err := resolveChatIdentifier(conversation)
 	if err != nil {
-		fmt.Println(colored(fmt.Sprintf("Error reading messages: %v", err), colorRed))
+		printError(err)
 		os.Exit(1)
 	}
 
-	if len(messages) == 0 {
-		fmt.Printf("No messages found for %s\n", chatName)
-		return
+	if err := sender.OpenConversation(identifier); err != nil {
+		printError(err)
+		os.Exit(1)
 	}
+	fmt.Println(colored(fmt.Sprintf("✓ Opened %s in Messages", database.GetContactName(identifier)), colorGreen))
+}
 
-	fmt.Println(colored(fmt.Sprintf("\n📱 Messages with %s", chatName), colorBold, colorCyan))
-	fmt.Println(strings.Repeat("-", 60))
+// cmdReact sends a tapback to the most recent message of a conversation.
+func cmdReact(conversation, reaction string, skipConfirm bool) {
+	name, _, err := sender.ParseTapback(reaction)
+	if err != nil {
+		printError(err)
+		os.Exit(1)
+	}
 
-	for _, msg := range messages {
-		dateStr := formatDate(msg.Date)
-		text := msg.Text
-		if text == "" {
-			text = "[No text content]"
+	identifier, err := resolveChatIdentifier(conversation)
+	if err != nil {
+		printError(err)
+		os.Exit(1)
+	}
+
+	// UI scripting can only reach the last message, so that's the target.
+	messages, err := database.GetMessages(0, identifier, 1)
+	if err != nil {
+		printError(err)
+		os.Exit(1)
+	}
+	if len(messages) == 0 {
+		fmt.Println(colored("Error: no messages in that conversation", colorRed))
+		os.Exit(1)
+	}
+	last := messages[len(messages)-1]
+
+	fmt.Printf("%s %s\n", colored("Reaction:", colorBold), name)
+	fmt.Printf("%s %s: %s\n", colored("To:", colorBold), last.Sender, truncate(last.Text, 60))
+	if !skipConfirm {
+		reader := bufio.NewReader(os.Stdin)
+		fmt.Print(colored("\nSend this reaction? [y/N] ", colorYellow))
+		confirm, _ := reader.ReadString('\n')
+		confirm = strings.TrimSpace(strings.ToLower(confirm))
+		if confirm != "y" && confirm != "yes" {
+			fmt.Println("Reaction cancelled.")
+			return
 		}
+	}
 
-		if msg.IsFromMe {
-			fmt.Printf("\n%58s\n", colored(dateStr, colorDim))
-			fmt.Printf("%10s %s\n", colored("Me:", colorGreen, colorBold), text)
+	// Confirming can take a while; make sure no message arrived since.
+	latestGUID := func() (string, error) {
+		messages, err := database.GetMessages(0, identifier, 1)
+		if err != nil || len(messages) == 0 {
+			return "", err
+		}
+		return messages[len(messages)-1].GUID, nil
+	}
+	if err := sender.SendTapback(identifier, last.GUID, name, latestGUID); err != nil {
+		printError(err)
+		os.Exit(1)
+	}
+	fmt.Println(colored("✓ Reaction sent!", colorGreen, colorBold))
+}
+
+// cmdMute adds the conversation to (or removes it from) the mute list.
+func cmdMute(conversation string, mute bool) {
+	identifier, err := resolveChatIdentifier(conversation)
+	if err != nil {
+		printError(err)
+		os.Exit(1)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		printError(err)
+		os.Exit(1)
+	}
+
+	name := database.GetContactName(identifier)
+	var changed bool
+	if mute {
+		changed = cfg.Mute(identifier)
+	} else {
+		changed = cfg.Unmute(identifier)
+	}
+	if !changed {
+		state := "not muted"
+		if mute {
+			state = "already muted"
+		}
+		fmt.Printf("%s is %s.\n", name, state)
+		return
+	}
+
+	if err := cfg.Save(); err != nil {
+		printError(err)
+		os.Exit(1)
+	}
+	if mute {
+		fmt.Println(colored(fmt.Sprintf("🔇 Muted %s", name), colorGreen))
+	} else {
+		fmt.Println(colored(fmt.Sprintf("🔔 Unmuted %s", name), colorGreen))
+	}
+}
+
+// looksLikeIdentifier reports whether s is a phone number, email or chat
+// identifier (e.g. "chat123456") rather than a contact name.
+func looksLikeIdentifier(s string) bool {
+	if strings.Contains(s, "@") {
+		return true
+	}
+	s = strings.TrimPrefix(s, "chat")
+	hasDigit := false
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			hasDigit = true
+		case strings.ContainsRune("+-(). ", r):
+		default:
+			return false
+		}
+	}
+	return hasDigit
+}
+
+// resolveName turns a contact name into a phone number or email, asking
+// which one to use when several contacts match. Identifiers, and names that
+// match no contact, are returned unchanged.
+func resolveName(arg string) string {
+	if looksLikeIdentifier(arg) {
+		return arg
+	}
+
+	identifier, err := database.FindIdentifierByName(arg)
+	if err == nil {
+		return identifier
+	}
+	var ambiguous *database.AmbiguousNameError
+	if !errors.As(err, &ambiguous) {
+		return arg
+	}
+
+	labels := make([]string, len(ambiguous.Matches))
+	for i, m := range ambiguous.Matches {
+		labels[i] = fmt.Sprintf("%s  %s", m.Name, colored(m.Identifier, colorDim))
+	}
+	return ambiguous.Matches[chooseMatch(arg, "contacts", labels)].Identifier
+}
+
+// conversationByName finds the conversation whose display name is name,
+// ignoring case, or else contains it. It asks which one to use when several
+// match and returns nil when none do.
+func conversationByName(name string, conversations []database.Conversation) *database.Conversation {
+	var exact, partial []int
+	lower := strings.ToLower(name)
+	for i, conv := range conversations {
+		display := strings.ToLower(conv.DisplayName)
+		switch {
+		case display == lower:
+			exact = append(exact, i)
+		case strings.Contains(display, lower):
+			partial = append(partial, i)
+		}
+	}
+	matches := exact
+	if len(matches) == 0 {
+		matches = partial
+	}
+	switch len(matches) {
+	case 0:
+		return nil
+	case 1:
+		return &conversations[matches[0]]
+	}
+
+	labels := make([]string, len(matches))
+	for i, idx := range matches {
+		conv := conversations[idx]
+		labels[i] = fmt.Sprintf("%s  %s", conv.DisplayName, colored(fmt.Sprintf("(last message %s)", formatDate(conv.LastMessageDate)), colorDim))
+	}
+	return &conversations[matches[chooseMatch(name, "conversations", labels)]]
+}
+
+// chooseMatch asks which of several matches for arg to use and returns its
+// index. When stdin isn't a terminal it lists them and exits instead.
+func chooseMatch(arg, what string, labels []string) int {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		fmt.Println(colored(fmt.Sprintf("Error: %d %s match %q", len(labels), what, arg), colorRed))
+		for _, label := range labels {
+			fmt.Printf("  %s\n", label)
+		}
+		os.Exit(1)
+	}
+
+	fmt.Println(colored(fmt.Sprintf("Several %s match %q:", what, arg), colorYellow))
+	for i, label := range labels {
+		fmt.Printf("  %d. %s\n", i+1, label)
+	}
+	reader := bufio.NewReader(os.Stdin)
+	fmt.Print(colored("Which one? ", colorYellow))
+	answer, _ := reader.ReadString('\n')
+	idx, err := strconv.Atoi(strings.TrimSpace(answer))
+	if err != nil || idx < 1 || idx > len(labels) {
+		fmt.Println(colored(fmt.Sprintf("Invalid choice. Use 1-%d", len(labels)), colorRed))
+		os.Exit(1)
+	}
+	return idx - 1
+}
+
+// addDirectionFlags adds --from-me and --from-them, read with directionFlag.
+func addDirectionFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("from-me", false, "Only messages you sent")
+	cmd.Flags().Bool("from-them", false, "Only messages you received")
+	cmd.MarkFlagsMutuallyExclusive("from-me", "from-them")
+}
+
+// directionFlag returns the direction selected by --from-me/--from-them.
+func directionFlag(cmd *cobra.Command) database.Direction {
+	if fromMe, _ := cmd.Flags().GetBool("from-me"); fromMe {
+		return database.FromMe
+	}
+	if fromThem, _ := cmd.Flags().GetBool("from-them"); fromThem {
+		return database.FromThem
+	}
+	return database.AnyDirection
+}
+
+// addCursorFlags adds the --before-id/--after-id paging flags.
+func addCursorFlags(cmd *cobra.Command) {
+	cmd.Flags().Int64("before-id", 0, "Only messages before the message with this ID (for paging back)")
+	cmd.Flags().Int64("after-id", 0, "Only messages after the message with this ID (for incremental sync)")
+}
+
+// cursorFlags returns the --before-id and --after-id values, exiting if
+// either is negative.
+func cursorFlags(cmd *cobra.Command) (beforeID, afterID int64) {
+	beforeID, _ = cmd.Flags().GetInt64("before-id")
+	afterID, _ = cmd.Flags().GetInt64("after-id")
+	if beforeID < 0 || afterID < 0 {
+		fmt.Println(colored("Error: --before-id and --after-id must be message IDs", colorRed))
+		os.Exit(1)
+	}
+	return beforeID, afterID
+}
+
+// timezoneFlag returns the location named by --timezone, exiting if it's
+// unknown.
+func timezoneFlag(cmd *cobra.Command) *time.Location {
+	name, _ := cmd.Flags().GetString("timezone")
+	loc, err := time.LoadLocation(name)
+	if err != nil || name == "" {
+		fmt.Println(colored(fmt.Sprintf("Error: invalid --timezone %q (want an IANA name such as Europe/London, UTC or Local)", name), colorRed))
+		os.Exit(1)
+	}
+	return loc
+}
+
+// readOptions are the flags of the read command.
+type readOptions struct {
+	Limit           int
+	Follow          bool
+	ShowIdentifiers bool
+	Direction       database.Direction
+	// BeforeID and AfterID page through history; see database.MessageOptions
+	BeforeID int64
+	AfterID  int64
+	// Raw hex-dumps each message's attributedBody after its text
+	Raw bool
+}
+
+func cmdRead(conversation string, opts readOptions) {
+	database.SetShowSenderIdentifiers(opts.ShowIdentifiers)
+	conversations, err := database.GetConversations(100)
+	if err != nil {
+		printError(err)
+		os.Exit(1)
+	}
+
+	var chatID int64
+	var chatIdentifier string
+	var chatName string
+	var participants []string
+	// unnamedAs is what chatName falls back to when the chat has no name
+	var unnamedAs string
+	// displayIdentifier is the raw identifier shown with --show-identifiers
+	var displayIdentifier string
+
+	var selected *database.Conversation
+	if idx, err := strconv.Atoi(conversation); err == nil {
+		// User provided a number from the list
+		idx--
+		if idx < 0 || idx >= len(conversations) {
+			fmt.Println(colored(fmt.Sprintf("Invalid conversation number. Use 1-%d", len(conversations)), colorRed))
+			os.Exit(1)
+		}
+		selected = &conversations[idx]
+	} else if !looksLikeIdentifier(conversation) {
+		// A conversation name, such as a named group or a contact
+		selected = conversationByName(conversation, conversations)
+	}
+
+	if selected != nil {
+		chatID = selected.ChatID
+		chatName = selected.DisplayName
+		displayIdentifier = selected.ChatIdentifier
+		participants = selected.Participants
+		unnamedAs = database.FormatPhoneNumber(selected.ChatIdentifier)
+	} else {
+		// User provided a phone number, identifier or contact name
+		chatIdentifier = resolveName(conversation)
+		contact, _ := database.GetContactByIdentifier(chatIdentifier)
+		if contact != nil {
+			if contact.ChatIdentifier != "" {
+				chatIdentifier = contact.ChatIdentifier
+			}
+			if contact.DisplayName != "" {
+				chatName = contact.DisplayName
+			} else {
+				chatName = database.FormatPhoneNumber(chatIdentifier)
+			}
 		} else {
-			fmt.Printf("\n%s\n", colored(dateStr, colorDim))
-			fmt.Printf("%s %s\n", colored(msg.Sender+":", colorBlue, colorBold), text)
+			chatName = database.FormatPhoneNumber(chatIdentifier)
+		}
+		unnamedAs = database.FormatPhoneNumber(chatIdentifier)
+		displayIdentifier = chatIdentifier
+		for _, conv := range conversations {
+			if conv.ChatIdentifier == chatIdentifier {
+				participants = conv.Participants
+				break
+			}
 		}
 	}
 
-	fmt.Println("\n" + strings.Repeat("-", 60))
+	// Group chats: list who's in the thread, and use that as the name when
+	// the group has none.
+	var members string
+	if len(participants) > 1 {
+		names := make([]string, len(participants))
+		for i, p := range participants {
+			names[i] = database.GetContactName(p)
+			if opts.ShowIdentifiers {
+				names[i] = withIdentifier(names[i], p)
+			}
+		}
+		members = strings.Join(names, ", ")
+		if chatName == "" || chatName == unnamedAs {
+			chatName = members
+		}
+	}
+	if opts.ShowIdentifiers && chatName != members {
+		chatName = withIdentifier(chatName, displayIdentifier)
+	}
+
+	var messages []database.Message
+	msgOpts := database.MessageOptions{Direction: opts.Direction, BeforeID: opts.BeforeID, AfterID: opts.AfterID}
+	if chatID > 0 {
+		messages, err = database.ListMessages(chatID, "", opts.Limit, msgOpts)
+	} else {
+		messages, err = database.ListMessages(0, chatIdentifier, opts.Limit, msgOpts)
+	}
+
+	if err != nil {
+		fmt.Println(colored(fmt.Sprintf("Error reading messages: %v", err), colorRed))
+		os.Exit(1)
+	}
+
+	// hasOlder is set when messages before the first one shown exist
+	var hasOlder bool
+	if len(messages) == 0 {
+		fmt.Printf("No messages found for %s\n", chatName)
+		if !opts.Follow {
+			return
+		}
+	} else {
+		printDecoration(colored(fmt.Sprintf("\n📱 Messages with %s", chatName), colorBold, colorCyan))
+		if members != "" && members != chatName {
+			printDecoration(colored(fmt.Sprintf("👥 %s", members), colorDim))
+		}
+		if total, err := database.CountMessages(chatID, chatIdentifier, msgOpts); err == nil {
+			// Paging forward from --after-id, the limit cuts off newer messages
+			forward := opts.AfterID > 0 && opts.BeforeID == 0
+			printDecoration(colored(messageCountLine(len(messages), total, !forward), colorDim))
+			hasOlder = len(messages) < total && !forward
+		}
+		printDecoration(strings.Repeat("-", 60))
+
+		replies := database.ReplyTexts(messages)
+		for _, msg := range messages {
+			printReadMessage(msg, replies)
+			if opts.Raw {
+				printAttributedBody(msg)
+			}
+		}
+	}
+
+	if opts.Follow {
+		// Follow on from the last message shown so nothing that arrives in
+		// between is missed
+		var lastID int64
+		if len(messages) > 0 {
+			lastID = messages[len(messages)-1].MessageID
+		}
+		followChat(chatID, chatIdentifier, opts.Direction, lastID)
+		return
+	}
+
+	printDecoration("\n" + strings.Repeat("-", 60))
 
 	replyTarget := chatIdentifier
 	if replyTarget == "" {
 		replyTarget = conversation
 	}
-	fmt.Println(colored(fmt.Sprintf("Reply: imessage send \"%s\" \"your message\"", replyTarget), colorDim))
+	if hasOlder {
+		printDecoration(colored(fmt.Sprintf("Older: imessage read \"%s\" --before-id %d", conversation, messages[0].MessageID), colorDim))
+	}
+	printDecoration(colored(fmt.Sprintf("Reply: imessage send \"%s\" \"your message\"", replyTarget), colorDim))
 }
 
-func cmdSend(recipient, message string, skipConfirm bool) {
-	if !skipConfirm {
-		fmt.Printf("%s %s\n", colored("Sending to:", colorBold), recipient)
+// messageCountLine describes how many of a conversation's messages are
+// shown, e.g. "Showing 30 of 1,234 messages". olderHidden tells whether the
+// limit left out older messages or newer ones.
+func messageCountLine(shown, total int, olderHidden bool) string {
+	if shown >= total {
+		if total == 1 {
+			return "Showing the only message"
+		}
+		return fmt.Sprintf("Showing all %s messages", formatCount(total))
+	}
+	hidden := "older"
+	if !olderHidden {
+		hidden = "newer"
+	}
+	return fmt.Sprintf("Showing %s of %s messages (%s ones hidden by --limit)", formatCount(shown), formatCount(total), hidden)
+}
+
+// formatCount formats n with thousands separators, e.g. 1,234.
+func formatCount(n int) string {
+	if n < 0 {
+		return "-" + formatCount(-n)
+	}
+	s := strconv.Itoa(n)
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
+
+// printReadMessage prints a single message in the read command's format.
+// replies maps reply originator GUIDs to their text (see database.ReplyTexts).
+func printReadMessage(msg database.Message, replies map[string]string) {
+	dateStr := formatDate(msg.Date)
+	text := msg.Text
+	if text == "" {
+		text = "[No text content]"
+	}
+	if msg.IsRetracted {
+		text = colored(text, colorDim)
+	} else if msg.IsEdited {
+		text += " " + colored("(edited)", colorDim)
+	}
+	if msg.Effect != "" && !msg.IsRetracted {
+		text += " " + colored(fmt.Sprintf("[sent with %s]", msg.Effect), colorDim)
+	}
+
+	var replyLine string
+	if msg.ReplyToGUID != "" {
+		if quoted, ok := replies[msg.ReplyToGUID]; ok {
+			replyLine = colored(fmt.Sprintf("↳ replying to: \"%s\"", truncate(quoted, 50)), colorDim)
+		} else {
+			replyLine = colored("↳ replying to an earlier message", colorDim)
+		}
+	}
+
+	if msg.IsFromMe {
+		fmt.Printf("\n%58s\n", colored(dateStr, colorDim))
+		if replyLine != "" {
+			fmt.Printf("%10s %s\n", "", replyLine)
+		}
+		fmt.Printf("%10s %s\n", colored("Me:", colorGreen, colorBold), text)
+		if status := deliveryStatus(msg); status != "" {
+			fmt.Printf("%10s %s\n", "", colored(status, colorDim))
+		}
+	} else {
+		fmt.Printf("\n%s\n", colored(dateStr, colorDim))
+		if replyLine != "" {
+			fmt.Println(replyLine)
+		}
+		fmt.Printf("%s %s\n", colored(msg.Sender+":", colorBlue, colorBold), text)
+	}
+}
+
+// followChat streams new messages for a chat to stdout until interrupted.
+// The chat is matched by ID when known, otherwise by chat identifier.
+// Messages after afterID are printed; 0 starts from the newest message.
+func followChat(chatID int64, chatIdentifier string, direction database.Direction, afterID int64) {
+	printDecoration(colored("\nFollowing new messages (Ctrl+C to stop)...", colorDim))
+
+	var printMu sync.Mutex
+	w := watcher.NewMessageWatcher(watcher.DefaultPollInterval)
+	if chatID > 0 {
+		w.WatchChat(chatID)
+	}
+	if afterID > 0 {
+		w.StartAfter(afterID)
+	}
+	w.OnNewMessages(func(msgs []watcher.Message) {
+		printMu.Lock()
+		defer printMu.Unlock()
+		for _, m := range msgs {
+			if chatID == 0 && m.ChatIdentifier != chatIdentifier {
+				continue
+			}
+			if !direction.Includes(m.IsFromMe) {
+				continue
+			}
+			var replies map[string]string
+			if m.ReplyToText != "" {
+				replies = map[string]string{m.ReplyToGUID: m.ReplyToText}
+			}
+			printReadMessage(database.Message{
+				MessageID:   m.MessageID,
+				GUID:        m.GUID,
+				ReplyToGUID: m.ReplyToGUID,
+				Text:        m.Text,
+				Date:        m.Date,
+				IsFromMe:    m.IsFromMe,
+				IsRead:      m.IsRead,
+				IsDelivered: m.IsDelivered,
+				DateRead:    m.DateRead,
+				IsEdited:    m.IsEdited,
+				IsRetracted: m.IsRetracted,
+				Effect:      m.Effect,
+				Sender:      m.Sender,
+				ChatID:      m.ChatID,
+				ChatIdent:   m.ChatIdentifier,
+				ChatName:    m.ChatName,
+			}, replies)
+		}
+	})
+	w.OnError(func(err error) {
+		fmt.Fprintln(os.Stderr, colored(fmt.Sprintf("Error: %v", err), colorRed))
+	})
+
+	w.Start()
+	defer w.Stop()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	<-sigCh
+	signal.Stop(sigCh)
+}
+
+// deliveryStatus describes whether one of my messages was delivered or read.
+func deliveryStatus(msg database.Message) string {
+	if msg.DateRead != nil {
+		return "✓✓ Read at " + formatDate(msg.DateRead)
+	}
+	if msg.IsDelivered {
+		return "✓ Delivered"
+	}
+	return ""
+}
+
+// cmdShow prints every field of the message whose ROWID or GUID is arg,
+// and with raw its attributedBody as a hex dump.
+func cmdShow(arg string, raw bool) {
+	var msg *database.Message
+	var err error
+	if id, convErr := strconv.ParseInt(arg, 10, 64); convErr == nil {
+		msg, err = database.GetMessageByID(id)
+	} else {
+		msg, err = database.GetMessageByGUID(arg)
+	}
+	if err != nil {
+		printError(err)
+		os.Exit(1)
+	}
+	if msg == nil {
+		fmt.Println(colored(fmt.Sprintf("Error: no message with ID or GUID %q", arg), colorRed))
+		os.Exit(1)
+	}
+
+	yesNo := func(b bool) string {
+		if b {
+			return "yes"
+		}
+		return "no"
+	}
+	field := func(name, value string) {
+		fmt.Printf("%s %s\n", colored(padRight(name+":", 13), colorDim), value)
+	}
+	dateField := func(name string, t *time.Time) {
+		if t != nil {
+			field(name, timefmt.FormatAbsoluteTime(t))
+		}
+	}
+
+	fmt.Println(colored(fmt.Sprintf("\nMessage %d", msg.MessageID), colorBold, colorCyan))
+	fmt.Println(strings.Repeat("-", 60))
+	field("GUID", msg.GUID)
+	field("Chat", fmt.Sprintf("%s (%s, chat %d)", msg.ChatName, msg.ChatIdent, msg.ChatID))
+	if msg.IsFromMe {
+		field("From", "Me")
+	} else {
+		field("From", msg.Sender)
+	}
+	dateField("Date", msg.Date)
+	field("Service", msg.Service)
+	field("Read", yesNo(msg.IsRead))
+	field("Delivered", yesNo(msg.IsDelivered))
+	dateField("Read at", msg.DateRead)
+	field("Edited", yesNo(msg.IsEdited))
+	field("Unsent", yesNo(msg.IsRetracted))
+	if msg.Effect != "" {
+		field("Effect", msg.Effect)
+	}
+	if msg.ReplyToGUID != "" {
+		field("Reply to", msg.ReplyToGUID)
+	}
+	for i, att := range msg.Attachments {
+		name := ""
+		if i == 0 {
+			name = "Attachments"
+		}
+		field(name, fmt.Sprintf("%s (%s, %d bytes)", att.FilePath, att.MIMEType, att.TotalBytes))
+	}
+	fmt.Println(colored("Text:", colorDim))
+	fmt.Println(msg.Text)
+
+	if raw {
+		fmt.Println()
+		printAttributedBody(*msg)
+	}
+	fmt.Println()
+}
+
+// printAttributedBody hex-dumps the attributedBody blob msg's text may be
+// extracted from, for show --raw and read --raw.
+func printAttributedBody(msg database.Message) {
+	if len(msg.AttributedBody) == 0 {
+		fmt.Println(colored(fmt.Sprintf("attributedBody of message %d: none", msg.MessageID), colorDim))
+		return
+	}
+	fmt.Println(colored(fmt.Sprintf("attributedBody of message %d (%d bytes):", msg.MessageID, len(msg.AttributedBody)), colorDim))
+	fmt.Print(hex.Dump(msg.AttributedBody))
+}
+
+// sendOptions are the flags of the send command.
+type sendOptions struct {
+	SkipConfirm bool
+	// At delays the send until this time; zero sends immediately
+	At time.Time
+	// Delay is the pause between recipients
+	Delay time.Duration
+	// From is the handle of the account to send from; "" uses Messages'
+	// default
+	From string
+}
+
+func cmdSend(recipients []string, message string, opts sendOptions) {
+	if len(recipients) == 0 {
+		fmt.Println(colored("Error: no recipients given", colorRed))
+		os.Exit(1)
+	}
+	for i, recipient := range recipients {
+		recipients[i] = resolveName(recipient)
+	}
+
+	// Checked before confirming so a typo doesn't surface after a long --at wait
+	send := func(recipient string) error {
+		return sender.SendMessage(recipient, message)
+	}
+	if opts.From != "" {
+		account, err := sender.FindAccount(opts.From)
+		if err != nil {
+			printError(err)
+			os.Exit(1)
+		}
+		send = func(recipient string) error {
+			return sender.SendMessageFrom(account, recipient, message)
+		}
+	}
+
+	if !opts.SkipConfirm {
+		if opts.From != "" {
+			fmt.Printf("%s %s\n", colored("From:", colorBold), opts.From)
+		}
+		fmt.Printf("%s %s\n", colored("Sending to:", colorBold), strings.Join(recipients, ", "))
 		fmt.Printf("%s %s\n", colored("Message:", colorBold), message)
 
 		reader := bufio.NewReader(os.Stdin)
@@ -346,25 +1741,149 @@ func cmdSend(recipient, message string, skipConfirm bool) {
 		}
 	}
 
-	fmt.Println("Sending message...")
+	if !opts.At.IsZero() {
+		fmt.Println(colored(fmt.Sprintf("⏰ Scheduled for %s", opts.At.Format("Mon "+timefmt.DateLayout+" "+timefmt.ClockLayout())), colorCyan, colorBold))
+		fmt.Println(colored("Keep this process running until then; press Ctrl+C to cancel.", colorDim))
+		if !waitUntil(opts.At) {
+			fmt.Println("\nScheduled send cancelled.")
+			return
+		}
+	}
+
+	if len(recipients) == 1 {
+		fmt.Println("Sending message...")
+
+		err := send(recipients[0])
+		if err != nil {
+			fmt.Println(colored(fmt.Sprintf("Error: %v", err), colorRed))
+			queueFailedSend(recipients[0], message, opts.From, err)
+			printOutboxHint()
+			printSendHelp(err)
+			os.Exit(1)
+		}
+
+		fmt.Println(colored("✓ Message sent successfully!", colorGreen, colorBold))
+		return
+	}
+
+	// Send to each recipient in turn, paced so Messages doesn't drop or
+	// reorder them; one failure doesn't stop the rest.
+	fmt.Printf("Sending message to %d recipients...\n", len(recipients))
+	errs := sender.SendAll(recipients, opts.Delay, func(recipient string) error {
+		err := send(recipient)
+		if err != nil {
+			fmt.Println(colored(fmt.Sprintf("  ✗ %s: %v", recipient, err), colorRed))
+			queueFailedSend(recipient, message, opts.From, err)
+			return err
+		}
+		fmt.Println(colored(fmt.Sprintf("  ✓ %s", recipient), colorGreen))
+		return nil
+	})
+	var failed int
+	var lastErr error
+	for _, err := range errs {
+		if err != nil {
+			failed++
+			lastErr = err
+		}
+	}
+
+	sent := len(recipients) - failed
+	if failed > 0 {
+		fmt.Println(colored(fmt.Sprintf("\nSent to %d of %d recipients, %d failed.", sent, len(recipients), failed), colorYellow, colorBold))
+		printOutboxHint()
+		printSendHelp(lastErr)
+		os.Exit(1)
+	}
+	fmt.Println(colored(fmt.Sprintf("\n✓ Message sent to all %d recipients!", sent), colorGreen, colorBold))
+}
+
+// queueFailedSend saves a message that failed to send to the outbox so
+// `outbox flush` can retry it.
+func queueFailedSend(recipient, message, from string, sendErr error) {
+	if err := outbox.Add(recipient, message, from, sendErr); err != nil {
+		fmt.Println(colored(fmt.Sprintf("Warning: could not save the message to the outbox: %v", err), colorYellow))
+	}
+}
+
+// printOutboxHint tells the user how to retry queued messages.
+func printOutboxHint() {
+	fmt.Println(colored("Saved to the outbox; retry with 'imessage outbox flush'.", colorDim))
+}
 
-	err := sender.SendMessage(recipient, message)
+// cmdReply sends message to the conversation at the top of the list, i.e.
+// the one with the most recent activity.
+func cmdReply(message string, skipConfirm bool) {
+	conversations, err := database.GetConversations(1)
 	if err != nil {
-		fmt.Println(colored(fmt.Sprintf("Error: %v", err), colorRed))
-		fmt.Println(colored("\nMake sure:", colorYellow))
-		fmt.Println("  1. Messages app is configured and signed in")
-		fmt.Println("  2. You've granted Terminal/SSH full disk access in System Preferences")
-		fmt.Println("  3. The recipient is a valid phone number or email")
+		printError(err)
+		os.Exit(1)
+	}
+	if len(conversations) == 0 || conversations[0].ChatIdentifier == "" {
+		fmt.Println(colored("Error: no recent conversation to reply to", colorRed))
 		os.Exit(1)
 	}
 
-	fmt.Println(colored("✓ Message sent successfully!", colorGreen, colorBold))
+	conv := conversations[0]
+	fmt.Printf("%s %s %s\n", colored("Replying to:", colorBold), conv.DisplayName,
+		colored(fmt.Sprintf("(last message %s)", formatDate(conv.LastMessageDate)), colorDim))
+	cmdSend([]string{conv.ChatIdentifier}, message, sendOptions{SkipConfirm: skipConfirm})
+}
+
+// printError prints err, followed by remediation steps if it is a
+// permission failure.
+func printError(err error) {
+	fmt.Println(colored(fmt.Sprintf("Error: %v", err), colorRed))
+	printPermissionHelp(err)
+}
+
+// printPermissionHelp explains how to grant the permission behind err. It
+// reports whether err was a permission failure.
+func printPermissionHelp(err error) bool {
+	switch {
+	case errors.Is(err, database.ErrNoFullDiskAccess):
+		fmt.Println(colored("\nGrant Full Disk Access to your terminal:", colorYellow))
+		fmt.Println("  1. Open System Settings → Privacy & Security → Full Disk Access")
+		fmt.Println("  2. Turn on your terminal app (Terminal, iTerm2, ...), adding it with + if it's missing")
+		fmt.Println("  3. Quit and reopen the terminal")
+	case errors.Is(err, sender.ErrNoAccessibilityPermission):
+		fmt.Println(colored("\nAllow your terminal to use UI scripting:", colorYellow))
+		fmt.Println("  1. Open System Settings → Privacy & Security → Accessibility")
+		fmt.Println("  2. Turn on your terminal app, adding it with + if it's missing")
+	case errors.Is(err, sender.ErrNoAutomationPermission):
+		fmt.Println(colored("\nAllow your terminal to control Messages:", colorYellow))
+		fmt.Println("  1. Open System Settings → Privacy & Security → Automation")
+		fmt.Println("  2. Under your terminal app, turn on Messages")
+		fmt.Println("  3. If it isn't listed, run 'tccutil reset AppleEvents' and try again to get the prompt")
+	default:
+		return false
+	}
+	return true
+}
+
+// printSendHelp lists the usual causes of a failed send, or the specific fix
+// when err is a permission failure.
+func printSendHelp(err error) {
+	if printPermissionHelp(err) {
+		return
+	}
+	fmt.Println(colored("\nMake sure:", colorYellow))
+	fmt.Println("  1. Messages app is configured and signed in")
+	fmt.Println("  2. You've granted Terminal/SSH full disk access in System Preferences")
+	fmt.Println("  3. The recipient is a valid phone number or email")
+}
+
+// chatOptions are the flags of the chat command.
+type chatOptions struct {
+	// Limit is how many recent messages are shown on start and refresh
+	Limit int
+	Live  bool
 }
 
-func cmdChat(contact string) {
+func cmdChat(contact string, opts chatOptions) {
 	conversations, err := database.GetConversations(100)
 	if err != nil {
-		fmt.Println(colored(fmt.Sprintf("Error: %v", err), colorRed))
+		printError(err)
 		os.Exit(1)
 	}
 
@@ -384,7 +1903,7 @@ func cmdChat(contact string) {
 			os.Exit(1)
 		}
 	} else {
-		chatIdentifier = contact
+		chatIdentifier = resolveName(contact)
 		c, _ := database.GetContactByIdentifier(chatIdentifier)
 		if c != nil {
 			if c.ChatIdentifier != "" {
@@ -393,46 +1912,72 @@ func cmdChat(contact string) {
 			if c.DisplayName != "" {
 				chatName = c.DisplayName
 			} else {
-				chatName = chatIdentifier
+				chatName = database.FormatPhoneNumber(chatIdentifier)
 			}
 		} else {
-			chatName = chatIdentifier
+			chatName = database.FormatPhoneNumber(chatIdentifier)
 		}
 	}
 
 	fmt.Println(colored(fmt.Sprintf("\n💬 Chat with %s", chatName), colorBold, colorCyan))
 	fmt.Println(colored("Type your message and press Enter to send. Type 'quit' or Ctrl+C to exit.", colorDim))
-	fmt.Println(colored("Type 'refresh' or 'r' to reload messages.", colorDim))
+	if opts.Live {
+		fmt.Println(colored("New messages will appear as they arrive.", colorDim))
+	} else {
+		fmt.Println(colored("Type 'refresh' or 'r' to reload messages.", colorDim))
+	}
 	fmt.Println(strings.Repeat("-", 60))
 
-	showMessages := func() {
-		var messages []database.Message
-		if chatID > 0 {
-			messages, _ = database.GetMessages(chatID, "", 10)
-		} else {
-			messages, _ = database.GetMessages(0, chatIdentifier, 10)
-		}
+	// showMessages prints the latest messages and returns the ID of the
+	// newest one.
+	showMessages := func() int64 {
+		// Oldest first, so the newest ends up next to the prompt
+		messages, _ := database.GetMessages(chatID, chatIdentifier, opts.Limit)
 
+		var lastID int64
 		for _, msg := range messages {
-			dateStr := formatDate(msg.Date)
-			text := msg.Text
-			if text == "" {
-				text = ""
-			}
-			if msg.IsFromMe {
-				fmt.Printf("  %s %s\n", colored(fmt.Sprintf("[%s] Me:", dateStr), colorGreen), text)
-			} else {
-				fmt.Printf("  %s %s\n", colored(fmt.Sprintf("[%s] %s:", dateStr, msg.Sender), colorBlue), text)
-			}
+			fmt.Println(chatLine(msg.Date, msg.Sender, msg.IsFromMe, msg.Text))
+			lastID = max(lastID, msg.MessageID)
 		}
 		fmt.Println()
+		return lastID
 	}
 
-	showMessages()
+	lastID := showMessages()
+
+	// printMu keeps live messages from interleaving with the prompt.
+	var printMu sync.Mutex
+	prompt := colored("You: ", colorGreen, colorBold)
+	if opts.Live {
+		w := watcher.NewMessageWatcher(watcher.DefaultPollInterval)
+		if chatID > 0 {
+			w.WatchChat(chatID)
+		}
+		if lastID > 0 {
+			w.StartAfter(lastID)
+		}
+		w.OnNewMessages(func(msgs []watcher.Message) {
+			printMu.Lock()
+			defer printMu.Unlock()
+			for _, m := range msgs {
+				if m.IsFromMe || (chatID == 0 && m.ChatIdentifier != chatIdentifier) {
+					continue
+				}
+				// Replace the prompt line, then draw the prompt again below.
+				// Anything typed so far is still read, but no longer shown.
+				fmt.Printf("\r\033[K%s\n", chatLine(m.Date, m.Sender, false, m.Text))
+				fmt.Print(prompt)
+			}
+		})
+		w.Start()
+		defer w.Stop()
+	}
 
 	reader := bufio.NewReader(os.Stdin)
 	for {
-		fmt.Print(colored("You: ", colorGreen, colorBold))
+		printMu.Lock()
+		fmt.Print(prompt)
+		printMu.Unlock()
 		input, err := reader.ReadString('\n')
 		if err != nil {
 			fmt.Println("\nGoodbye!")
@@ -446,36 +1991,138 @@ func cmdChat(contact string) {
 			fmt.Println("Goodbye!")
 			return
 		case "refresh", "r":
+			printMu.Lock()
 			fmt.Println(colored("\n--- Refreshing ---\n", colorDim))
 			showMessages()
+			printMu.Unlock()
 			continue
 		case "":
 			continue
 		}
 
 		err = sender.SendMessage(chatIdentifier, input)
+		printMu.Lock()
 		if err != nil {
-			fmt.Println(colored("  ✗ Failed to send", colorRed))
+			fmt.Println(colored("  ✗ Failed to send (saved to the outbox)", colorRed))
+			queueFailedSend(chatIdentifier, input, "", err)
 		} else {
-			fmt.Println(colored("  ✓ Sent", colorDim))
+			// Show it as the newest message without re-reading the chat
+			now := time.Now()
+			fmt.Println(chatLine(&now, "Me", true, input))
 		}
+		printMu.Unlock()
+	}
+}
+
+// chatLine formats a message for chat mode: "[date] Sender: text", with my
+// messages in green.
+func chatLine(date *time.Time, sender string, isFromMe bool, text string) string {
+	if isFromMe {
+		return fmt.Sprintf("  %s %s", colored(fmt.Sprintf("[%s] Me:", formatDate(date)), colorGreen), text)
+	}
+	return fmt.Sprintf("  %s %s", colored(fmt.Sprintf("[%s] %s:", formatDate(date), sender), colorBlue), text)
+}
+
+// searchResultJSON is the --json representation of a search result. Text is
+// never truncated.
+type searchResultJSON struct {
+	GUID           string   `json:"guid"`
+	ChatIdentifier string   `json:"chat_identifier"`
+	ChatName       string   `json:"chat_name"`
+	Sender         string   `json:"sender"`
+	IsFromMe       bool     `json:"is_from_me"`
+	Date           string   `json:"date"`
+	Text           string   `json:"text"`
+	Attachments    []string `json:"attachments,omitempty"`
+}
+
+// toSearchResultJSON converts msg for structured output, with its date in loc.
+func toSearchResultJSON(msg database.Message, loc *time.Location) searchResultJSON {
+	r := searchResultJSON{
+		GUID:           msg.GUID,
+		ChatIdentifier: msg.ChatIdent,
+		ChatName:       msg.ChatName,
+		Sender:         msg.Sender,
+		IsFromMe:       msg.IsFromMe,
+		Text:           msg.Text,
+	}
+	if msg.Date != nil {
+		r.Date = msg.Date.In(loc).Format(time.RFC3339)
+	}
+	for _, att := range msg.Attachments {
+		r.Attachments = append(r.Attachments, att.Filename)
 	}
+	return r
 }
 
-func cmdSearch(query string, limit int) {
-	results, err := database.SearchMessages(query, limit)
+// searchOptions are the flags of the search command that shape its output;
+// the ones that pick messages are in database.SearchOptions.
+type searchOptions struct {
+	Limit   int
+	JSON    bool
+	CSV     bool
+	Context int
+	// GroupByChat lists matches under a header per conversation
+	GroupByChat bool
+	// Location is the time zone of --json and --csv dates
+	Location *time.Location
+}
+
+func cmdSearch(query string, filter database.SearchOptions, opts searchOptions) {
+	results, err := database.SearchMessages(query, opts.Limit, filter)
 	if err != nil {
 		fmt.Println(colored(fmt.Sprintf("Error searching: %v", err), colorRed))
 		os.Exit(1)
 	}
 
+	if opts.JSON {
+		out := make([]searchResultJSON, 0, len(results))
+		for _, msg := range results {
+			out = append(out, toSearchResultJSON(msg, opts.Location))
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(out); err != nil {
+			printError(err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if opts.CSV {
+		w := csv.NewWriter(os.Stdout)
+		w.Write([]string{"guid", "chat_identifier", "chat_name", "sender", "is_from_me", "date", "text"})
+		for _, msg := range results {
+			r := toSearchResultJSON(msg, opts.Location)
+			w.Write([]string{r.GUID, r.ChatIdentifier, r.ChatName, r.Sender, strconv.FormatBool(r.IsFromMe), r.Date, r.Text})
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			printError(err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	if len(results) == 0 {
 		fmt.Printf("No messages found matching '%s'\n", query)
 		return
 	}
 
-	fmt.Println(colored(fmt.Sprintf("\nSearch results for '%s':", query), colorBold, colorCyan))
-	fmt.Println(strings.Repeat("-", 70))
+	printDecoration(colored(fmt.Sprintf("\nSearch results for '%s':", query), colorBold, colorCyan))
+	printDecoration(strings.Repeat("-", 70))
+
+	if opts.Context > 0 {
+		printSearchContext(results, opts.Context)
+		printDecoration(fmt.Sprintf("\nFound %d message(s)", len(results)))
+		return
+	}
+
+	if opts.GroupByChat {
+		printSearchByChat(results)
+		printDecoration(fmt.Sprintf("\nFound %d message(s)", len(results)))
+		return
+	}
 
 	for _, msg := range results {
 		dateStr := formatDate(msg.Date)
@@ -486,27 +2133,197 @@ func cmdSearch(query string, limit int) {
 		}
 		text := truncate(msg.Text, 40)
 
-		fmt.Printf("%-20s %s %-17s %s\n",
+		fmt.Printf("%-20s %s %s %s\n",
 			dateStr,
-			colored(fmt.Sprintf("%-22s", chat), colorCyan),
-			colored(senderName, colorYellow),
+			colored(padRight(chat, 22), colorCyan),
+			colored(padRight(senderName, 17), colorYellow),
 			text)
+		for _, att := range msg.Attachments {
+			fmt.Printf("%62s %s\n", "", colored("📎 "+att.Filename, colorDim))
+		}
+	}
+
+	printDecoration(fmt.Sprintf("\nFound %d message(s)", len(results)))
+}
+
+func cmdRecent(limit int) {
+	messages, err := database.GetRecentMessages(limit)
+	if err != nil {
+		printError(err)
+		os.Exit(1)
+	}
+
+	if len(messages) == 0 {
+		printDecoration("No messages found.")
+		return
+	}
+
+	printDecoration(colored("\nRecent messages:", colorBold, colorCyan))
+	printDecoration(strings.Repeat("-", 70))
+
+	for _, msg := range messages {
+		// Name who wrote it when the conversation's name doesn't already,
+		// i.e. my messages and group chats.
+		text := msg.Text
+		if msg.IsFromMe {
+			text = "Me: " + text
+		} else if msg.Sender != msg.ChatName {
+			text = msg.Sender + ": " + text
+		}
+		fmt.Printf("%-20s %s %s\n",
+			formatDate(msg.Date),
+			colored(padRight(truncate(msg.ChatName, 20), 22), colorCyan),
+			truncate(text, 50))
+	}
+}
+
+func cmdDeleted(limit int) {
+	messages, err := database.GetRecentlyDeletedMessages(limit)
+	if errors.Is(err, database.ErrNoRecentlyDeleted) {
+		fmt.Println(colored("Can't list deleted messages: "+err.Error(), colorYellow))
+		return
+	}
+	if err != nil {
+		printError(err)
+		os.Exit(1)
+	}
+
+	if len(messages) == 0 {
+		printDecoration("Recently Deleted is empty.")
+		return
+	}
+
+	printDecoration(colored("\nRecently deleted messages:", colorBold, colorCyan))
+	printDecoration(strings.Repeat("-", 70))
+
+	for _, msg := range messages {
+		text := msg.Text
+		if msg.IsFromMe {
+			text = "Me: " + text
+		} else if msg.Sender != msg.ChatName {
+			text = msg.Sender + ": " + text
+		}
+		fmt.Printf("%-20s %s %s %s\n",
+			formatDate(msg.Date),
+			colored(padRight(truncate(msg.ChatName, 20), 22), colorCyan),
+			truncate(text, 40),
+			colored("(deleted "+formatDate(msg.DateDeleted)+")", colorDim))
 	}
 
-	fmt.Printf("\nFound %d message(s)\n", len(results))
+	printDecoration(colored("\nRecover them in Messages under View > Recently Deleted.", colorDim))
+}
+
+// printSearchByChat prints search results under a header per conversation,
+// using the chat names SearchMessages resolved. Conversations are ordered by
+// their newest match, and each one's matches oldest first so they read like
+// the thread.
+func printSearchByChat(results []database.Message) {
+	var order []int64
+	groups := make(map[int64][]database.Message)
+	for _, msg := range results {
+		if _, ok := groups[msg.ChatID]; !ok {
+			order = append(order, msg.ChatID)
+		}
+		groups[msg.ChatID] = append(groups[msg.ChatID], msg)
+	}
+
+	for i, chatID := range order {
+		msgs := groups[chatID]
+		sort.SliceStable(msgs, func(a, b int) bool {
+			da, db := msgs[a].Date, msgs[b].Date
+			if da == nil || db == nil {
+				return da == nil && db != nil
+			}
+			return da.Before(*db)
+		})
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Println(colored(fmt.Sprintf("%s (%d)", msgs[0].ChatName, len(msgs)), colorCyan, colorBold))
+		for _, msg := range msgs {
+			senderName := "Me"
+			if !msg.IsFromMe {
+				senderName = truncate(msg.Sender, 15)
+			}
+			fmt.Printf("  %-20s %s %s\n", formatDate(msg.Date), colored(padRight(senderName, 17), colorYellow), truncate(msg.Text, 60))
+			for _, att := range msg.Attachments {
+				fmt.Printf("  %38s %s\n", "", colored("📎 "+att.Filename, colorDim))
+			}
+		}
+	}
+}
+
+// printSearchContext prints each search result with the messages around it
+// in its conversation, one group per match, like grep -C.
+func printSearchContext(results []database.Message, contextLines int) {
+	for i, match := range results {
+		if i > 0 {
+			fmt.Println(colored("--", colorDim))
+		}
+		fmt.Println(colored(match.ChatName, colorCyan, colorBold))
+
+		earlier, later, err := database.GetSurroundingMessages(match.ChatID, match.MessageID, contextLines, contextLines)
+		if err != nil {
+			fmt.Println(colored(fmt.Sprintf("  (context unavailable: %v)", err), colorDim))
+		}
+		for _, msg := range earlier {
+			printContextLine(msg, false)
+		}
+		printContextLine(match, true)
+		for _, msg := range later {
+			printContextLine(msg, false)
+		}
+	}
+}
+
+// printContextLine prints one message of a context group; the match is
+// marked with ">" and the surrounding messages are dimmed.
+func printContextLine(msg database.Message, isMatch bool) {
+	senderName := "Me"
+	if !msg.IsFromMe {
+		senderName = truncate(msg.Sender, 15)
+	}
+	line := fmt.Sprintf("%-20s %s %s", formatDate(msg.Date), padRight(senderName, 17), truncate(msg.Text, 60))
+	if isMatch {
+		fmt.Println(colored("> "+line, colorYellow))
+	} else {
+		fmt.Println(colored("  "+line, colorDim))
+	}
+}
+
+func cmdSearchCount(query string, opts database.SearchOptions) {
+	count, err := database.CountSearchMessages(query, opts)
+	if err != nil {
+		fmt.Println(colored(fmt.Sprintf("Error searching: %v", err), colorRed))
+		os.Exit(1)
+	}
+	fmt.Println(count)
 }
 
 func cmdStatus() {
-	fmt.Println(colored("\n📊 iMessage CLI Status", colorBold, colorCyan))
-	fmt.Println(strings.Repeat("-", 40))
+	printDecoration(colored("\n📊 iMessage CLI Status", colorBold, colorCyan))
+	printDecoration(strings.Repeat("-", 40))
 
-	// Check database access
+	// Check database access. Stat can succeed without Full Disk Access, so
+	// only a real query tells whether reads work.
 	dbPath := database.GetDBPath()
-	if _, err := os.Stat(dbPath); err == nil {
+	_, statErr := os.Stat(dbPath)
+	if statErr == nil {
 		fmt.Printf("%s Database found: %s\n", colored("✓", colorGreen), dbPath)
-	} else {
+	} else if os.IsNotExist(statErr) {
 		fmt.Printf("%s Database not found: %s\n", colored("✗", colorRed), dbPath)
 	}
+	accessErr := database.CheckAccess()
+	switch {
+	case os.IsNotExist(statErr):
+		// Already reported above.
+	case accessErr == nil:
+		fmt.Printf("%s Full Disk Access: granted\n", colored("✓", colorGreen))
+	case errors.Is(accessErr, database.ErrNoFullDiskAccess):
+		fmt.Printf("%s Full Disk Access: denied\n", colored("✗", colorRed))
+	default:
+		fmt.Printf("%s Database not readable: %v\n", colored("✗", colorRed), accessErr)
+	}
 
 	// Check Messages app
 	if sender.CheckMessagesRunning() {
@@ -515,14 +2332,355 @@ func cmdStatus() {
 		fmt.Printf("%s Messages app is not running\n", colored("○", colorYellow))
 	}
 
+	if n := database.ContactCount(); n > 0 {
+		fmt.Printf("%s Contacts: %d phone numbers and emails\n", colored("✓", colorGreen), n)
+	} else {
+		fmt.Printf("%s %s\n", colored("○", colorYellow), database.NoContactsHint)
+	}
+
+	if accessErr != nil {
+		printPermissionHelp(accessErr)
+		printDecoration("")
+		return
+	}
+
 	// Show stats
 	conversations, _ := database.GetConversations(1000)
 	unread, _ := database.GetUnreadCount()
 
-	fmt.Println("\n📈 Statistics:")
+	printDecoration("\n📈 Statistics:")
 	fmt.Printf("   Conversations: %d\n", len(conversations))
 	fmt.Printf("   Unread messages: %d\n", unread)
-	fmt.Println()
+	if queued, err := outbox.Load(); err == nil && len(queued) > 0 {
+		fmt.Printf("   Outbox: %d unsent message(s) (imessage outbox)\n", len(queued))
+	}
+
+	if services, err := database.GetServiceCounts(); err == nil && len(services) > 0 {
+		fmt.Println("   Messages by service:")
+		for _, svc := range services {
+			fmt.Printf("     %-12s %d\n", svc.Service, svc.Count)
+		}
+	}
+
+	// A recent last message means the database is live and syncing.
+	if last, err := database.GetLastMessageDate(); err == nil && last != nil {
+		fmt.Printf("   Most recent message: %s\n", formatDate(last))
+	}
+	printDecoration("")
+}
+
+func cmdDoctor() {
+	printDecoration(colored("\n🩺 iMessage CLI Doctor", colorBold, colorCyan))
+	printDecoration(strings.Repeat("-", 40))
+
+	// Checks print as they run; how to fix each failure follows the list.
+	var problems int
+	var fixes []func()
+	pass := func(format string, a ...interface{}) {
+		fmt.Printf("%s %s\n", colored("✓", colorGreen), fmt.Sprintf(format, a...))
+	}
+	warn := func(format string, a ...interface{}) {
+		fmt.Printf("%s %s\n", colored("○", colorYellow), fmt.Sprintf(format, a...))
+	}
+	fail := func(fix func(), format string, a ...interface{}) {
+		fmt.Printf("%s %s\n", colored("✗", colorRed), fmt.Sprintf(format, a...))
+		problems++
+		if fix != nil {
+			fixes = append(fixes, fix)
+		}
+	}
+	permissionFix := func(err error) func() {
+		return func() { printPermissionHelp(err) }
+	}
+
+	dbPath := database.GetDBPath()
+	if _, err := os.Stat(dbPath); os.IsNotExist(err) {
+		fail(func() {
+			fmt.Println(colored("\nNo Messages database:", colorYellow))
+			fmt.Println("  Messages creates it once you sign in on this Mac; to read a copy,")
+			fmt.Printf("  pass --db or set $%s\n", database.DBPathEnv)
+		}, "Database not found: %s", dbPath)
+	} else if err := database.CheckAccess(); errors.Is(err, database.ErrNoFullDiskAccess) {
+		fail(permissionFix(err), "Database: Full Disk Access denied")
+	} else if err != nil {
+		fail(nil, "Database not readable: %v", err)
+	} else {
+		pass("Database readable: %s", dbPath)
+	}
+
+	if n := database.ContactCount(); n > 0 {
+		pass("Contacts: %d phone numbers and emails", n)
+	} else {
+		warn("%s", database.NoContactsHint)
+	}
+
+	if sender.CheckMessagesRunning() {
+		pass("Messages app is running")
+	} else {
+		warn("Messages app is not running (the next check starts it)")
+	}
+
+	accounts, err := sender.ListAccounts()
+	switch {
+	case errors.Is(err, sender.ErrNoAutomationPermission):
+		fail(permissionFix(err), "Automation: not allowed to control Messages")
+	case err != nil:
+		fail(nil, "Automation: couldn't talk to Messages: %v", err)
+	default:
+		pass("Automation: allowed to control Messages")
+		if len(accounts) == 0 {
+			fail(func() {
+				fmt.Println(colored("\nSign in to Messages:", colorYellow))
+				fmt.Println("  Open Messages → Settings → iMessage and sign in with your Apple Account")
+			}, "No accounts signed in to Messages")
+		} else {
+			pass("Accounts: %s", strings.Join(accounts, ", "))
+		}
+	}
+
+	// Only react and undo send need UI scripting, so it's not a failure.
+	if err := sender.CheckAccessibility(); errors.Is(err, sender.ErrNoAccessibilityPermission) {
+		warn("Accessibility: not allowed (needed only by react and the TUI's undo send)")
+		fixes = append(fixes, permissionFix(err))
+	} else if err != nil {
+		warn("Accessibility: couldn't check: %v", err)
+	} else {
+		pass("Accessibility: allowed")
+	}
+
+	for _, fix := range fixes {
+		fix()
+	}
+	if problems > 0 {
+		printDecoration(colored(fmt.Sprintf("\n%d problem(s) found", problems), colorRed))
+		os.Exit(1)
+	}
+	printDecoration(colored("\nEverything needed to read and send is in place", colorGreen))
+}
+
+// contactCountJSON is the --json representation of database.ContactMessageCount.
+type contactCountJSON struct {
+	Identifier string `json:"identifier"`
+	Name       string `json:"name"`
+	Count      int    `json:"count"`
+}
+
+// statsJSON is the --json representation of database.MessageStats.
+type statsJSON struct {
+	Sent                   int                `json:"sent"`
+	Received               int                `json:"received"`
+	TopContacts            []contactCountJSON `json:"top_contacts"`
+	BusiestHour            int                `json:"busiest_hour"`
+	BusiestHourCount       int                `json:"busiest_hour_count"`
+	AvgResponseTimeSeconds float64            `json:"avg_response_time_seconds"`
+}
+
+func cmdAccounts() {
+	accounts, err := sender.ListAccounts()
+	if err != nil {
+		printError(err)
+		os.Exit(1)
+	}
+
+	if len(accounts) == 0 {
+		fmt.Println("No accounts configured in Messages.")
+		return
+	}
+
+	printDecoration(colored("\n👤 Messages accounts", colorBold, colorCyan))
+	printDecoration(strings.Repeat("-", 40))
+	for _, account := range accounts {
+		fmt.Printf("   %s\n", account)
+	}
+	printDecoration("")
+}
+
+func cmdStats(jsonOut bool) {
+	stats, err := database.GetMessageStats()
+	if err != nil {
+		printError(err)
+		os.Exit(1)
+	}
+
+	if jsonOut {
+		out := statsJSON{
+			Sent:                   stats.Sent,
+			Received:               stats.Received,
+			BusiestHour:            stats.BusiestHour,
+			BusiestHourCount:       stats.BusiestHourCount,
+			AvgResponseTimeSeconds: stats.AvgResponseTime.Seconds(),
+		}
+		out.TopContacts = make([]contactCountJSON, 0, len(stats.TopContacts))
+		for _, c := range stats.TopContacts {
+			out.TopContacts = append(out.TopContacts, contactCountJSON{
+				Identifier: c.Identifier,
+				Name:       c.Name,
+				Count:      c.Count,
+			})
+		}
+
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(out); err != nil {
+			printError(err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	printDecoration(colored("\n📈 Message Statistics", colorBold, colorCyan))
+	printDecoration(strings.Repeat("-", 50))
+	fmt.Printf("   Sent:     %d\n", stats.Sent)
+	fmt.Printf("   Received: %d\n", stats.Received)
+	if stats.BusiestHour >= 0 {
+		fmt.Printf("   Busiest hour: %02d:00 (%d messages)\n", stats.BusiestHour, stats.BusiestHourCount)
+	}
+	if stats.AvgResponseTime > 0 {
+		fmt.Printf("   Average response time: %s\n", stats.AvgResponseTime.Round(time.Second))
+	}
+
+	if len(stats.TopContacts) > 0 {
+		header := fmt.Sprintf("\n%-4s %-30s %10s", "#", "Top Contacts", "Messages")
+		printDecoration(colored(header, colorBold, colorCyan))
+		printDecoration(strings.Repeat("-", 50))
+		for i, c := range stats.TopContacts {
+			fmt.Printf("%-4d %s %10d\n", i+1, padRight(truncate(c.Name, 28), 30), c.Count)
+		}
+	}
+	printDecoration("")
+}
+
+func cmdPreview(path string) {
+	// Fit the image to the terminal, leaving a row for the prompt
+	width, height := 80, 24
+	if w, h, err := term.GetSize(int(os.Stdout.Fd())); err == nil {
+		width, height = w, h
+	}
+
+	// Use full-resolution Sixel/iTerm2 output where the terminal supports it
+	render := tui.RenderImageToANSI
+	if tui.DetectImageProtocol() != tui.ProtocolNone {
+		render = tui.RenderImageSixel
+	}
+
+	rendered, err := render(path, width, height-1)
+	if err != nil {
+		printError(err)
+		os.Exit(1)
+	}
+	fmt.Print(rendered)
+}
+
+// setDefaultCountry applies --country, or else the config file's country,
+// to contact matching (see database.SetDefaultCountry).
+func setDefaultCountry(cmd *cobra.Command) error {
+	country, _ := cmd.Flags().GetString("country")
+	source := "--country"
+	if country == "" {
+		cfg, err := config.Load()
+		if err != nil {
+			return err
+		}
+		country, source = cfg.Country, "country in "+config.Path()
+	}
+	if err := database.SetDefaultCountry(country); err != nil {
+		return fmt.Errorf("%s: %w", source, err)
+	}
+	return nil
+}
+
+// tuiColors returns the TUI colors: each --*-color flag wins over the
+// matching config file setting, which wins over tui.DefaultColors.
+func tuiColors(cmd *cobra.Command) (tui.Colors, error) {
+	colors := tui.DefaultColors
+	cfg, err := config.Load()
+	if err != nil {
+		return colors, err
+	}
+	settings := []struct {
+		flag, key, configured string
+		color                 *tcell.Color
+	}{
+		{"me-color", "me_color", cfg.MeColor, &colors.Me},
+		{"them-color", "them_color", cfg.ThemColor, &colors.Them},
+		{"status-color", "status_bar_color", cfg.StatusBarColor, &colors.StatusBar},
+	}
+	for _, s := range settings {
+		name, _ := cmd.Flags().GetString(s.flag)
+		source := "--" + s.flag
+		if name == "" {
+			name, source = s.configured, s.key+" in "+config.Path()
+		}
+		if name == "" {
+			continue
+		}
+		c, err := tui.ParseColor(name)
+		if err != nil {
+			return colors, fmt.Errorf("%s: %w", source, err)
+		}
+		*s.color = c
+	}
+	return colors, nil
+}
+
+// completionLimit is how many recent conversations are offered as completions.
+const completionLimit = 20
+
+// completeConversations completes a conversation argument with the numbers
+// shown by `list` and the identifiers of recent chats.
+func completeConversations(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	conversations, err := database.GetConversations(completionLimit)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	var completions []string
+	for i, conv := range conversations {
+		completions = append(completions, fmt.Sprintf("%d\t%s", i+1, conv.DisplayName))
+		if conv.ChatIdentifier != "" {
+			completions = append(completions, conv.ChatIdentifier+"\t"+conv.DisplayName)
+		}
+	}
+	return completions, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeRecipients completes the recipient of send with the identifiers of
+// recent chats; the message argument isn't completed.
+func completeRecipients(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	conversations, err := database.GetConversations(completionLimit)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	var completions []string
+	for _, conv := range conversations {
+		if conv.ChatIdentifier != "" {
+			completions = append(completions, conv.ChatIdentifier+"\t"+conv.DisplayName)
+		}
+	}
+	return completions, cobra.ShellCompDirectiveNoFileComp
+}
+
+func cmdCompletion(root *cobra.Command, shell string) {
+	var err error
+	switch shell {
+	case "bash":
+		err = root.GenBashCompletionV2(os.Stdout, true)
+	case "zsh":
+		err = root.GenZshCompletion(os.Stdout)
+	case "fish":
+		err = root.GenFishCompletion(os.Stdout, true)
+	}
+	if err != nil {
+		printError(err)
+		os.Exit(1)
+	}
 }
 
 func cmdTUI() {
@@ -0,0 +1,310 @@
+package cli
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/danewalton/imessage-cli/internal/database"
+	"github.com/danewalton/imessage-cli/internal/timefmt"
+)
+
+// exportFormats are the values accepted by export --format.
+var exportFormats = []string{"txt", "html"}
+
+// embeddableImageTypes are the image types browsers display natively; other
+// attachments are listed by name in HTML exports.
+var embeddableImageTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// maxEmbeddedImageBytes keeps a single photo from bloating the HTML file.
+const maxEmbeddedImageBytes = 10 << 20
+
+// exportPageSize is how many messages export reads per query, reporting
+// progress after each.
+const exportPageSize = 1000
+
+// exportOptions are the flags of the export command.
+type exportOptions struct {
+	Format string
+	Output string
+	Limit  int
+	// Location is the time zone message times are written in
+	Location *time.Location
+	// BeforeID and AfterID export one page of history; see
+	// database.MessageOptions
+	BeforeID int64
+	AfterID  int64
+}
+
+func cmdExport(conversation string, opts exportOptions) {
+	identifier, err := resolveChatIdentifier(conversation)
+	if err != nil {
+		printError(err)
+		os.Exit(1)
+	}
+
+	messages, err := loadExportMessages(identifier, opts)
+	if err != nil {
+		printError(err)
+		os.Exit(1)
+	}
+	if len(messages) == 0 {
+		fmt.Println(colored("Error: no messages in that conversation", colorRed))
+		os.Exit(1)
+	}
+	title := exportTitle(identifier)
+
+	out := os.Stdout
+	if opts.Output != "" {
+		f, err := os.Create(opts.Output)
+		if err != nil {
+			printError(err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	w := bufio.NewWriter(out)
+	switch opts.Format {
+	case "html":
+		err = writeHTMLExport(w, title, messages, opts.Location)
+	default:
+		err = writeTextExport(w, title, messages, opts.Location)
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		printError(err)
+		os.Exit(1)
+	}
+
+	if opts.Output != "" {
+		fmt.Println(colored(fmt.Sprintf("✓ Exported %d messages to %s", len(messages), opts.Output), colorGreen))
+	}
+}
+
+// loadExportMessages reads the messages to export, oldest first, a page at
+// a time so long conversations can show progress on stderr. Paging follows
+// ListMessages: backwards from the newest (or from BeforeID), except when
+// paging forward from AfterID alone.
+func loadExportMessages(identifier string, opts exportOptions) ([]database.Message, error) {
+	msgOpts := database.MessageOptions{BeforeID: opts.BeforeID, AfterID: opts.AfterID}
+	total, err := database.CountMessages(0, identifier, msgOpts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Limit > 0 && opts.Limit < total {
+		total = opts.Limit
+	}
+	// Progress is only for someone watching; stderr keeps it out of the
+	// export either way.
+	showProgress := isTerminal() && !quiet && total > exportPageSize
+	defer func() {
+		if showProgress {
+			fmt.Fprint(os.Stderr, "\r\033[K")
+		}
+	}()
+
+	forward := opts.AfterID > 0 && opts.BeforeID == 0
+	var pages [][]database.Message
+	count := 0
+	for count < total {
+		n := min(exportPageSize, total-count)
+		page, err := database.ListMessages(0, identifier, n, msgOpts)
+		if err != nil {
+			return nil, err
+		}
+		if len(page) == 0 {
+			break
+		}
+		pages = append(pages, page)
+		count += len(page)
+		if showProgress {
+			fmt.Fprintf(os.Stderr, "\rExporting %s/%s messages", formatCount(count), formatCount(total))
+		}
+		if forward {
+			msgOpts.AfterID = page[len(page)-1].MessageID
+		} else {
+			msgOpts.BeforeID = page[0].MessageID
+		}
+	}
+
+	messages := make([]database.Message, 0, count)
+	for i := range pages {
+		if !forward {
+			// Backward pages arrive newest first
+			i = len(pages) - 1 - i
+		}
+		messages = append(messages, pages[i]...)
+	}
+	return messages, nil
+}
+
+// exportTitle returns the conversation name used as the export heading.
+func exportTitle(identifier string) string {
+	if conversations, err := database.GetConversations(0); err == nil {
+		for _, conv := range conversations {
+			if conv.ChatIdentifier == identifier && conv.DisplayName != "" {
+				return conv.DisplayName
+			}
+		}
+	}
+	return database.GetContactName(identifier)
+}
+
+// exportTime formats message times in exports, which are read later, so
+// they are always absolute and name their time zone.
+func exportTime(t *time.Time, loc *time.Location) string {
+	if t == nil {
+		return ""
+	}
+	return timefmt.FormatAbsoluteTimeIn(t, loc) + " " + t.In(loc).Format("MST")
+}
+
+// exportText returns the text shown for msg in exports.
+func exportText(msg database.Message) string {
+	text := msg.Text
+	if msg.IsEdited && !msg.IsRetracted {
+		text += " (edited)"
+	}
+	if msg.Effect != "" && !msg.IsRetracted {
+		text += fmt.Sprintf(" [sent with %s]", msg.Effect)
+	}
+	return text
+}
+
+func writeTextExport(w io.Writer, title string, messages []database.Message, loc *time.Location) error {
+	if _, err := fmt.Fprintf(w, "Messages with %s\n\n", title); err != nil {
+		return err
+	}
+	for _, msg := range messages {
+		sender := msg.Sender
+		if msg.IsFromMe {
+			sender = "Me"
+		}
+		line := fmt.Sprintf("[%s] %s: %s", exportTime(msg.Date, loc), sender, exportText(msg))
+		for _, att := range msg.Attachments {
+			line += fmt.Sprintf(" [📎 %s]", att.Filename)
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// htmlMessage is a message prepared for the HTML template.
+type htmlMessage struct {
+	FromMe      bool
+	Sender      string
+	Time        string
+	Text        string
+	Images      []template.URL
+	Attachments []string
+}
+
+func writeHTMLExport(w io.Writer, title string, messages []database.Message, loc *time.Location) error {
+	now := time.Now()
+	data := struct {
+		Title    string
+		Exported string
+		Messages []htmlMessage
+	}{Title: title, Exported: exportTime(&now, loc)}
+
+	for _, msg := range messages {
+		hm := htmlMessage{
+			FromMe: msg.IsFromMe,
+			Sender: msg.Sender,
+			Time:   exportTime(msg.Date, loc),
+		}
+		if msg.Text != "[Attachment]" || len(msg.Attachments) == 0 {
+			hm.Text = exportText(msg)
+		}
+		for _, att := range msg.Attachments {
+			if src, ok := embeddedImage(att); ok {
+				hm.Images = append(hm.Images, src)
+			} else {
+				hm.Attachments = append(hm.Attachments, att.Filename)
+			}
+		}
+		data.Messages = append(data.Messages, hm)
+	}
+
+	return exportTemplate.Execute(w, data)
+}
+
+// embeddedImage returns att as a base64 data URL if browsers can show it.
+func embeddedImage(att database.Attachment) (template.URL, bool) {
+	mime := strings.ToLower(att.MIMEType)
+	if !embeddableImageTypes[mime] || att.TotalBytes > maxEmbeddedImageBytes {
+		return "", false
+	}
+	data, err := os.ReadFile(att.FilePath)
+	if err != nil || len(data) > maxEmbeddedImageBytes {
+		return "", false
+	}
+	// The MIME type comes from the allowlist above, so the URL is safe.
+	return template.URL("data:" + mime + ";base64," + base64.StdEncoding.EncodeToString(data)), true
+}
+
+// exportTemplate renders a conversation as chat bubbles. html/template
+// escapes all message text.
+var exportTemplate = template.Must(template.New("export").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Messages with {{.Title}}</title>
+<style>
+  body { font-family: -apple-system, BlinkMacSystemFont, "Helvetica Neue", sans-serif; background: #fff; margin: 0; }
+  header { padding: 16px; border-bottom: 1px solid #ddd; text-align: center; }
+  header h1 { font-size: 18px; margin: 0; }
+  header p { color: #888; font-size: 12px; margin: 4px 0 0; }
+  main { max-width: 720px; margin: 0 auto; padding: 16px; }
+  .msg { display: flex; flex-direction: column; margin: 8px 0; }
+  .msg.me { align-items: flex-end; }
+  .msg.them { align-items: flex-start; }
+  .meta { color: #888; font-size: 11px; margin: 0 12px 2px; }
+  .bubble { max-width: 70%; padding: 8px 12px; border-radius: 18px; white-space: pre-wrap; word-wrap: break-word; }
+  .me .bubble { background: #0a84ff; color: #fff; }
+  .them .bubble { background: #e9e9eb; color: #000; }
+  .msg img { max-width: 70%; border-radius: 12px; margin-top: 4px; }
+  .attachment { color: #888; font-size: 13px; margin: 2px 12px; }
+</style>
+</head>
+<body>
+<header>
+  <h1>{{.Title}}</h1>
+  <p>Exported {{.Exported}}</p>
+</header>
+<main>
+{{- range .Messages}}
+  <div class="msg {{if .FromMe}}me{{else}}them{{end}}">
+    <div class="meta">{{if not .FromMe}}{{.Sender}} · {{end}}{{.Time}}</div>
+    {{- if .Text}}
+    <div class="bubble">{{.Text}}</div>
+    {{- end}}
+    {{- range .Images}}
+    <img src="{{.}}" alt="">
+    {{- end}}
+    {{- range .Attachments}}
+    <div class="attachment">📎 {{.}}</div>
+    {{- end}}
+  </div>
+{{- end}}
+</main>
+</body>
+</html>
+`))
@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/danewalton/imessage-cli/internal/outbox"
+	"github.com/danewalton/imessage-cli/internal/sender"
+)
+
+func cmdOutbox() {
+	entries, err := outbox.Load()
+	if err != nil {
+		printError(err)
+		os.Exit(1)
+	}
+	if len(entries) == 0 {
+		fmt.Println("The outbox is empty.")
+		return
+	}
+
+	printDecoration(colored(fmt.Sprintf("\n📤 %d unsent message(s)", len(entries)), colorBold, colorCyan))
+	for i, e := range entries {
+		to := e.Recipient
+		if e.From != "" {
+			to += " from " + e.From
+		}
+		fmt.Printf("\n%d. %s %s\n", i+1, colored("To "+to+":", colorBold), truncate(e.Message, 60))
+		fmt.Println(colored(fmt.Sprintf("   Failed %s (%d attempt(s)): %s", formatDate(&e.FailedAt), e.Attempts, truncate(e.Error, 60)), colorDim))
+	}
+	printDecoration(colored("\nRetry with 'imessage outbox flush', or drop them with 'imessage outbox clear'.", colorDim))
+}
+
+func cmdOutboxFlush(delay time.Duration) {
+	// Accounts are looked up once per handle, not once per message
+	accounts := make(map[string]sender.Account)
+	send := func(e outbox.Entry) error {
+		if e.From == "" {
+			return sender.SendMessage(e.Recipient, e.Message)
+		}
+		account, ok := accounts[e.From]
+		if !ok {
+			var err error
+			if account, err = sender.FindAccount(e.From); err != nil {
+				return err
+			}
+			accounts[e.From] = account
+		}
+		return sender.SendMessageFrom(account, e.Recipient, e.Message)
+	}
+
+	sent, remaining, err := outbox.Flush(send, delay)
+	if err != nil {
+		printError(err)
+		os.Exit(1)
+	}
+	if sent == 0 && remaining == 0 {
+		fmt.Println("The outbox is empty.")
+		return
+	}
+	if remaining > 0 {
+		fmt.Println(colored(fmt.Sprintf("Sent %d, %d still failing; see 'imessage outbox'.", sent, remaining), colorYellow, colorBold))
+		os.Exit(1)
+	}
+	fmt.Println(colored(fmt.Sprintf("✓ Sent %d queued message(s)", sent), colorGreen, colorBold))
+}
+
+func cmdOutboxClear() {
+	n, err := outbox.Clear()
+	if err != nil {
+		printError(err)
+		os.Exit(1)
+	}
+	fmt.Printf("Dropped %d queued message(s).\n", n)
+}
@@ -0,0 +1,21 @@
+// Package clipboard copies text to the macOS clipboard.
+package clipboard
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Copy replaces the clipboard contents with text using pbcopy.
+func Copy(text string) error {
+	cmd := exec.Command("pbcopy")
+	cmd.Stdin = strings.NewReader(text)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		if msg := strings.TrimSpace(string(output)); msg != "" {
+			return fmt.Errorf("pbcopy failed: %s", msg)
+		}
+		return fmt.Errorf("pbcopy failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+// Package config loads and saves user settings for the iMessage CLI.
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// FileName is the name of the config file in the user's home directory.
+const FileName = ".imessage-cli.json"
+
+// Config holds persistent user settings.
+type Config struct {
+	// Muted lists chat identifiers whose conversations are hidden with
+	// --hide-muted and don't trigger new-message notifications.
+	Muted []string `json:"muted,omitempty"`
+	// MeColor, ThemColor and StatusBarColor override the TUI's colors for
+	// my messages, everyone else's and the status bar background. Values
+	// are color names ("blue", "darkorange") or hex ("#ff8800").
+	MeColor        string `json:"me_color,omitempty"`
+	ThemColor      string `json:"them_color,omitempty"`
+	StatusBarColor string `json:"status_bar_color,omitempty"`
+	// Country is the country of phone numbers saved without a country
+	// code, as a region ("GB") or calling code ("+44"); --country wins.
+	Country string `json:"country,omitempty"`
+}
+
+// Path returns the location of the config file.
+func Path() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, FileName)
+}
+
+// Load reads the config file. A missing file yields an empty config.
+func Load() (*Config, error) {
+	cfg := &Config{}
+	data, err := os.ReadFile(Path())
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", Path(), err)
+	}
+	return cfg, nil
+}
+
+// Save writes the config file, replacing it atomically.
+func (c *Config) Save() error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := Path()
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	return nil
+}
+
+// IsMuted reports whether the chat identifier is muted.
+func (c *Config) IsMuted(identifier string) bool {
+	for _, m := range c.Muted {
+		if m == identifier {
+			return true
+		}
+	}
+	return false
+}
+
+// Mute adds identifier to the muted list. It returns false if it was
+// already muted.
+func (c *Config) Mute(identifier string) bool {
+	if c.IsMuted(identifier) {
+		return false
+	}
+	c.Muted = append(c.Muted, identifier)
+	return true
+}
+
+// Unmute removes identifier from the muted list. It returns false if it
+// wasn't muted.
+func (c *Config) Unmute(identifier string) bool {
+	for i, m := range c.Muted {
+		if m == identifier {
+			c.Muted = append(c.Muted[:i], c.Muted[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
@@ -3,10 +3,16 @@ package database
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 	"unicode"
 )
 
@@ -17,10 +23,32 @@ var (
 
 // ContactResolver resolves phone numbers and email addresses to contact names.
 type ContactResolver struct {
-	phoneToName map[string]string
+	phoneToName map[string]string // keyed by phoneKey
 	emailToName map[string]string
-	loaded      bool
-	mu          sync.RWMutex
+	// contacts lists each name/identifier pair once, for name lookups
+	contacts []ContactMatch
+	loaded   bool
+	mu       sync.RWMutex
+}
+
+// ContactMatch is a contact name and one of its phone numbers or emails.
+type ContactMatch struct {
+	Name       string
+	Identifier string
+}
+
+// ErrContactNotFound is returned when no contact name matches.
+var ErrContactNotFound = errors.New("no contact matches that name")
+
+// AmbiguousNameError is returned by FindIdentifierByName when several
+// contacts (or several numbers of one contact) match.
+type AmbiguousNameError struct {
+	Name    string
+	Matches []ContactMatch
+}
+
+func (e *AmbiguousNameError) Error() string {
+	return fmt.Sprintf("%d contacts match %q", len(e.Matches), e.Name)
 }
 
 // NewContactResolver creates a new ContactResolver.
@@ -31,7 +59,8 @@ func NewContactResolver() *ContactResolver {
 	}
 }
 
-// GetContactName returns the contact name for a phone number or email.
+// GetContactName returns the contact name for a phone number or email, or
+// the identifier itself (US numbers formatted) if there's no matching contact.
 func GetContactName(identifier string) string {
 	resolverOnce.Do(func() {
 		resolver = NewContactResolver()
@@ -47,6 +76,95 @@ func PreloadContacts() {
 	resolver.loadContacts()
 }
 
+// NoContactsHint explains why names show as numbers when no contacts load,
+// usually because Contacts isn't synced to this Mac.
+const NoContactsHint = "No local contacts found; names will show as numbers. Enable Contacts sync (System Settings > Apple ID > iCloud > Contacts) so they're stored on this Mac."
+
+// ContactCount loads contacts if needed and returns how many phone numbers
+// and emails are known. Zero means every sender shows as a number or email;
+// callers can explain that with NoContactsHint.
+func ContactCount() int {
+	resolverOnce.Do(func() {
+		resolver = NewContactResolver()
+	})
+	return resolver.GetContactCount()
+}
+
+// nameCache remembers the names resolved while scanning one query's rows,
+// so a sender or chat repeated across rows is looked up once.
+type nameCache map[string]string
+
+// name is GetContactName, looking each identifier up once.
+func (nc nameCache) name(identifier string) string {
+	if name, ok := nc[identifier]; ok {
+		return name
+	}
+	name := GetContactName(identifier)
+	nc[identifier] = name
+	return name
+}
+
+// sender is ResolveSender, looking each identifier up once.
+func (nc nameCache) sender(isFromMe bool, senderID string) string {
+	if isFromMe {
+		return "Me"
+	}
+	if senderID != "" {
+		return senderLabel(nc.name(senderID), senderID)
+	}
+	return "Unknown"
+}
+
+// showSenderIdentifiers is set by SetShowSenderIdentifiers.
+var showSenderIdentifiers atomic.Bool
+
+// SetShowSenderIdentifiers makes ResolveSender, and so Message.Sender,
+// append the raw phone number or email to senders that resolved to a
+// contact name, e.g. "Jane Doe (+15551234567)". Senders with no contact
+// already show as their identifier and are left as they are.
+func SetShowSenderIdentifiers(show bool) {
+	showSenderIdentifiers.Store(show)
+}
+
+// senderLabel is how senderID, resolved to name, is shown.
+func senderLabel(name, senderID string) string {
+	if !showSenderIdentifiers.Load() || name == senderID || name == FormatPhoneNumber(senderID) {
+		return name
+	}
+	return fmt.Sprintf("%s (%s)", name, senderID)
+}
+
+// ReloadContacts re-reads the AddressBook so contacts added or renamed
+// since the first lookup resolve. It returns the number of phone numbers
+// and emails now known.
+func ReloadContacts() int {
+	resolverOnce.Do(func() {
+		resolver = NewContactResolver()
+	})
+	resolver.Reload()
+	return resolver.GetContactCount()
+}
+
+// AutoReloadContacts calls ReloadContacts every interval until the returned
+// stop function is called.
+func AutoReloadContacts(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				ReloadContacts()
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
+
 // getAddressBookPaths finds all AddressBook database files on the system.
 func getAddressBookPaths() []string {
 	home, _ := os.UserHomeDir()
@@ -74,7 +192,9 @@ func getAddressBookPaths() []string {
 	return dbFiles
 }
 
-// NormalizePhoneNumber normalizes a phone number to just digits for comparison.
+// NormalizePhoneNumber normalizes a phone number to just digits for
+// comparison, keeping a leading "+". The "(0)" in international numbers
+// like "+44 (0)20 7946 0018" is only dialled at home, so it is dropped.
 func NormalizePhoneNumber(phone string) string {
 	if phone == "" {
 		return ""
@@ -82,27 +202,66 @@ func NormalizePhoneNumber(phone string) string {
 
 	phone = strings.TrimSpace(phone)
 	hasPlus := strings.HasPrefix(phone, "+")
+	if hasPlus {
+		phone = strings.Replace(phone, "(0)", "", 1)
+	}
 
 	var digits strings.Builder
+	digits.Grow(len(phone) + 1)
+	if hasPlus {
+		digits.WriteByte('+')
+	}
 	for _, c := range phone {
 		if unicode.IsDigit(c) {
 			digits.WriteRune(c)
 		}
 	}
 
-	if hasPlus {
-		return "+" + digits.String()
-	}
 	return digits.String()
 }
 
-// GetPhoneVariants generates common variants of a phone number for matching.
+// FormatPhoneNumber formats a US phone number for display, e.g.
+// "+15551234567" becomes "(555) 123-4567". Emails, short codes, group chat
+// identifiers and numbers from other countries are returned unchanged. The
+// result is only for display; send to the raw identifier.
+func FormatPhoneNumber(identifier string) string {
+	var digits strings.Builder
+	for i, c := range identifier {
+		switch {
+		case unicode.IsDigit(c):
+			digits.WriteRune(c)
+		case c == '+' && i == 0, c == ' ', c == '-', c == '(', c == ')', c == '.':
+		default:
+			return identifier
+		}
+	}
+
+	d := digits.String()
+	switch {
+	case len(d) == 11 && d[0] == '1':
+		d = d[1:]
+	case len(d) == 10 && !strings.HasPrefix(identifier, "+"):
+	default:
+		return identifier
+	}
+	return fmt.Sprintf("(%s) %s-%s", d[:3], d[3:6], d[6:])
+}
+
+// GetPhoneVariants generates common variants of a phone number for matching:
+// with and without "+", the US forms with and without the 1, and the
+// international and national forms for the default country (see
+// SetDefaultCountry).
 func GetPhoneVariants(phone string) []string {
 	if phone == "" {
 		return nil
 	}
 
 	variants := []string{phone}
+	add := func(v string) {
+		if !slices.Contains(variants, v) {
+			variants = append(variants, v)
+		}
+	}
 	var digitsBuilder strings.Builder
 	for _, c := range phone {
 		if unicode.IsDigit(c) {
@@ -117,109 +276,199 @@ func GetPhoneVariants(phone string) []string {
 
 	// Add version with + prefix
 	if !strings.HasPrefix(phone, "+") {
-		variants = append(variants, "+"+digits)
+		add("+" + digits)
 	}
 
 	// Handle US phone numbers
 	if len(digits) == 10 {
-		variants = append(variants, "+1"+digits)
-		variants = append(variants, "1"+digits)
+		add("+1" + digits)
+		add("1" + digits)
 	} else if len(digits) == 11 && strings.HasPrefix(digits, "1") {
-		variants = append(variants, digits[1:])
-		variants = append(variants, "+"+digits)
+		add(digits[1:])
+		add("+" + digits)
+	}
+
+	// International form, and the national one in the default country
+	intl := internationalDigits(phone)
+	add("+" + intl)
+	if country := getDefaultCountry(); country.code != "1" {
+		if national, ok := strings.CutPrefix(intl, country.code); ok {
+			add(country.trunk + national)
+		}
 	}
 
 	return variants
 }
 
+// phoneKey reduces a phone number to the form contacts are indexed by, its
+// international digits (see internationalDigits). "+1 (555) 123-4567",
+// "15551234567" and "5551234567" share one key, as do "+44 7700 900123"
+// and, with GB as the default country, "07700 900123", so each number is
+// stored once rather than once per GetPhoneVariants form.
+func phoneKey(phone string) string {
+	return internationalDigits(phone)
+}
+
 // loadContacts loads contacts from all AddressBook databases.
 func (cr *ContactResolver) loadContacts() {
+	// Every lookup passes through here; once loaded, don't contend for the
+	// write lock.
+	cr.mu.RLock()
+	loaded := cr.loaded
+	cr.mu.RUnlock()
+	if loaded {
+		return
+	}
+
 	cr.mu.Lock()
 	defer cr.mu.Unlock()
 
 	if cr.loaded {
 		return
 	}
+
+	fresh := loadAddressBooks()
+	cr.phoneToName = fresh.phoneToName
+	cr.emailToName = fresh.emailToName
+	cr.contacts = fresh.contacts
+	cr.loaded = true
+}
+
+// Reload re-reads every AddressBook database. The new contacts are loaded
+// into fresh maps without holding the lock, so lookups carry on meanwhile,
+// and then swapped in under the write lock: Resolve sees either the old
+// contacts or the new ones, never a partly loaded set.
+func (cr *ContactResolver) Reload() {
+	fresh := loadAddressBooks()
+
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	cr.phoneToName = fresh.phoneToName
+	cr.emailToName = fresh.emailToName
+	cr.contacts = fresh.contacts
 	cr.loaded = true
+}
 
+// loadAddressBooks reads every AddressBook database concurrently and merges
+// them in path order. A number or email found in several sources keeps the
+// name from the first.
+func loadAddressBooks() *ContactResolver {
 	dbPaths := getAddressBookPaths()
-	for _, dbPath := range dbPaths {
-		cr.loadFromDatabase(dbPath)
+	if len(dbPaths) == 0 {
+		logf("contacts: no AddressBook databases found; names will show as numbers")
 	}
+	sources := make([]*ContactResolver, len(dbPaths))
+	var wg sync.WaitGroup
+	for i, dbPath := range dbPaths {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sources[i] = NewContactResolver()
+			sources[i].loadFromDatabase(dbPath)
+		}()
+	}
+	wg.Wait()
+
+	if len(sources) == 1 {
+		return sources[0]
+	}
+	merged := NewContactResolver()
+	for _, src := range sources {
+		for key, name := range src.phoneToName {
+			if _, exists := merged.phoneToName[key]; !exists {
+				merged.phoneToName[key] = name
+			}
+		}
+		for email, name := range src.emailToName {
+			if _, exists := merged.emailToName[email]; !exists {
+				merged.emailToName[email] = name
+			}
+		}
+		merged.contacts = append(merged.contacts, src.contacts...)
+	}
+	return merged
 }
 
-// loadFromDatabase loads contacts from a single AddressBook database.
+// loadFromDatabase loads contacts from a single AddressBook database. It
+// reads one row per contact, with its phone numbers and emails joined by
+// listSeparator, rather than a row per number: the cost of loading is
+// mostly per row and column fetched through cgo, not in SQLite.
 func (cr *ContactResolver) loadFromDatabase(dbPath string) {
 	connStr := "file:" + dbPath + "?mode=ro"
 	db, err := sql.Open("sqlite3", connStr)
 	if err != nil {
+		logf("contacts: %s: %v", dbPath, err)
 		return
 	}
 	defer db.Close()
 
-	// Load phone number to name mappings
 	rows, err := db.Query(`
 		SELECT 
 			r.ZFIRSTNAME,
 			r.ZLASTNAME,
 			r.ZORGANIZATION,
-			p.ZFULLNUMBER
+			(SELECT group_concat(p.ZFULLNUMBER, char(30)) FROM ZABCDPHONENUMBER p
+			 WHERE p.ZOWNER = r.Z_PK AND p.ZFULLNUMBER IS NOT NULL),
+			(SELECT group_concat(e.ZADDRESS, char(30)) FROM ZABCDEMAILADDRESS e
+			 WHERE e.ZOWNER = r.Z_PK AND e.ZADDRESS IS NOT NULL)
 		FROM ZABCDRECORD r
-		JOIN ZABCDPHONENUMBER p ON r.Z_PK = p.ZOWNER
-		WHERE p.ZFULLNUMBER IS NOT NULL
 	`)
-	if err == nil {
-		defer rows.Close()
-		for rows.Next() {
-			var firstName, lastName, organization, phone sql.NullString
-			if err := rows.Scan(&firstName, &lastName, &organization, &phone); err != nil {
-				continue
-			}
+	if err != nil {
+		logf("contacts: %s: %v", dbPath, err)
+		return
+	}
+	defer rows.Close()
 
-			displayName := buildDisplayName(firstName.String, lastName.String, organization.String)
-			if displayName == "" || !phone.Valid {
-				continue
-			}
+	for rows.Next() {
+		var firstName, lastName, organization, phones, emails sql.NullString
+		if err := rows.Scan(&firstName, &lastName, &organization, &phones, &emails); err != nil {
+			logf("contacts: %s: skipping contact: %v", dbPath, err)
+			continue
+		}
+		if !phones.Valid && !emails.Valid {
+			continue
+		}
 
-			normalized := NormalizePhoneNumber(phone.String)
-			if normalized != "" {
-				cr.phoneToName[normalized] = displayName
-				for _, variant := range GetPhoneVariants(normalized) {
-					if _, exists := cr.phoneToName[variant]; !exists {
-						cr.phoneToName[variant] = displayName
-					}
-				}
-			}
+		displayName := buildDisplayName(firstName.String, lastName.String, organization.String)
+		if displayName == "" {
+			continue
 		}
-	}
 
-	// Load email to name mappings
-	rows, err = db.Query(`
-		SELECT 
-			r.ZFIRSTNAME,
-			r.ZLASTNAME,
-			r.ZORGANIZATION,
-			e.ZADDRESS
-		FROM ZABCDRECORD r
-		JOIN ZABCDEMAILADDRESS e ON r.Z_PK = e.ZOWNER
-		WHERE e.ZADDRESS IS NOT NULL
-	`)
-	if err == nil {
-		defer rows.Close()
-		for rows.Next() {
-			var firstName, lastName, organization, email sql.NullString
-			if err := rows.Scan(&firstName, &lastName, &organization, &email); err != nil {
+		for _, phone := range splitList(phones.String) {
+			normalized := NormalizePhoneNumber(phone)
+			key := phoneKey(normalized)
+			if key == "" {
 				continue
 			}
-
-			displayName := buildDisplayName(firstName.String, lastName.String, organization.String)
-			if displayName == "" || !email.Valid {
-				continue
+			if _, exists := cr.phoneToName[key]; !exists {
+				cr.phoneToName[key] = displayName
 			}
-
-			cr.emailToName[strings.ToLower(email.String)] = displayName
+			cr.contacts = append(cr.contacts, ContactMatch{Name: displayName, Identifier: normalized})
 		}
+		for _, email := range splitList(emails.String) {
+			email = strings.ToLower(email)
+			if _, exists := cr.emailToName[email]; !exists {
+				cr.emailToName[email] = displayName
+			}
+			cr.contacts = append(cr.contacts, ContactMatch{Name: displayName, Identifier: email})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		logf("contacts: %s: %v", dbPath, err)
 	}
+	logf("contacts: %s: %d phone numbers, %d emails", dbPath, len(cr.phoneToName), len(cr.emailToName))
+}
+
+// listSeparator joins a contact's numbers and emails in loadFromDatabase's
+// query (the ASCII record separator, which can't appear in either).
+const listSeparator = "\x1e"
+
+// splitList splits a group_concat list from loadFromDatabase.
+func splitList(list string) []string {
+	if list == "" {
+		return nil
+	}
+	return strings.Split(list, listSeparator)
 }
 
 func buildDisplayName(firstName, lastName, organization string) string {
@@ -255,28 +504,101 @@ func (cr *ContactResolver) Resolve(identifier string) string {
 		if name, ok := cr.emailToName[strings.ToLower(identifier)]; ok {
 			return name
 		}
+		logf("contacts: no contact for %s", identifier)
 		return identifier
 	}
 
 	// Try phone number lookup
-	normalized := NormalizePhoneNumber(identifier)
-
-	// Try direct match first
-	if name, ok := cr.phoneToName[normalized]; ok {
+	if name, ok := cr.phoneToName[phoneKey(identifier)]; ok {
 		return name
 	}
 
-	// Try variants
-	for _, variant := range GetPhoneVariants(normalized) {
-		if name, ok := cr.phoneToName[variant]; ok {
-			return name
+	logf("contacts: no contact for %s (looked up as %s)", identifier, phoneKey(identifier))
+	return FormatPhoneNumber(identifier)
+}
+
+// FindByName returns the contacts whose name matches name, ignoring case.
+// Exact matches win over substring matches, which win over fuzzy ones (all
+// letters of name in order, e.g. "jdoe" for "Jane Doe").
+func (cr *ContactResolver) FindByName(name string) []ContactMatch {
+	cr.loadContacts()
+
+	cr.mu.RLock()
+	defer cr.mu.RUnlock()
+
+	query := strings.ToLower(strings.TrimSpace(name))
+	if query == "" {
+		return nil
+	}
+
+	var exact, substring, fuzzy []ContactMatch
+	seen := make(map[ContactMatch]bool)
+	for _, c := range cr.contacts {
+		if seen[c] {
+			continue
+		}
+		seen[c] = true
+
+		lower := strings.ToLower(c.Name)
+		switch {
+		case lower == query:
+			exact = append(exact, c)
+		case strings.Contains(lower, query):
+			substring = append(substring, c)
+		case isSubsequence(query, lower):
+			fuzzy = append(fuzzy, c)
+		}
+	}
+
+	var matches []ContactMatch
+	switch {
+	case len(exact) > 0:
+		matches = exact
+	case len(substring) > 0:
+		matches = substring
+	default:
+		matches = fuzzy
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Name != matches[j].Name {
+			return matches[i].Name < matches[j].Name
+		}
+		return matches[i].Identifier < matches[j].Identifier
+	})
+	return matches
+}
+
+// isSubsequence reports whether all runes of pattern appear in s in order.
+func isSubsequence(pattern, s string) bool {
+	pr := []rune(pattern)
+	pi := 0
+	for _, r := range s {
+		if pi < len(pr) && r == pr[pi] {
+			pi++
 		}
 	}
+	return pi == len(pr)
+}
 
-	return identifier
+// FindIdentifierByName returns the phone number or email of the contact
+// named name, matching as described in FindByName. If several match it
+// returns an *AmbiguousNameError listing them; if none do, ErrContactNotFound.
+func FindIdentifierByName(name string) (string, error) {
+	resolverOnce.Do(func() {
+		resolver = NewContactResolver()
+	})
+	matches := resolver.FindByName(name)
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("%w: %q", ErrContactNotFound, name)
+	case 1:
+		return matches[0].Identifier, nil
+	}
+	return "", &AmbiguousNameError{Name: name, Matches: matches}
 }
 
-// GetContactCount returns the number of loaded contacts.
+// GetContactCount returns the number of distinct phone numbers and emails
+// loaded.
 func (cr *ContactResolver) GetContactCount() int {
 	cr.loadContacts()
 	cr.mu.RLock()
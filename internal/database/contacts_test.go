@@ -0,0 +1,134 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const (
+	// benchContacts is the size of the address book the contact benchmarks
+	// generate, split over two sources.
+	benchContacts = 2000
+	// benchChats and benchChatMessages size BenchmarkGetConversations'
+	// database.
+	benchChats        = 500
+	benchChatMessages = 20_000
+)
+
+// createBenchAddressBook writes AddressBook sources A and B under
+// home/Library/Application Support/AddressBook with n contacts between them.
+// Contact i has two numbers, the first formatted like the bench database's
+// handle i, and an email.
+func createBenchAddressBook(b *testing.B, home string, n int) {
+	b.Helper()
+	for s, source := range []string{"A", "B"} {
+		dir := filepath.Join(home, "Library", "Application Support", "AddressBook", "Sources", source)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			b.Fatal(err)
+		}
+		db, err := sql.Open("sqlite3", filepath.Join(dir, "AddressBook-v22.abcddb"))
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer db.Close()
+		if _, err := db.Exec(`
+			CREATE TABLE ZABCDRECORD (Z_PK INTEGER PRIMARY KEY, ZFIRSTNAME TEXT, ZLASTNAME TEXT, ZORGANIZATION TEXT);
+			CREATE TABLE ZABCDPHONENUMBER (Z_PK INTEGER PRIMARY KEY, ZOWNER INTEGER, ZFULLNUMBER TEXT);
+			CREATE TABLE ZABCDEMAILADDRESS (Z_PK INTEGER PRIMARY KEY, ZOWNER INTEGER, ZADDRESS TEXT);
+			CREATE INDEX phone_owner ON ZABCDPHONENUMBER (ZOWNER);
+			CREATE INDEX email_owner ON ZABCDEMAILADDRESS (ZOWNER);
+		`); err != nil {
+			b.Fatal(err)
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			b.Fatal(err)
+		}
+		for i := s + 1; i <= n; i += 2 {
+			tx.Exec(`INSERT INTO ZABCDRECORD VALUES (?, ?, ?, NULL)`, i, fmt.Sprintf("First%d", i), fmt.Sprintf("Last%d", i))
+			tx.Exec(`INSERT INTO ZABCDPHONENUMBER (ZOWNER, ZFULLNUMBER) VALUES (?, ?)`, i, fmt.Sprintf("+1 (555) %03d-%04d", i/10000, i%10000))
+			tx.Exec(`INSERT INTO ZABCDPHONENUMBER (ZOWNER, ZFULLNUMBER) VALUES (?, ?)`, i, fmt.Sprintf("(666) 555-%04d", i))
+			tx.Exec(`INSERT INTO ZABCDEMAILADDRESS (ZOWNER, ZADDRESS) VALUES (?, ?)`, i, fmt.Sprintf("contact%d@example.com", i))
+		}
+		if err := tx.Commit(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// useBenchContacts points HOME at a generated address book and reloads the
+// shared resolver from it, undoing both when the benchmark ends.
+func useBenchContacts(b *testing.B) {
+	b.Helper()
+	PreloadContacts()
+	b.Cleanup(func() { ReloadContacts() })
+	home := b.TempDir()
+	createBenchAddressBook(b, home, benchContacts)
+	b.Setenv("HOME", home)
+	if got := ReloadContacts(); got != 3*benchContacts {
+		b.Fatalf("loaded %d numbers and emails, want %d", got, 3*benchContacts)
+	}
+}
+
+func BenchmarkLoadContacts(b *testing.B) {
+	useBenchContacts(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		loadAddressBooks()
+	}
+}
+
+func BenchmarkGetConversations(b *testing.B) {
+	useBenchContacts(b)
+	SetDBPath(createBenchDB(b, benchChats, benchChatMessages))
+	defer SetDBPath(testDBPath)
+
+	convs, err := GetConversations(0)
+	if err != nil {
+		b.Fatal(err)
+	}
+	if len(convs) != benchChats || convs[0].DisplayName == convs[0].ChatIdentifier {
+		b.Fatalf("got %d conversations, first named %q", len(convs), convs[0].DisplayName)
+	}
+
+	for _, limit := range []int{50, 0} {
+		b.Run(fmt.Sprintf("limit%d", limit), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := GetConversations(limit); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkResolveSenders resolves the senders of benchChatMessages rows
+// from benchChats handles, as scanMessages does, with and without the
+// per-query nameCache.
+func BenchmarkResolveSenders(b *testing.B) {
+	useBenchContacts(b)
+	senders := make([]string, benchChatMessages)
+	for i := range senders {
+		senders[i] = fmt.Sprintf("+1555%07d", i%benchChats+1)
+	}
+
+	b.Run("GetContactName", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, id := range senders {
+				ResolveSender(false, id)
+			}
+		}
+	})
+	b.Run("nameCache", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			names := make(nameCache)
+			for _, id := range senders {
+				names.sender(false, id)
+			}
+		}
+	})
+}
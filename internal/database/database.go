@@ -3,7 +3,10 @@ package database
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"log"
+	"math"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -12,15 +15,50 @@ import (
 	"time"
 	"unicode"
 
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/mattn/go-sqlite3"
+)
+
+// DefaultBusyTimeout is how long a read waits for Messages to release a
+// lock on the database before failing with "database is locked".
+const DefaultBusyTimeout = 5 * time.Second
+
+var (
+	sharedDB    *sql.DB
+	dbMu        sync.Mutex
+	busyTimeout = DefaultBusyTimeout
 )
 
 var (
-	sharedDB   *sql.DB
-	dbOnce     sync.Once
-	dbInitErr  error
+	logMu  sync.Mutex
+	logger *log.Logger
 )
 
+// SetLogger makes the package log the scan and query errors it otherwise
+// skips over, e.g. rows that no longer match the schema after a macOS
+// update. A nil logger (the default) discards them.
+func SetLogger(l *log.Logger) {
+	logMu.Lock()
+	defer logMu.Unlock()
+	logger = l
+}
+
+// logf writes to the logger set with SetLogger, if any.
+func logf(format string, v ...interface{}) {
+	logMu.Lock()
+	defer logMu.Unlock()
+	if logger != nil {
+		logger.Printf(format, v...)
+	}
+}
+
+// SetBusyTimeout sets how long reads wait on a locked database. It applies
+// to connections opened afterwards, so call it before the first query.
+func SetBusyTimeout(d time.Duration) {
+	dbMu.Lock()
+	defer dbMu.Unlock()
+	busyTimeout = d
+}
+
 // Attachment represents a file attachment on an iMessage.
 type Attachment struct {
 	AttachmentID int64
@@ -32,19 +70,33 @@ type Attachment struct {
 	IsImage      bool
 }
 
+// RetractedText replaces the text of messages the sender unsent.
+const RetractedText = "[Message unsent]"
+
 // Message represents an iMessage.
 type Message struct {
 	MessageID   int64
+	GUID        string
+	ReplyToGUID string // thread originator for inline replies, "" otherwise
 	Text        string
 	Date        *time.Time
 	IsFromMe    bool
 	IsRead      bool
+	IsDelivered bool
+	DateRead    *time.Time
+	DateDeleted *time.Time // moved to Recently Deleted; see GetRecentlyDeletedMessages
+	IsEdited    bool
+	IsRetracted bool   // unsent by the sender; Text is replaced with RetractedText
+	Effect      string // sent with this effect, e.g. "confetti"; see EffectName
 	Service     string
 	Sender      string
 	ChatID      int64
 	ChatIdent   string
 	ChatName    string
 	Attachments []Attachment
+	// AttributedBody is the raw typedstream blob Text may be extracted
+	// from; nil when the database has none for the message.
+	AttributedBody []byte
 }
 
 // Conversation represents a chat/conversation.
@@ -57,65 +109,172 @@ type Conversation struct {
 	LastMessageText string
 	UnreadCount     int
 	Participants    []string
+	IsArchived      bool
+}
+
+// ErrNoFullDiskAccess is returned when the iMessage database exists but macOS
+// won't let this process read it (Privacy & Security → Full Disk Access).
+var ErrNoFullDiskAccess = errors.New("cannot read the iMessage database; grant Full Disk Access to your terminal")
+
+// DBPathEnv overrides the database location, e.g. to read a copied
+// database or a backup.
+const DBPathEnv = "IMESSAGE_DB"
+
+// dbPathOverride is set by SetDBPath and takes precedence over DBPathEnv.
+// Guarded by dbMu.
+var dbPathOverride string
+
+// SetDBPath overrides the database location, e.g. to point the package at a
+// fixture database. An empty path restores the default (DBPathEnv, then
+// ~/Library/Messages/chat.db). If a connection pool is already open it is
+// closed, so the next query opens the new path.
+func SetDBPath(path string) {
+	dbMu.Lock()
+	defer dbMu.Unlock()
+
+	dbPathOverride = path
+	if sharedDB != nil {
+		sharedDB.Close()
+		sharedDB = nil
+		sharedSchema = nil
+	}
 }
 
 // GetDBPath returns the path to the iMessage database.
 func GetDBPath() string {
+	dbMu.Lock()
+	defer dbMu.Unlock()
+	return dbPathLocked()
+}
+
+// dbPathLocked implements GetDBPath; the caller must hold dbMu.
+func dbPathLocked() string {
+	if dbPathOverride != "" {
+		return dbPathOverride
+	}
+	if path := os.Getenv(DBPathEnv); path != "" {
+		return path
+	}
 	home, _ := os.UserHomeDir()
 	return filepath.Join(home, "Library", "Messages", "chat.db")
 }
 
-// initDB initializes the shared database connection pool.
-func initDB() {
-	dbOnce.Do(func() {
-		dbPath := GetDBPath()
-		if _, err := os.Stat(dbPath); os.IsNotExist(err) {
-			dbInitErr = fmt.Errorf("iMessage database not found at %s. Make sure you're running this on macOS with Messages configured", dbPath)
-			return
-		}
+// openDB opens and verifies a new connection pool to the database at dbPath.
+func openDB(dbPath string) (*sql.DB, error) {
+	if err := checkReadable(dbPath); err != nil {
+		return nil, err
+	}
 
-		// Connect in read-only mode with busy timeout to avoid locking issues
-		// _busy_timeout=3000 waits up to 3 seconds if database is locked
-		// _journal_mode=WAL enables write-ahead logging for better concurrent access
-		connStr := fmt.Sprintf("file:%s?mode=ro&_busy_timeout=3000&_journal_mode=WAL", dbPath)
-		db, err := sql.Open("sqlite3", connStr)
-		if err != nil {
-			dbInitErr = err
-			return
-		}
+	// Connect in read-only mode with busy timeout to avoid locking issues
+	// _busy_timeout waits up to busyTimeout if the database is locked
+	// _journal_mode=WAL enables write-ahead logging for better concurrent access
+	connStr := fmt.Sprintf("file:%s?mode=ro&_busy_timeout=%d&_journal_mode=WAL", dbPath, busyTimeout.Milliseconds())
+	driverName := "sqlite3"
+	if logQueries {
+		driverName = queryLogDriverName
+	}
+	db, err := sql.Open(driverName, connStr)
+	if err != nil {
+		return nil, err
+	}
+
+	// Pool settings for a shared long-lived connection
+	db.SetMaxOpenConns(2)
+	db.SetMaxIdleConns(2)
+	db.SetConnMaxLifetime(5 * time.Minute)
+
+	// Verify the connection is usable
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("cannot connect to iMessage database: %w", accessError(err))
+	}
+
+	return db, nil
+}
 
-		// Pool settings for a shared long-lived connection
-		db.SetMaxOpenConns(2)
-		db.SetMaxIdleConns(2)
-		db.SetConnMaxLifetime(5 * time.Minute)
+// checkReadable tells a missing database apart from one macOS won't let us
+// read. Without Full Disk Access, stat or open fail with a permission error
+// even though the file exists.
+func checkReadable(dbPath string) error {
+	f, err := os.Open(dbPath)
+	switch {
+	case err == nil:
+		f.Close()
+		return nil
+	case os.IsNotExist(err):
+		return fmt.Errorf("iMessage database not found at %s. Make sure you're running this on macOS with Messages configured", dbPath)
+	case os.IsPermission(err):
+		return fmt.Errorf("%w (%s)", ErrNoFullDiskAccess, dbPath)
+	}
+	return fmt.Errorf("cannot open iMessage database: %w", err)
+}
 
-		// Verify the connection is usable
-		if err := db.Ping(); err != nil {
-			db.Close()
-			dbInitErr = fmt.Errorf("cannot connect to iMessage database: %w", err)
-			return
+// accessError maps SQLite's permission-style failures to ErrNoFullDiskAccess.
+// Some setups let stat and open succeed and only fail once SQLite reads.
+func accessError(err error) error {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		switch sqliteErr.Code {
+		case sqlite3.ErrCantOpen, sqlite3.ErrPerm, sqlite3.ErrAuth:
+			return fmt.Errorf("%w: %v", ErrNoFullDiskAccess, err)
 		}
+	}
+	return err
+}
 
-		sharedDB = db
-	})
+// CheckAccess verifies that the database can actually be queried, not just
+// found. It returns an error wrapping ErrNoFullDiskAccess when macOS denies
+// the read.
+func CheckAccess() error {
+	db, err := DB()
+	if err != nil {
+		return err
+	}
+	var id int64
+	err = db.QueryRow("SELECT ROWID FROM message LIMIT 1").Scan(&id)
+	if err != nil && err != sql.ErrNoRows {
+		return accessError(err)
+	}
+	return nil
 }
 
 // DB returns the shared database connection pool.
-// The pool is lazily initialized on first call and reused for all subsequent queries.
+// The pool is lazily initialized on first call and reused for all subsequent
+// queries. If initialization fails (e.g. the database is temporarily locked),
+// the next call tries again rather than caching the error.
 func DB() (*sql.DB, error) {
-	initDB()
-	if dbInitErr != nil {
-		return nil, dbInitErr
+	dbMu.Lock()
+	defer dbMu.Unlock()
+
+	if sharedDB != nil {
+		return sharedDB, nil
+	}
+
+	db, err := openDB(dbPathLocked())
+	if err != nil {
+		return nil, err
+	}
+	// Check the schema once per pool so queries can adapt to it.
+	s, err := loadSchema(db)
+	if err != nil {
+		db.Close()
+		return nil, err
 	}
+	sharedDB = db
+	sharedSchema = s
 	return sharedDB, nil
 }
 
 // CloseDB closes the shared database connection pool.
 // Call this during application shutdown for a clean exit.
 func CloseDB() {
+	dbMu.Lock()
+	defer dbMu.Unlock()
+
 	if sharedDB != nil {
 		sharedDB.Close()
 		sharedDB = nil
+		sharedSchema = nil
 	}
 }
 
@@ -125,38 +284,75 @@ func GetConnection() (*sql.DB, error) {
 	return DB()
 }
 
+// appleEpochOffset is the number of seconds between the Unix epoch
+// (1970-01-01) and the Apple epoch (2001-01-01).
+const appleEpochOffset = 978307200
+
+// appleNanosecondThreshold separates second-resolution timestamps from
+// nanosecond-resolution ones. Seconds since 2001 stay below ~1e9 for the
+// foreseeable future, while nanosecond values for any date after early 2001
+// are well above 1e14 (modern values are ~7e17), so anything at or above 1e11
+// (year ~5170 if it were seconds) is treated as nanoseconds.
+const appleNanosecondThreshold = 1e11
+
 // AppleTimeToTime converts Apple's timestamp format to Go time.Time.
-// Apple uses nanoseconds since 2001-01-01, while Unix uses seconds since 1970-01-01.
-// The difference is 978307200 seconds.
+// Older databases store seconds since 2001-01-01, while macOS 10.13 and later
+// store nanoseconds since the same epoch. The resolution is detected from the
+// magnitude of the value. The Unix/Apple epoch difference is 978307200 seconds.
+// The result is in UTC; formatters convert it for display.
 func AppleTimeToTime(appleTime int64) *time.Time {
 	if appleTime == 0 {
 		return nil
 	}
 
-	const appleEpochOffset = 978307200
-
-	var unixTimestamp float64
-	if appleTime > 1e18 {
+	var sec, nsec int64
+	if appleTime >= appleNanosecondThreshold || appleTime <= -appleNanosecondThreshold {
 		// Nanoseconds
-		unixTimestamp = float64(appleTime)/1e9 + appleEpochOffset
-	} else if appleTime > 1e9 {
-		// Already in reasonable range, might be nanoseconds
-		unixTimestamp = float64(appleTime)/1e9 + appleEpochOffset
+		sec = appleTime / 1e9
+		nsec = appleTime % 1e9
 	} else {
-		unixTimestamp = float64(appleTime) + appleEpochOffset
+		// Seconds
+		sec = appleTime
 	}
 
-	t := time.Unix(int64(unixTimestamp), 0)
+	t := time.Unix(sec+appleEpochOffset, nsec).UTC()
 	return &t
 }
 
+// MessageText is the text to show for a message row: its text column, else
+// the text in attributedBody, else "[Attachment]". Inline-attachment markers
+// (U+FFFC) are dropped from the text column as from attributedBody, so a
+// photo whose text is only the marker counts as having none. MMS messages
+// can have a subject, which goes on the line above.
+func MessageText(text string, attributedBody []byte, subject string) string {
+	text = strings.TrimSpace(stripObjectReplacement(text))
+	if text == "" && len(attributedBody) > 0 {
+		text = ExtractTextFromAttributedBody(attributedBody)
+	}
+	switch {
+	case subject != "" && text != "":
+		return subject + "\n" + text
+	case subject != "":
+		return subject
+	case text == "":
+		return "[Attachment]"
+	}
+	return text
+}
+
 // ExtractTextFromAttributedBody extracts plain text from an attributedBody blob.
-// The attributedBody column contains a serialized NSAttributedString.
+// The attributedBody column contains an NSAttributedString serialized as a
+// typedstream. The stream is decoded properly first; the string-splitting
+// heuristics below are only used for blobs the decoder can't handle.
 func ExtractTextFromAttributedBody(data []byte) string {
 	if data == nil || len(data) == 0 {
 		return ""
 	}
 
+	if text, err := decodeTypedstreamText(data); err == nil {
+		return strings.TrimSpace(stripObjectReplacement(text))
+	}
+
 	// Decode as UTF-8, replacing invalid characters
 	decoded := string(data)
 
@@ -217,7 +413,7 @@ func ExtractTextFromAttributedBody(data []byte) string {
 				}
 			}
 			if !hasArtifact && len(strings.TrimSpace(m)) > 2 {
-				filtered = append(filtered, strings.TrimSpace(m))
+				filtered = append(filtered, strings.TrimSpace(stripObjectReplacement(m)))
 			}
 		}
 
@@ -236,9 +432,21 @@ func ExtractTextFromAttributedBody(data []byte) string {
 	return ""
 }
 
+// objectReplacementChar marks where an inline attachment sits in the text.
+// The attachments themselves are listed separately, so the marker is dropped.
+const objectReplacementChar = '\uFFFC'
+
+// stripObjectReplacement removes inline attachment markers from message text.
+func stripObjectReplacement(s string) string {
+	return strings.ReplaceAll(s, string(objectReplacementChar), "")
+}
+
 func cleanPrintable(s string) string {
 	var result strings.Builder
 	for _, r := range s {
+		if r == objectReplacementChar {
+			continue
+		}
 		if unicode.IsPrint(r) || r == '\n' || r == '\t' {
 			result.WriteRune(r)
 		}
@@ -246,37 +454,73 @@ func cleanPrintable(s string) string {
 	return result.String()
 }
 
-// GetConversations retrieves a list of recent conversations.
+// sqlLimit converts a limit into a LIMIT value, treating limit <= 0 as no
+// limit (SQLite reads a negative LIMIT as unbounded).
+func sqlLimit(limit int) int {
+	if limit <= 0 {
+		return -1
+	}
+	return limit
+}
+
+// ConversationOptions controls which conversations ListConversations returns.
+type ConversationOptions struct {
+	// IncludeArchived also returns chats archived in Messages, which are
+	// hidden by default like in the Messages sidebar.
+	IncludeArchived bool
+}
+
+// GetConversations retrieves a list of recent conversations, excluding
+// archived ones. A limit <= 0 returns all of them.
 func GetConversations(limit int) ([]Conversation, error) {
+	return ListConversations(limit, ConversationOptions{})
+}
+
+// ListConversations retrieves recent conversations filtered by opts. A
+// limit <= 0 returns all of them.
+func ListConversations(limit int, opts ConversationOptions) ([]Conversation, error) {
 	db, err := DB()
 	if err != nil {
 		return nil, err
 	}
 
+	isArchived := Column("c", "chat", "is_archived")
+	where := "WHERE COALESCE(" + isArchived + ", 0) = 0"
+	if opts.IncludeArchived {
+		where = ""
+	}
+
 	query := `
 		SELECT 
 			c.ROWID as chat_id,
 			c.chat_identifier,
-			c.display_name,
-			c.service_name,
+			` + Column("c", "chat", "display_name") + `,
+			` + Column("c", "chat", "service_name") + `,
 			MAX(m.date) as last_message_date,
-			GROUP_CONCAT(DISTINCT h.id) as participants
+			GROUP_CONCAT(DISTINCT h.id) as participants,
+			COUNT(DISTINCT CASE WHEN m.is_read = 0 AND m.is_from_me = 0 THEN m.ROWID END) as unread_count,
+			COALESCE(` + isArchived + `, 0) as is_archived
 		FROM chat c
 		LEFT JOIN chat_message_join cmj ON c.ROWID = cmj.chat_id
 		LEFT JOIN message m ON cmj.message_id = m.ROWID
 		LEFT JOIN chat_handle_join chj ON c.ROWID = chj.chat_id
 		LEFT JOIN handle h ON chj.handle_id = h.ROWID
+		` + where + `
 		GROUP BY c.ROWID
 		ORDER BY last_message_date DESC
 		LIMIT ?
 	`
 
-	rows, err := db.Query(query, limit)
+	// Load contacts while the query runs instead of on the first lookup.
+	go PreloadContacts()
+
+	rows, err := db.Query(query, sqlLimit(limit))
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
+	names := make(nameCache)
 	var conversations []Conversation
 	for rows.Next() {
 		var c Conversation
@@ -284,8 +528,9 @@ func GetConversations(limit int) ([]Conversation, error) {
 		var lastMessageDate sql.NullInt64
 		var participants sql.NullString
 
-		err := rows.Scan(&c.ChatID, &chatIdentifier, &displayName, &service, &lastMessageDate, &participants)
+		err := rows.Scan(&c.ChatID, &chatIdentifier, &displayName, &service, &lastMessageDate, &participants, &c.UnreadCount, &c.IsArchived)
 		if err != nil {
+			logf("ListConversations: skipping row: %v", err)
 			continue
 		}
 
@@ -300,215 +545,1003 @@ func GetConversations(limit int) ([]Conversation, error) {
 			c.LastMessageDate = AppleTimeToTime(lastMessageDate.Int64)
 		}
 
-		if participants.Valid && participants.String != "" {
-			c.Participants = strings.Split(participants.String, ",")
-		}
-
-		// Resolve display name from contacts if not set
-		if c.DisplayName == "" {
-			c.DisplayName = GetContactName(c.ChatIdentifier)
-		}
+		c.Participants = splitParticipants(participants.String)
+		c.DisplayName = names.chatName(c.DisplayName, c.ChatIdentifier, c.Participants)
 
 		conversations = append(conversations, c)
 	}
+	if err := rows.Err(); err != nil {
+		logf("ListConversations: %v", err)
+	}
 
 	return conversations, nil
 }
 
-// GetMessages retrieves messages from a specific conversation.
+// Direction restricts messages to the ones sent or received.
+type Direction int
+
+const (
+	// AnyDirection matches every message.
+	AnyDirection Direction = iota
+	// FromMe matches messages sent by the user.
+	FromMe
+	// FromThem matches messages received from others.
+	FromThem
+)
+
+// clause returns the SQL condition on message m for d, or "" for any.
+func (d Direction) clause() string {
+	switch d {
+	case FromMe:
+		return "m.is_from_me = 1"
+	case FromThem:
+		return "m.is_from_me = 0"
+	}
+	return ""
+}
+
+// Includes reports whether a message with the given is_from_me matches d.
+func (d Direction) Includes(isFromMe bool) bool {
+	return d == AnyDirection || (d == FromMe) == isFromMe
+}
+
+// MessageOptions controls which messages ListMessages returns.
+type MessageOptions struct {
+	// Direction keeps only sent or only received messages.
+	Direction Direction
+	// BeforeID and AfterID are message ROWID cursors for paging: only
+	// messages ordered before (or after) that message are returned. Order
+	// is by date with ROWID breaking ties, so messages sharing a timestamp
+	// are neither skipped nor repeated. 0 means no cursor.
+	BeforeID int64
+	AfterID  int64
+}
+
+// GetMessages retrieves messages from a specific conversation. A limit <= 0
+// returns the whole history.
 func GetMessages(chatID int64, chatIdentifier string, limit int) ([]Message, error) {
+	return ListMessages(chatID, chatIdentifier, limit, MessageOptions{})
+}
+
+// ListMessages retrieves messages from a specific conversation filtered by
+// opts. A limit <= 0 returns every match.
+func ListMessages(chatID int64, chatIdentifier string, limit int, opts MessageOptions) ([]Message, error) {
 	db, err := DB()
 	if err != nil {
 		return nil, err
 	}
 
+	whereClause, params, err := messageFilter(chatID, chatIdentifier, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// The limit keeps the newest matches, except when paging forward from
+	// AfterID alone, where it keeps the ones right after the cursor.
+	forward := opts.AfterID > 0 && opts.BeforeID == 0
+	order := "DESC"
+	if forward {
+		order = "ASC"
+	}
+	query := messageSelect() + fmt.Sprintf(`
+		WHERE %s
+		ORDER BY m.date %s, m.ROWID %s
+		LIMIT ?
+	`, whereClause, order, order)
+
+	rows, err := db.Query(query, append(params, sqlLimit(limit))...)
+	if err != nil {
+		return nil, err
+	}
+	messages := scanMessages(rows)
+	rows.Close()
+
+	// Reverse to show oldest first
+	if !forward {
+		reverseMessages(messages)
+	}
+	loadMessageAttachments(messages)
+
+	return messages, nil
+}
+
+// CountMessages returns how many messages a conversation has, filtered by
+// opts like ListMessages, e.g. to show how much a limit leaves out.
+func CountMessages(chatID int64, chatIdentifier string, opts MessageOptions) (int, error) {
+	db, err := DB()
+	if err != nil {
+		return 0, err
+	}
+
+	whereClause, params, err := messageFilter(chatID, chatIdentifier, opts)
+	if err != nil {
+		return 0, err
+	}
+
+	var count int
+	err = db.QueryRow(`
+		SELECT COUNT(*)
+		FROM message m
+		JOIN chat_message_join cmj ON m.ROWID = cmj.message_id
+		JOIN chat c ON cmj.chat_id = c.ROWID
+		WHERE `+whereClause, params...).Scan(&count)
+	return count, err
+}
+
+// messageFilter returns the WHERE condition (on message m and chat c) and
+// its parameters selecting a conversation's messages, by chat ID when given,
+// otherwise by chat identifier.
+func messageFilter(chatID int64, chatIdentifier string, opts MessageOptions) (string, []interface{}, error) {
 	var whereClause string
-	var whereParam interface{}
+	var params []interface{}
 	if chatID > 0 {
 		whereClause = "c.ROWID = ?"
-		whereParam = chatID
+		params = append(params, chatID)
 	} else if chatIdentifier != "" {
 		whereClause = "c.chat_identifier = ?"
-		whereParam = chatIdentifier
+		params = append(params, chatIdentifier)
 	} else {
-		return nil, fmt.Errorf("must provide either chat_id or chat_identifier")
+		return "", nil, fmt.Errorf("must provide either chat_id or chat_identifier")
+	}
+	if clause := opts.Direction.clause(); clause != "" {
+		whereClause += " AND " + clause
+	}
+	// Row values compare (date, ROWID) pairs, matching the ORDER BY
+	if opts.BeforeID > 0 {
+		whereClause += " AND (m.date, m.ROWID) < ((SELECT date FROM message WHERE ROWID = ?), ?)"
+		params = append(params, opts.BeforeID, opts.BeforeID)
+	}
+	if opts.AfterID > 0 {
+		whereClause += " AND (m.date, m.ROWID) > ((SELECT date FROM message WHERE ROWID = ?), ?)"
+		params = append(params, opts.AfterID, opts.AfterID)
 	}
+	return whereClause, params, nil
+}
 
-	query := fmt.Sprintf(`
+// uniqueHandles drops handles that are another form of one already in
+// handles. SMS/MMS chats often join the same number more than once, e.g.
+// "+15551234567" and "5551234567", or once per service.
+func uniqueHandles(handles []string) []string {
+	seen := make(map[string]bool, len(handles))
+	unique := handles[:0]
+	for _, h := range handles {
+		key := strings.ToLower(h)
+		if !strings.Contains(h, "@") {
+			key = phoneKey(h)
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		unique = append(unique, h)
+	}
+	return unique
+}
+
+// splitParticipants turns a comma-separated handle list, as selected by
+// GetConversations or ParticipantsColumn, into unique handles.
+func splitParticipants(list string) []string {
+	if list == "" {
+		return nil
+	}
+	return uniqueHandles(strings.Split(list, ","))
+}
+
+// groupName names an unnamed group chat after its participants, e.g.
+// "Alice Smith, Bob Brown".
+func groupName(participants []string, names nameCache) string {
+	list := make([]string, len(participants))
+	for i, p := range participants {
+		list[i] = names.name(p)
+	}
+	return strings.Join(list, ", ")
+}
+
+// ChatName is the name to show for a chat: displayName if it has one, else
+// for a group its participants' names (see groupName), else the contact name
+// of its identifier. Unnamed groups, which SMS/MMS groups almost always
+// are, would otherwise show as "chat123...". participants is the
+// comma-separated handle list selected by ParticipantsColumn.
+func ChatName(displayName, identifier, participants string) string {
+	return make(nameCache).chatName(displayName, identifier, splitParticipants(participants))
+}
+
+// chatName is ChatName with names looked up in nc.
+func (nc nameCache) chatName(displayName, identifier string, participants []string) string {
+	switch {
+	case displayName != "":
+		return displayName
+	case len(participants) > 1:
+		return groupName(participants, nc)
+	}
+	return nc.name(identifier)
+}
+
+// ParticipantsColumn is the comma-separated handles of chat c, for ChatName,
+// in a query that selects from chat c. It's NULL for chats with a display
+// name, which don't need it.
+func ParticipantsColumn() string {
+	return fmt.Sprintf(`CASE WHEN COALESCE(%s, '') = '' THEN
+			(SELECT GROUP_CONCAT(ph.id) FROM chat_handle_join pj
+			 JOIN handle ph ON pj.handle_id = ph.ROWID
+			 WHERE pj.chat_id = c.ROWID) END`,
+		Column("c", "chat", "display_name"))
+}
+
+// SenderColumn is the handle of message m's sender, in a query that joins
+// chat c and handle h on m.handle_id. Incoming group messages sometimes have
+// no handle_id; they fall back to m.other_handle, then to the chat's
+// participant if it has only one. It's NULL if none of them tell.
+func SenderColumn() string {
+	return fmt.Sprintf(`COALESCE(h.id,
+			(SELECT oh.id FROM handle oh WHERE oh.ROWID = %s),
+			(SELECT MIN(ph.id) FROM chat_handle_join chj
+			 JOIN handle ph ON chj.handle_id = ph.ROWID
+			 WHERE chj.chat_id = c.ROWID HAVING COUNT(*) = 1))`,
+		Column("m", "message", "other_handle"))
+}
+
+// messageSelect returns the column list and joins shared by message
+// queries; rows are read with scanMessages. Columns that vary between macOS
+// versions go through Column.
+func messageSelect() string {
+	return messageSelectFrom(`
+		FROM message m
+		LEFT JOIN chat_message_join cmj ON m.ROWID = cmj.message_id
+		LEFT JOIN chat c ON cmj.chat_id = c.ROWID`)
+}
+
+// messageSelectFrom is messageSelect with from in place of its FROM clause,
+// which must name message m and join chat c.
+func messageSelectFrom(from string) string {
+	return fmt.Sprintf(`
 		SELECT 
 			m.ROWID as message_id,
+			m.guid,
+			%s,
 			m.text,
-			m.attributedBody,
+			%s,
 			m.date,
 			m.is_from_me,
 			m.is_read,
-			m.service,
-			h.id as sender_id,
+			%s,
+			%s,
+			%s,
+			%s,
+			%s,
+			%s,
+			%s,
+			%s as sender_id,
 			c.ROWID as chat_id,
 			c.chat_identifier,
-			c.display_name
-		FROM message m
-		LEFT JOIN chat_message_join cmj ON m.ROWID = cmj.message_id
-		LEFT JOIN chat c ON cmj.chat_id = c.ROWID
-		LEFT JOIN handle h ON m.handle_id = h.ROWID
-		WHERE %s
-		ORDER BY m.date DESC
-		LIMIT ?
-	`, whereClause)
-
-	rows, err := db.Query(query, whereParam, limit)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
+			%s,
+			%s as participants%s
+		LEFT JOIN handle h ON m.handle_id = h.ROWID`,
+		Column("m", "message", "thread_originator_guid"),
+		Column("m", "message", "attributedBody"),
+		"COALESCE("+Column("m", "message", "is_delivered")+", 0)",
+		Column("m", "message", "date_read"),
+		Column("m", "message", "date_edited"),
+		Column("m", "message", "date_retracted"),
+		Column("m", "message", "service"),
+		Column("m", "message", "expressive_send_style_id"),
+		Column("m", "message", "subject"),
+		SenderColumn(),
+		Column("c", "chat", "display_name"),
+		ParticipantsColumn(),
+		from)
+}
 
+// scanMessages reads the rows of a messageSelect query. Rows that fail to
+// scan are skipped.
+func scanMessages(rows *sql.Rows) []Message {
+	names := make(nameCache)
 	var messages []Message
 	for rows.Next() {
 		var m Message
-		var text, senderID, chatIdent, chatName sql.NullString
+		var guid, replyTo, text, senderID, chatIdent, chatName, participants sql.NullString
 		var attributedBody []byte
-		var date sql.NullInt64
-		var isFromMe, isRead int
-		var service sql.NullString
+		var date, dateRead, dateEdited, dateRetracted sql.NullInt64
+		var isFromMe, isRead, isDelivered int
+		var service, styleID, subject sql.NullString
 
-		err := rows.Scan(&m.MessageID, &text, &attributedBody, &date, &isFromMe, &isRead, &service, &senderID, &m.ChatID, &chatIdent, &chatName)
+		err := rows.Scan(&m.MessageID, &guid, &replyTo, &text, &attributedBody, &date, &isFromMe, &isRead, &isDelivered, &dateRead, &dateEdited, &dateRetracted, &service, &styleID, &subject, &senderID, &m.ChatID, &chatIdent, &chatName, &participants)
 		if err != nil {
+			logf("scanMessages: skipping row: %v", err)
 			continue
 		}
 
+		m.GUID = guid.String
+		m.ReplyToGUID = replyTo.String
+		m.AttributedBody = attributedBody
+
 		m.IsFromMe = isFromMe == 1
 		m.IsRead = isRead == 1
+		m.IsDelivered = isDelivered == 1
+		if dateRead.Valid {
+			m.DateRead = AppleTimeToTime(dateRead.Int64)
+		}
 		m.Service = service.String
+		m.Effect = EffectName(styleID.String)
 		m.ChatIdent = chatIdent.String
-		m.ChatName = chatName.String
+		m.ChatName = names.chatName(chatName.String, m.ChatIdent, splitParticipants(participants.String))
 
 		if date.Valid {
 			m.Date = AppleTimeToTime(date.Int64)
 		}
 
-		// Try to get text from the text column first, then fall back to attributedBody
-		m.Text = text.String
-		if m.Text == "" && len(attributedBody) > 0 {
-			m.Text = ExtractTextFromAttributedBody(attributedBody)
-		}
-		if m.Text == "" {
-			m.Text = "[Attachment]"
+		m.Text = MessageText(text.String, attributedBody, subject.String)
+
+		// Edits and unsends are recorded as timestamps (macOS 13+). An unsent
+		// message can still carry its original text, which shouldn't be shown.
+		m.IsEdited = dateEdited.Int64 > 0
+		m.IsRetracted = dateRetracted.Int64 > 0
+		if m.IsRetracted {
+			m.Text = RetractedText
 		}
 
 		// Resolve sender
-		m.Sender = ResolveSender(m.IsFromMe, senderID.String)
-
-		// Resolve chat name
-		if m.ChatName == "" {
-			m.ChatName = GetContactName(m.ChatIdent)
-		}
+		m.Sender = names.sender(m.IsFromMe, senderID.String)
 
 		messages = append(messages, m)
 	}
+	if err := rows.Err(); err != nil {
+		logf("scanMessages: %v", err)
+	}
+	return messages
+}
 
-	// Reverse to show oldest first
+// reverseMessages reverses messages in place.
+func reverseMessages(messages []Message) {
 	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
 		messages[i], messages[j] = messages[j], messages[i]
 	}
+}
 
-	// Batch-load attachments for all messages
-	if len(messages) > 0 {
-		msgIDs := make([]int64, len(messages))
-		for i, m := range messages {
-			msgIDs[i] = m.MessageID
-		}
-		attMap, err := GetAttachmentsForMessages(msgIDs)
-		if err == nil && attMap != nil {
-			for i := range messages {
-				if atts, ok := attMap[messages[i].MessageID]; ok {
-					messages[i].Attachments = atts
-				}
-			}
+// loadMessageAttachments batch-loads attachments for messages.
+func loadMessageAttachments(messages []Message) {
+	if len(messages) == 0 {
+		return
+	}
+	msgIDs := make([]int64, len(messages))
+	for i, m := range messages {
+		msgIDs[i] = m.MessageID
+	}
+	attMap, err := GetAttachmentsForMessages(msgIDs)
+	if err != nil {
+		logf("loading attachments: %v", err)
+		return
+	}
+	for i := range messages {
+		if atts, ok := attMap[messages[i].MessageID]; ok {
+			messages[i].Attachments = atts
 		}
 	}
-
-	return messages, nil
 }
 
-// SearchMessages searches for messages containing the given text.
-func SearchMessages(query string, limit int) ([]Message, error) {
+// GetSurroundingMessages returns up to before messages preceding messageID
+// and up to after messages following it in the same chat, each oldest-first.
+// Messages are ordered by date, with ROWID breaking ties.
+func GetSurroundingMessages(chatID, messageID int64, before, after int) (earlier, later []Message, err error) {
 	db, err := DB()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	sqlQuery := `
-		SELECT 
-			m.ROWID as message_id,
-			m.text,
-			m.attributedBody,
-			m.date,
-			m.is_from_me,
-			c.chat_identifier,
-			c.display_name,
-			h.id as sender_id
-		FROM message m
-		LEFT JOIN chat_message_join cmj ON m.ROWID = cmj.message_id
-		LEFT JOIN chat c ON cmj.chat_id = c.ROWID
-		LEFT JOIN handle h ON m.handle_id = h.ROWID
-		WHERE m.text LIKE ? OR CAST(m.attributedBody AS TEXT) LIKE ?
-		ORDER BY m.date DESC
-		LIMIT ?
-	`
-
-	searchPattern := "%" + query + "%"
-	rows, err := db.Query(sqlQuery, searchPattern, searchPattern, limit)
-	if err != nil {
-		return nil, err
+	var date int64
+	if err := db.QueryRow("SELECT date FROM message WHERE ROWID = ?", messageID).Scan(&date); err != nil {
+		return nil, nil, err
 	}
-	defer rows.Close()
-
-	var results []Message
-	for rows.Next() {
-		var m Message
-		var text, chatIdent, chatName, senderID sql.NullString
-		var attributedBody []byte
-		var date sql.NullInt64
-		var isFromMe int
 
-		err := rows.Scan(&m.MessageID, &text, &attributedBody, &date, &isFromMe, &chatIdent, &chatName, &senderID)
+	if before > 0 {
+		rows, err := db.Query(messageSelect()+`
+		WHERE c.ROWID = ? AND (m.date < ? OR (m.date = ? AND m.ROWID < ?))
+		ORDER BY m.date DESC, m.ROWID DESC
+		LIMIT ?
+	`, chatID, date, date, messageID, before)
 		if err != nil {
-			continue
-		}
-
-		m.IsFromMe = isFromMe == 1
-		m.ChatIdent = chatIdent.String
-		m.ChatName = chatName.String
-
-		if date.Valid {
-			m.Date = AppleTimeToTime(date.Int64)
+			return nil, nil, err
 		}
+		earlier = scanMessages(rows)
+		rows.Close()
+		reverseMessages(earlier)
+		loadMessageAttachments(earlier)
+	}
 
-		m.Text = text.String
-		if m.Text == "" && len(attributedBody) > 0 {
-			m.Text = ExtractTextFromAttributedBody(attributedBody)
-		}
-		if m.Text == "" {
-			m.Text = "[Attachment]"
+	if after > 0 {
+		rows, err := db.Query(messageSelect()+`
+		WHERE c.ROWID = ? AND (m.date > ? OR (m.date = ? AND m.ROWID > ?))
+		ORDER BY m.date ASC, m.ROWID ASC
+		LIMIT ?
+	`, chatID, date, date, messageID, after)
+		if err != nil {
+			return nil, nil, err
 		}
+		later = scanMessages(rows)
+		rows.Close()
+		loadMessageAttachments(later)
+	}
 
-		m.Sender = ResolveSender(m.IsFromMe, senderID.String)
-
-		if m.ChatName == "" {
-			m.ChatName = GetContactName(m.ChatIdent)
-		}
+	return earlier, later, nil
+}
 
-		results = append(results, m)
+// GetRecentMessages returns the newest messages across all conversations,
+// newest first, as one feed. A limit <= 0 returns the whole history.
+func GetRecentMessages(limit int) ([]Message, error) {
+	db, err := DB()
+	if err != nil {
+		return nil, err
 	}
 
-	return results, nil
+	rows, err := db.Query(messageSelect()+`
+		ORDER BY m.date DESC, m.ROWID DESC
+		LIMIT ?
+	`, sqlLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanMessages(rows), nil
 }
 
-// GetUnreadCount returns the count of unread messages.
-func GetUnreadCount() (int, error) {
+// ErrNoRecentlyDeleted is returned by GetRecentlyDeletedMessages when the
+// database predates Recently Deleted (macOS 13).
+var ErrNoRecentlyDeleted = errors.New("this version of Messages has no Recently Deleted (it needs macOS 13 or later)")
+
+// GetRecentlyDeletedMessages returns the messages in Messages' Recently
+// Deleted, most recently deleted first, with DateDeleted set. Messages
+// keeps them about 30 days. Deleted messages are detached from
+// chat_message_join and listed in chat_recoverable_message_join instead, so
+// their chat comes from there. A limit <= 0 returns all of them.
+func GetRecentlyDeletedMessages(limit int) ([]Message, error) {
 	db, err := DB()
 	if err != nil {
-		return 0, err
+		return nil, err
+	}
+	if !hasTable("chat_recoverable_message_join") {
+		return nil, ErrNoRecentlyDeleted
+	}
+
+	rows, err := db.Query(`
+		SELECT message_id, MAX(delete_date) AS deleted
+		FROM chat_recoverable_message_join
+		GROUP BY message_id
+		ORDER BY deleted DESC, message_id DESC
+		LIMIT ?
+	`, sqlLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+	var ids []int64
+	var params []interface{}
+	deleted := make(map[int64]*time.Time)
+	for rows.Next() {
+		var id int64
+		var date sql.NullInt64
+		if err := rows.Scan(&id, &date); err != nil {
+			logf("GetRecentlyDeletedMessages: skipping row: %v", err)
+			continue
+		}
+		ids = append(ids, id)
+		params = append(params, id)
+		deleted[id] = AppleTimeToTime(date.Int64)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+	rows, err = db.Query(messageSelectFrom(`
+		FROM message m
+		JOIN chat_recoverable_message_join r ON m.ROWID = r.message_id
+		LEFT JOIN chat c ON r.chat_id = c.ROWID`)+`
+		WHERE m.ROWID IN (`+placeholders+`)
+	`, params...)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int64]Message, len(ids))
+	for _, m := range scanMessages(rows) {
+		// A message deleted from several chats is listed once
+		if _, ok := byID[m.MessageID]; !ok {
+			m.DateDeleted = deleted[m.MessageID]
+			byID[m.MessageID] = m
+		}
+	}
+	rows.Close()
+
+	messages := make([]Message, 0, len(byID))
+	for _, id := range ids {
+		if m, ok := byID[id]; ok {
+			messages = append(messages, m)
+		}
+	}
+	loadMessageAttachments(messages)
+	return messages, nil
+}
+
+// SearchOptions controls how SearchMessages matches the query.
+type SearchOptions struct {
+	// IgnoreCase matches regardless of case. It is applied in SQL via LIKE
+	// with NOCASE collation, so it costs nothing extra.
+	IgnoreCase bool
+	// WholeWord only matches the query as a complete word. SQL narrows the
+	// candidates by substring and a word-boundary regexp filters them in Go,
+	// which makes it slower than a plain substring search.
+	WholeWord bool
+	// Direction keeps only sent or only received messages.
+	Direction Direction
+	// Attachments also matches messages whose attachment filename contains
+	// the query, and loads the attachments of every result.
+	Attachments bool
+}
+
+// wordBoundaryStart and wordBoundaryEnd delimit a whole-word match: the
+// query must not continue a word of letters, digits, combining marks or
+// underscores in any script.
+const (
+	wordBoundaryStart = `(?:^|[^\pL\pN\pM_])`
+	wordBoundaryEnd   = `(?:$|[^\pL\pN\pM_])`
+)
+
+// likeEscaper escapes LIKE's wildcards, and its escape character, for
+// patterns used with ESCAPE '\'.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// likePattern returns a LIKE pattern matching s anywhere in a string, with
+// "%" and "_" in s matching themselves.
+func likePattern(s string) string {
+	return "%" + likeEscaper.Replace(s) + "%"
+}
+
+// searchMatcher returns the SQL condition (and its parameter) that narrows
+// text-column candidates for query, plus the Go matcher used for decoded
+// attributedBody text and whole-word filtering.
+func searchMatcher(query string, opts SearchOptions) (string, string, func(string) bool, error) {
+	matchClause := "instr(m.text, ?) > 0"
+	matchParam := query
+	if opts.IgnoreCase {
+		matchClause = `m.text LIKE ? COLLATE NOCASE ESCAPE '\'`
+		matchParam = likePattern(query)
+	}
+
+	matches := func(text string) bool {
+		if opts.IgnoreCase {
+			return containsFold(text, query)
+		}
+		return strings.Contains(text, query)
+	}
+	if opts.WholeWord {
+		// Go's \b only knows ASCII word characters, so it never matches
+		// around "é" or CJK text and finds no boundary after "c++".
+		pattern := wordBoundaryStart + regexp.QuoteMeta(query) + wordBoundaryEnd
+		if opts.IgnoreCase {
+			pattern = "(?i)" + pattern
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return "", "", nil, err
+		}
+		matches = re.MatchString
+	}
+	return matchClause, matchParam, matches, nil
+}
+
+// attributedTextExpr is SQL for the bytes of the NSString archived in an
+// attributedBody column, as text: what follows the first "+" (a C string
+// type) in the typedstream, after its 1-, 3- or 5-byte length. The text is
+// cut at the first NUL, which comes after the string.
+func attributedTextExpr(bodyColumn string) string {
+	start := fmt.Sprintf("(instr(%s, X'84012B') + 3)", bodyColumn)
+	return fmt.Sprintf(`CAST(substr(%[1]s, %[2]s +
+			CASE substr(%[1]s, %[2]s, 1) WHEN X'81' THEN 3 WHEN X'82' THEN 5 ELSE 1 END) AS TEXT)`,
+		bodyColumn, start)
+}
+
+// blobMatchClause returns the SQL condition (and its parameter) that narrows
+// attributedBody-only candidates for query, so only blobs that can match are
+// decoded in Go. Decoded text is a byte range of the blob, so a
+// case-sensitive match needs the query's bytes in it; ignoring case, the
+// archived string is matched with LIKE, like the text column. The decoded
+// text still has to match: the blob also holds class names and attributes.
+func blobMatchClause(bodyColumn, query string, opts SearchOptions) (string, interface{}) {
+	if opts.IgnoreCase {
+		return attributedTextExpr(bodyColumn) + ` LIKE ? COLLATE NOCASE ESCAPE '\'`, likePattern(query)
+	}
+	return fmt.Sprintf("instr(%s, ?) > 0", bodyColumn), []byte(query)
+}
+
+// searchPageSize is the fewest candidate IDs searchMessages asks SQLite for
+// at a time, and the most candidates it reads in one query. Candidates
+// that fail the Go check (whole-word misses, hits in a blob's serialization
+// bytes) are replaced from the next page.
+const searchPageSize = 200
+
+// CountSearchMessages returns the number of messages matching query, without
+// fetching or formatting them. Text-column matches are counted in SQL; only
+// attributedBody-only messages (and whole-word candidates) are checked in Go.
+func CountSearchMessages(query string, opts SearchOptions) (int, error) {
+	db, err := DB()
+	if err != nil {
+		return 0, err
+	}
+
+	if opts.Attachments {
+		// Attachment matches overlap text matches, so count distinct results.
+		results, err := searchMessages(query, math.MaxInt, opts, false)
+		return len(results), err
+	}
+
+	matchClause, matchParam, matches, err := searchMatcher(query, opts)
+	if err != nil {
+		return 0, err
+	}
+	bodyColumn := Column("m", "message", "attributedBody")
+	direction := ""
+	if clause := opts.Direction.clause(); clause != "" {
+		direction = " AND " + clause
+		matchClause = "(" + matchClause + ")" + direction
+	}
+
+	var count int
+	if opts.WholeWord {
+		rows, err := db.Query(fmt.Sprintf(`SELECT m.text FROM message m WHERE %s`, matchClause), matchParam)
+		if err != nil {
+			return 0, err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var text string
+			if err := rows.Scan(&text); err == nil && matches(text) {
+				count++
+			}
+		}
+		if err := rows.Err(); err != nil {
+			return 0, err
+		}
+	} else {
+		err = db.QueryRow(fmt.Sprintf(`SELECT COUNT(*) FROM message m WHERE %s`, matchClause), matchParam).Scan(&count)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	blobClause, blobParam := blobMatchClause(bodyColumn, query, opts)
+	rows, err := db.Query(`
+		SELECT `+bodyColumn+`
+		FROM message m
+		WHERE (m.text IS NULL OR m.text = '') AND `+blobClause+direction, blobParam)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var attributedBody []byte
+		if err := rows.Scan(&attributedBody); err != nil {
+			logf("CountSearchMessages: skipping row: %v", err)
+			continue
+		}
+		if matches(ExtractTextFromAttributedBody(attributedBody)) {
+			count++
+		}
+	}
+
+	return count, rows.Err()
+}
+
+// SearchMessages searches for messages containing the given text.
+// Messages with a text column are matched in SQL. Messages whose body only
+// exists in attributedBody are narrowed in SQL (see blobMatchClause), then
+// decoded and matched in Go, so serialization bytes inside the blob never
+// produce false hits. A limit <= 0 returns every match.
+func SearchMessages(query string, limit int, opts SearchOptions) ([]Message, error) {
+	return searchMessages(query, limit, opts, opts.Attachments)
+}
+
+// searchMessages implements SearchMessages; loadAttachments controls whether
+// results get their attachments, which counting doesn't need. Candidates
+// are found in two steps: their IDs, newest first, a page at a time, and
+// then their text and details. ORDER BY evaluates every selected column of
+// every candidate before sorting, so selecting the bodies and senders up
+// front would make searches for common words slow.
+func searchMessages(query string, limit int, opts SearchOptions, loadAttachments bool) ([]Message, error) {
+	db, err := DB()
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = math.MaxInt
+	}
+
+	matchClause, matchParam, matches, err := searchMatcher(query, opts)
+	if err != nil {
+		return nil, err
+	}
+	bodyColumn := Column("m", "message", "attributedBody")
+	blobClause, blobParam := blobMatchClause(bodyColumn, query, opts)
+	direction := ""
+	if clause := opts.Direction.clause(); clause != "" {
+		direction = " AND " + clause
+	}
+
+	// With Attachments, messages whose attachment name matches are collected
+	// up front and accepted without checking their text.
+	withClause := ""
+	attachmentMatch := "0"
+	var withArgs []interface{}
+	if opts.Attachments {
+		nameClause := "instr(a.filename, ?) > 0 OR instr(a.transfer_name, ?) > 0"
+		if opts.IgnoreCase {
+			nameClause = `a.filename LIKE ? COLLATE NOCASE ESCAPE '\' OR a.transfer_name LIKE ? COLLATE NOCASE ESCAPE '\'`
+		}
+		withClause = fmt.Sprintf(`
+		WITH attachment_matches AS (
+			SELECT maj.message_id
+			FROM message_attachment_join maj
+			JOIN attachment a ON maj.attachment_id = a.ROWID
+			WHERE %s
+		)`, nameClause)
+		attachmentMatch = "m.ROWID IN attachment_matches"
+		// Both name conditions take the same parameter as the text one.
+		withArgs = []interface{}{matchParam, matchParam}
+	}
+
+	idQuery := fmt.Sprintf(`%s
+		SELECT m.ROWID
+		FROM message m
+		WHERE (%s
+			OR ((m.text IS NULL OR m.text = '') AND %s)
+			OR %s)%s
+		ORDER BY m.date DESC, m.ROWID DESC
+		LIMIT ? OFFSET ?
+	`, withClause, matchClause, blobClause, attachmentMatch, direction)
+	idArgs := append(withArgs, matchParam, blobParam)
+
+	rowQuery := func(ids int) string {
+		return fmt.Sprintf(`%s
+		SELECT 
+			m.ROWID as message_id,
+			m.guid,
+			m.text,
+			%s,
+			m.date,
+			m.is_from_me,
+			c.ROWID as chat_id,
+			c.chat_identifier,
+			%s,
+			%s as participants,
+			%s as sender_id,
+			%s as attachment_match
+		FROM message m
+		LEFT JOIN chat_message_join cmj ON m.ROWID = cmj.message_id
+		LEFT JOIN chat c ON cmj.chat_id = c.ROWID
+		LEFT JOIN handle h ON m.handle_id = h.ROWID
+		WHERE m.ROWID IN (%s)
+		ORDER BY c.ROWID
+	`, withClause, bodyColumn, Column("c", "chat", "display_name"), ParticipantsColumn(), SenderColumn(), attachmentMatch,
+			strings.TrimSuffix(strings.Repeat("?,", ids), ","))
+	}
+
+	pageSize := max(limit, searchPageSize)
+	if limit == math.MaxInt {
+		pageSize = -1 // every candidate in one page
+	}
+	names := make(nameCache)
+	var results []Message
+	for offset := 0; len(results) < limit; offset += pageSize {
+		ids, err := queryIDs(db, idQuery, append(idArgs, pageSize, offset)...)
+		if err != nil {
+			return nil, err
+		}
+		for start := 0; start < len(ids) && len(results) < limit; start += searchPageSize {
+			chunk := ids[start:min(start+searchPageSize, len(ids))]
+			args := append([]interface{}{}, withArgs...)
+			for _, id := range chunk {
+				args = append(args, id)
+			}
+			page, err := searchRows(db, rowQuery(len(chunk)), args, chunk, limit-len(results), opts, matches, names)
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, page...)
+		}
+		if pageSize < 0 || len(ids) < pageSize {
+			break
+		}
+	}
+
+	if loadAttachments && len(results) > 0 {
+		ids := make([]int64, len(results))
+		for i, m := range results {
+			ids[i] = m.MessageID
+		}
+		if attMap, err := GetAttachmentsForMessages(ids); err == nil {
+			for i := range results {
+				results[i].Attachments = attMap[results[i].MessageID]
+			}
+		}
+	}
+
+	return results, nil
+}
+
+// queryIDs returns the first column of a query's rows.
+func queryIDs(db *sql.DB, query string, args ...interface{}) ([]int64, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// searchRows reads the search candidates ids with query (see
+// searchMessages) and returns, in the order of ids, up to want of them that
+// match. A message in several chats is listed under the first.
+func searchRows(db *sql.DB, query string, args []interface{}, ids []int64, want int, opts SearchOptions, matches func(string) bool, names nameCache) ([]Message, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byID := make(map[int64]Message, len(ids))
+	for rows.Next() {
+		var m Message
+		var guid, text, chatIdent, chatName, participants, senderID sql.NullString
+		var attributedBody []byte
+		var date, chatID sql.NullInt64
+		var isFromMe, attachmentMatched int
+
+		err := rows.Scan(&m.MessageID, &guid, &text, &attributedBody, &date, &isFromMe, &chatID, &chatIdent, &chatName, &participants, &senderID, &attachmentMatched)
+		if err != nil {
+			logf("SearchMessages: skipping row: %v", err)
+			continue
+		}
+		if _, ok := byID[m.MessageID]; ok {
+			continue
+		}
+
+		m.Text = strings.TrimSpace(stripObjectReplacement(text.String))
+		if m.Text == "" {
+			// Blob-only candidate: decode the body before matching.
+			m.Text = ExtractTextFromAttributedBody(attributedBody)
+			if attachmentMatched == 0 && !matches(m.Text) {
+				continue
+			}
+		} else if opts.WholeWord && attachmentMatched == 0 && !matches(m.Text) {
+			continue
+		}
+
+		m.GUID = guid.String
+		m.IsFromMe = isFromMe == 1
+		m.ChatID = chatID.Int64
+		m.ChatIdent = chatIdent.String
+		m.ChatName = names.chatName(chatName.String, m.ChatIdent, splitParticipants(participants.String))
+
+		if date.Valid {
+			m.Date = AppleTimeToTime(date.Int64)
+		}
+
+		m.Sender = names.sender(m.IsFromMe, senderID.String)
+
+		byID[m.MessageID] = m
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var results []Message
+	for _, id := range ids {
+		if m, ok := byID[id]; ok && len(results) < want {
+			results = append(results, m)
+		}
+	}
+	return results, nil
+}
+
+// GetMessageTextByGUID returns the text of the message with the given GUID.
+// It returns sql.ErrNoRows if there is no such message.
+func GetMessageTextByGUID(guid string) (string, error) {
+	db, err := DB()
+	if err != nil {
+		return "", err
+	}
+
+	var text sql.NullString
+	var attributedBody []byte
+	err = db.QueryRow(`SELECT text, `+Column("message", "message", "attributedBody")+` FROM message WHERE guid = ?`, guid).Scan(&text, &attributedBody)
+	if err != nil {
+		return "", err
+	}
+
+	return MessageText(text.String, attributedBody, ""), nil
+}
+
+// GetMessageByID returns the message with the given ROWID, with its
+// attachments, or nil if there is none.
+func GetMessageByID(id int64) (*Message, error) {
+	return getMessage("m.ROWID = ?", id)
+}
+
+// GetMessageByGUID returns the message with the given GUID, with its
+// attachments, or nil if there is none.
+func GetMessageByGUID(guid string) (*Message, error) {
+	return getMessage("m.guid = ?", guid)
+}
+
+// getMessage returns the first message matching whereClause.
+func getMessage(whereClause string, param interface{}) (*Message, error) {
+	db, err := DB()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(messageSelect()+`
+		WHERE `+whereClause+`
+		LIMIT 1`, param)
+	if err != nil {
+		return nil, err
+	}
+	messages := scanMessages(rows)
+	rows.Close()
+	if len(messages) == 0 {
+		return nil, nil
+	}
+	loadMessageAttachments(messages)
+	return &messages[0], nil
+}
+
+// ReplyTexts returns the text of the messages that msgs reply to, keyed by
+// GUID. Originators in msgs are used directly; others are looked up, and any
+// that can't be found are left out.
+func ReplyTexts(msgs []Message) map[string]string {
+	byGUID := make(map[string]string, len(msgs))
+	for _, m := range msgs {
+		byGUID[m.GUID] = m.Text
+	}
+
+	replies := make(map[string]string)
+	for _, m := range msgs {
+		if m.ReplyToGUID == "" {
+			continue
+		}
+		if _, ok := replies[m.ReplyToGUID]; ok {
+			continue
+		}
+		if text, ok := byGUID[m.ReplyToGUID]; ok {
+			replies[m.ReplyToGUID] = text
+			continue
+		}
+		if text, err := GetMessageTextByGUID(m.ReplyToGUID); err == nil {
+			replies[m.ReplyToGUID] = text
+		}
+	}
+	return replies
+}
+
+// containsFold reports whether substr is within s, ignoring case.
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+// GetUnreadCount returns the count of unread messages.
+func GetUnreadCount() (int, error) {
+	db, err := DB()
+	if err != nil {
+		return 0, err
 	}
 
 	var count int
@@ -521,6 +1554,175 @@ func GetUnreadCount() (int, error) {
 	return count, err
 }
 
+// ServiceMessageCount is the number of messages sent over one service.
+type ServiceMessageCount struct {
+	Service string
+	Count   int
+}
+
+// GetServiceCounts returns message counts per service (iMessage, SMS, ...),
+// most used first.
+func GetServiceCounts() ([]ServiceMessageCount, error) {
+	db, err := DB()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(`
+		SELECT COALESCE(NULLIF(service, ''), 'Unknown') as svc, COUNT(*) as count
+		FROM message
+		GROUP BY svc
+		ORDER BY count DESC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []ServiceMessageCount
+	for rows.Next() {
+		var c ServiceMessageCount
+		if err := rows.Scan(&c.Service, &c.Count); err != nil {
+			logf("GetServiceCounts: skipping row: %v", err)
+			continue
+		}
+		counts = append(counts, c)
+	}
+	return counts, rows.Err()
+}
+
+// GetLastMessageDate returns the date of the most recent message in the
+// database, or nil if there are none.
+func GetLastMessageDate() (*time.Time, error) {
+	db, err := DB()
+	if err != nil {
+		return nil, err
+	}
+
+	var date sql.NullInt64
+	if err := db.QueryRow(`SELECT MAX(date) FROM message`).Scan(&date); err != nil {
+		return nil, err
+	}
+	if !date.Valid {
+		return nil, nil
+	}
+	return AppleTimeToTime(date.Int64), nil
+}
+
+// ContactMessageCount is a contact with the number of messages exchanged.
+type ContactMessageCount struct {
+	Identifier string
+	Name       string
+	Count      int
+}
+
+// MessageStats holds aggregate statistics over the whole message history.
+type MessageStats struct {
+	Sent             int
+	Received         int
+	TopContacts      []ContactMessageCount
+	BusiestHour      int // local hour of day (0-23), -1 if there are no messages
+	BusiestHourCount int
+	// AvgResponseTime is the mean delay between an incoming message and my
+	// next reply in the same chat. Zero if I've never replied.
+	AvgResponseTime time.Duration
+}
+
+// appleSecondsExpr converts a message date column to seconds since the Apple
+// epoch, handling both second- and nanosecond-resolution databases the way
+// AppleTimeToTime does.
+var appleSecondsExpr = fmt.Sprintf(`(CASE WHEN m.date >= %d THEN m.date / 1000000000 ELSE m.date END)`,
+	int64(appleNanosecondThreshold))
+
+// GetMessageStats computes sent/received totals, the top 10 contacts by
+// message volume, the busiest hour of day, and the average response time.
+func GetMessageStats() (*MessageStats, error) {
+	db, err := DB()
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &MessageStats{BusiestHour: -1}
+
+	err = db.QueryRow(`
+		SELECT
+			COALESCE(SUM(CASE WHEN is_from_me = 1 THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN is_from_me = 0 THEN 1 ELSE 0 END), 0)
+		FROM message
+	`).Scan(&stats.Sent, &stats.Received)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(`
+		SELECT h.id, COUNT(*) as message_count
+		FROM message m
+		JOIN handle h ON m.handle_id = h.ROWID
+		GROUP BY h.id
+		ORDER BY message_count DESC
+		LIMIT 10
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c ContactMessageCount
+		if err := rows.Scan(&c.Identifier, &c.Count); err != nil {
+			logf("GetMessageStats: skipping row: %v", err)
+			continue
+		}
+		c.Name = GetContactName(c.Identifier)
+		stats.TopContacts = append(stats.TopContacts, c)
+	}
+
+	var hour sql.NullInt64
+	var hourCount sql.NullInt64
+	err = db.QueryRow(fmt.Sprintf(`
+		SELECT
+			CAST(strftime('%%H', %s + %d, 'unixepoch', 'localtime') AS INTEGER) as hour,
+			COUNT(*) as message_count
+		FROM message m
+		WHERE m.date > 0
+		GROUP BY hour
+		ORDER BY message_count DESC
+		LIMIT 1
+	`, appleSecondsExpr, appleEpochOffset)).Scan(&hour, &hourCount)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	if hour.Valid {
+		stats.BusiestHour = int(hour.Int64)
+		stats.BusiestHourCount = int(hourCount.Int64)
+	}
+
+	var avgSeconds sql.NullFloat64
+	err = db.QueryRow(fmt.Sprintf(`
+		SELECT AVG(secs - prev_secs)
+		FROM (
+			SELECT
+				m.is_from_me,
+				%s as secs,
+				LAG(m.is_from_me) OVER w as prev_from_me,
+				LAG(%s) OVER w as prev_secs
+			FROM message m
+			JOIN chat_message_join cmj ON m.ROWID = cmj.message_id
+			WHERE m.date > 0
+			WINDOW w AS (PARTITION BY cmj.chat_id ORDER BY m.date)
+		)
+		WHERE is_from_me = 1 AND prev_from_me = 0
+	`, appleSecondsExpr, appleSecondsExpr)).Scan(&avgSeconds)
+	if err != nil {
+		return nil, err
+	}
+	if avgSeconds.Valid {
+		stats.AvgResponseTime = time.Duration(avgSeconds.Float64 * float64(time.Second))
+	}
+
+	return stats, nil
+}
+
 // GetContactByIdentifier looks up a contact by phone number or email.
 func GetContactByIdentifier(identifier string) (*Conversation, error) {
 	db, err := DB()
@@ -530,6 +1732,14 @@ func GetContactByIdentifier(identifier string) (*Conversation, error) {
 
 	// Normalize identifier
 	normalized := normalizeIdentifier(identifier)
+	// Phone numbers also match in international form, e.g. "07700 900123"
+	// finds "+447700900123" when the default country is GB
+	international := normalized
+	if !strings.Contains(identifier, "@") {
+		if key := phoneKey(identifier); key != "" {
+			international = key
+		}
+	}
 
 	var c Conversation
 	var chatIdent, displayName, service sql.NullString
@@ -543,9 +1753,9 @@ func GetContactByIdentifier(identifier string) (*Conversation, error) {
 		FROM handle h
 		LEFT JOIN chat_handle_join chj ON h.ROWID = chj.handle_id
 		LEFT JOIN chat c ON chj.chat_id = c.ROWID
-		WHERE h.id LIKE ? OR h.id LIKE ?
+		WHERE h.id LIKE ? OR h.id LIKE ? OR h.id LIKE ?
 		LIMIT 1
-	`, "%"+identifier+"%", "%"+normalized+"%").Scan(&chatIdent, &service, &c.ChatIdentifier, &displayName)
+	`, "%"+identifier+"%", "%"+normalized+"%", "%"+international+"%").Scan(&chatIdent, &service, &c.ChatIdentifier, &displayName)
 
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -591,15 +1801,31 @@ func isImageMIME(mime string) bool {
 	return strings.HasPrefix(mime, "image/")
 }
 
-// expandAttachmentPath expands ~ in iMessage attachment paths.
+// expandAttachmentPath turns an attachment path as Messages stores it into
+// an absolute one. Most are "~/Library/Messages/Attachments/...", but some
+// are stored relative to the home directory.
 func expandAttachmentPath(p string) string {
+	if p == "" || filepath.IsAbs(p) {
+		return p
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return p
+	}
 	if strings.HasPrefix(p, "~") {
-		home, err := os.UserHomeDir()
-		if err == nil {
-			p = filepath.Join(home, p[1:])
-		}
+		return filepath.Join(home, p[1:])
 	}
-	return p
+	return filepath.Join(home, p)
+}
+
+// fillAttachment sets att's fields from the attachment table columns.
+func fillAttachment(att *Attachment, filename, mimeType, uti sql.NullString, totalBytes sql.NullInt64) {
+	att.Filename = filepath.Base(filename.String)
+	att.FilePath = expandAttachmentPath(filename.String)
+	att.MIMEType = mimeType.String
+	att.UTI = uti.String
+	att.TotalBytes = totalBytes.Int64
+	att.IsImage = imageUTIs[att.UTI] || isImageMIME(att.MIMEType)
 }
 
 // GetAttachmentsForMessage retrieves attachments for a single message ID.
@@ -632,14 +1858,10 @@ func GetAttachmentsForMessage(messageID int64) ([]Attachment, error) {
 		var totalBytes sql.NullInt64
 
 		if err := rows.Scan(&att.AttachmentID, &filename, &mimeType, &uti, &totalBytes); err != nil {
+			logf("GetAttachmentsForMessage: skipping row: %v", err)
 			continue
 		}
-		att.Filename = filepath.Base(filename.String)
-		att.FilePath = expandAttachmentPath(filename.String)
-		att.MIMEType = mimeType.String
-		att.UTI = uti.String
-		att.TotalBytes = totalBytes.Int64
-		att.IsImage = imageUTIs[att.UTI] || isImageMIME(att.MIMEType)
+		fillAttachment(&att, filename, mimeType, uti, totalBytes)
 
 		attachments = append(attachments, att)
 	}
@@ -693,27 +1915,68 @@ func GetAttachmentsForMessages(messageIDs []int64) (map[int64][]Attachment, erro
 		var totalBytes sql.NullInt64
 
 		if err := rows.Scan(&msgID, &att.AttachmentID, &filename, &mimeType, &uti, &totalBytes); err != nil {
+			logf("GetAttachmentsForMessages: skipping row: %v", err)
 			continue
 		}
-		att.Filename = filepath.Base(filename.String)
-		att.FilePath = expandAttachmentPath(filename.String)
-		att.MIMEType = mimeType.String
-		att.UTI = uti.String
-		att.TotalBytes = totalBytes.Int64
-		att.IsImage = imageUTIs[att.UTI] || isImageMIME(att.MIMEType)
+		fillAttachment(&att, filename, mimeType, uti, totalBytes)
 
 		result[msgID] = append(result[msgID], att)
 	}
 	return result, nil
 }
 
+// GetChatAttachments returns every attachment in a conversation, oldest
+// first. Attachments without a file path (e.g. ones never downloaded) are
+// left out.
+func GetChatAttachments(chatIdentifier string) ([]Attachment, error) {
+	db, err := DB()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(`
+		SELECT
+			a.ROWID,
+			a.filename,
+			a.mime_type,
+			a.uti,
+			a.total_bytes
+		FROM attachment a
+		JOIN message_attachment_join maj ON a.ROWID = maj.attachment_id
+		JOIN message m ON maj.message_id = m.ROWID
+		JOIN chat_message_join cmj ON m.ROWID = cmj.message_id
+		JOIN chat c ON cmj.chat_id = c.ROWID
+		WHERE c.chat_identifier = ? AND a.filename IS NOT NULL AND a.filename != ''
+		ORDER BY m.date ASC, a.ROWID ASC
+	`, chatIdentifier)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attachments []Attachment
+	for rows.Next() {
+		var att Attachment
+		var filename, mimeType, uti sql.NullString
+		var totalBytes sql.NullInt64
+
+		if err := rows.Scan(&att.AttachmentID, &filename, &mimeType, &uti, &totalBytes); err != nil {
+			logf("GetChatAttachments: skipping row: %v", err)
+			continue
+		}
+		fillAttachment(&att, filename, mimeType, uti, totalBytes)
+		attachments = append(attachments, att)
+	}
+	return attachments, rows.Err()
+}
+
 // ResolveSender resolves a sender identifier to a display name.
 func ResolveSender(isFromMe bool, senderID string) string {
 	if isFromMe {
 		return "Me"
 	}
 	if senderID != "" {
-		return GetContactName(senderID)
+		return senderLabel(GetContactName(senderID), senderID)
 	}
 	return "Unknown"
 }
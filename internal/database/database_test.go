@@ -0,0 +1,549 @@
+package database
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fixtureDB is a small chat.db built from testdata/chat.sql.
+const fixtureDB = "testdata/chat.db"
+
+// testDBPath is the copy of fixtureDB the tests read.
+var testDBPath string
+
+func TestMain(m *testing.M) {
+	// Resolve names without the AddressBook of whoever runs the tests, and
+	// read a copy of the fixture: SQLite leaves -shm and -wal files beside
+	// a WAL database.
+	home, err := os.MkdirTemp("", "imessage-test-home")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv("HOME", home)
+	data, err := os.ReadFile(fixtureDB)
+	if err != nil {
+		panic(err)
+	}
+	testDBPath = filepath.Join(home, "chat.db")
+	if err := os.WriteFile(testDBPath, data, 0o644); err != nil {
+		panic(err)
+	}
+	SetDBPath(testDBPath)
+
+	code := m.Run()
+	CloseDB()
+	os.RemoveAll(home)
+	os.Exit(code)
+}
+
+func TestAppleTimeToTime(t *testing.T) {
+	tests := []struct {
+		name string
+		in   int64
+		want time.Time
+	}{
+		{"seconds", 700000000, time.Date(2023, 3, 8, 20, 26, 40, 0, time.UTC)},
+		{"nanoseconds", 700000000123456789, time.Date(2023, 3, 8, 20, 26, 40, 123456789, time.UTC)},
+		{"epoch second", 1, time.Date(2001, 1, 1, 0, 0, 1, 0, time.UTC)},
+		{"negative seconds", -86400, time.Date(2000, 12, 31, 0, 0, 0, 0, time.UTC)},
+		{"negative nanoseconds", -86400_000000000, time.Date(2000, 12, 31, 0, 0, 0, 0, time.UTC)},
+		{"just below threshold is seconds", appleNanosecondThreshold - 1, time.Unix(appleNanosecondThreshold-1+appleEpochOffset, 0).UTC()},
+		{"threshold is nanoseconds", appleNanosecondThreshold, time.Date(2001, 1, 1, 0, 1, 40, 0, time.UTC)},
+		{"negative threshold is nanoseconds", -appleNanosecondThreshold, time.Date(2000, 12, 31, 23, 58, 20, 0, time.UTC)},
+		{"just above negative threshold is seconds", -appleNanosecondThreshold + 1, time.Unix(-appleNanosecondThreshold+1+appleEpochOffset, 0).UTC()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := AppleTimeToTime(tt.in)
+			if got == nil {
+				t.Fatalf("AppleTimeToTime(%d) = nil", tt.in)
+			}
+			if !got.Equal(tt.want) || got.Location() != time.UTC {
+				t.Errorf("AppleTimeToTime(%d) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+
+	if got := AppleTimeToTime(0); got != nil {
+		t.Errorf("AppleTimeToTime(0) = %v, want nil", got)
+	}
+}
+
+func TestAppleSecondsExpr(t *testing.T) {
+	db, err := DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The fixture's dates are in nanoseconds.
+	var first, last int64
+	query := fmt.Sprintf(`SELECT MIN(%s), MAX(%s) FROM message m`, appleSecondsExpr, appleSecondsExpr)
+	if err := db.QueryRow(query).Scan(&first, &last); err != nil {
+		t.Fatal(err)
+	}
+	if first != 700000000 || last != 700000600 {
+		t.Errorf("appleSecondsExpr range = %d..%d, want 700000000..700000600", first, last)
+	}
+
+	// Second-resolution dates pass through.
+	if err := db.QueryRow(fmt.Sprintf(`SELECT %s FROM (SELECT 700000000 AS date) m`, appleSecondsExpr)).Scan(&first); err != nil {
+		t.Fatal(err)
+	}
+	if first != 700000000 {
+		t.Errorf("appleSecondsExpr(700000000) = %d, want 700000000", first)
+	}
+}
+
+func TestGetConversationsOrder(t *testing.T) {
+	convs, err := GetConversations(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Newest last message first; chat 4 is archived.
+	var got []string
+	for _, c := range convs {
+		got = append(got, c.ChatIdentifier)
+	}
+	want := []string{"chat200", "chat100", "+15551234567", "bob@example.com"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetConversations order = %q, want %q", got, want)
+	}
+
+	if convs[1].DisplayName != "Weekend Plans" {
+		t.Errorf("group DisplayName = %q, want %q", convs[1].DisplayName, "Weekend Plans")
+	}
+	if !reflect.DeepEqual(convs[1].Participants, []string{"+15551234567", "bob@example.com"}) {
+		t.Errorf("group Participants = %q", convs[1].Participants)
+	}
+}
+
+func TestGetConversationsSMSGroup(t *testing.T) {
+	convs, err := GetConversations(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := convs[0]
+	if c.ChatIdentifier != "chat200" {
+		t.Fatalf("newest conversation = %q, want the SMS group chat200", c.ChatIdentifier)
+	}
+
+	// "5559876543" is "+15559876543" again, so it's listed once, and the
+	// unnamed group is named after its members rather than "chat200".
+	if c.Service != "SMS" {
+		t.Errorf("Service = %q, want SMS", c.Service)
+	}
+	if want := []string{"+15559876543", "+15551234567"}; !reflect.DeepEqual(c.Participants, want) {
+		t.Errorf("Participants = %q, want %q", c.Participants, want)
+	}
+	if want := "(555) 987-6543, (555) 123-4567"; c.DisplayName != want {
+		t.Errorf("DisplayName = %q, want %q", c.DisplayName, want)
+	}
+	if c.UnreadCount != 1 {
+		t.Errorf("UnreadCount = %d, want 1", c.UnreadCount)
+	}
+}
+
+func TestGetMessagesSMSGroup(t *testing.T) {
+	msgs, err := GetMessages(5, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		id     int64
+		text   string
+		sender string
+	}{
+		{9, "Running 10 min late", "(555) 987-6543"}, // attributedBody only
+		{10, "Save me a seat", "(555) 123-4567"},     // attributedBody only
+		{11, "On my way", "Me"},
+	}
+	if len(msgs) != len(tests) {
+		t.Fatalf("got %d messages, want %d", len(msgs), len(tests))
+	}
+	// The chat has no display name; it's named after its participants, as
+	// in the conversation list, rather than "chat200".
+	const group = "(555) 987-6543, (555) 123-4567"
+	for i, tt := range tests {
+		m := msgs[i]
+		if m.MessageID != tt.id || m.Text != tt.text || m.Sender != tt.sender {
+			t.Errorf("message %d = {%d %q from %q}, want {%d %q from %q}", i, m.MessageID, m.Text, m.Sender, tt.id, tt.text, tt.sender)
+		}
+		if m.ChatName != group {
+			t.Errorf("message %d ChatName = %q, want %q", i, m.ChatName, group)
+		}
+	}
+
+	found, err := SearchMessages("Running", 0, SearchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 1 || found[0].ChatName != group {
+		t.Errorf("SearchMessages(\"Running\") = %+v, want message 9 in %q", found, group)
+	}
+}
+
+func TestChatName(t *testing.T) {
+	tests := []struct {
+		displayName, identifier, participants string
+		want                                  string
+	}{
+		{"Weekend Plans", "chat100", "+15551234567,bob@example.com", "Weekend Plans"},
+		{"", "chat200", "+15559876543,5559876543,+15551234567", "(555) 987-6543, (555) 123-4567"},
+		{"", "+15551234567", "+15551234567", "(555) 123-4567"},
+		{"", "+15551234567", "", "(555) 123-4567"},
+	}
+	for _, tt := range tests {
+		if got := ChatName(tt.displayName, tt.identifier, tt.participants); got != tt.want {
+			t.Errorf("ChatName(%q, %q, %q) = %q, want %q", tt.displayName, tt.identifier, tt.participants, got, tt.want)
+		}
+	}
+}
+
+func TestListConversationsIncludeArchived(t *testing.T) {
+	convs, err := ListConversations(0, ConversationOptions{IncludeArchived: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(convs) != 5 {
+		t.Fatalf("got %d conversations, want 5", len(convs))
+	}
+	if convs[1].ChatIdentifier != "+15557654321" || !convs[1].IsArchived {
+		t.Errorf("second conversation = %q (archived %v), want the archived +15557654321", convs[1].ChatIdentifier, convs[1].IsArchived)
+	}
+}
+
+func TestGetMessagesText(t *testing.T) {
+	msgs, err := GetMessages(1, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		id       int64
+		text     string
+		isFromMe bool
+	}{
+		{1, "Old news", false},
+		{2, "Hello there", false},
+		{3, "Lunch tomorrow?", true},
+		{12, "[Attachment]", false},         // text is just U+FFFC
+		{4, "Sure, see you at noon", false}, // attributedBody only
+		{5, "[Attachment]", true},           // attachment only
+	}
+	if len(msgs) != len(tests) {
+		t.Fatalf("got %d messages, want %d", len(msgs), len(tests))
+	}
+	for i, tt := range tests {
+		m := msgs[i]
+		if m.MessageID != tt.id || m.Text != tt.text || m.IsFromMe != tt.isFromMe {
+			t.Errorf("message %d = {%d %q fromMe=%v}, want {%d %q fromMe=%v}", i, m.MessageID, m.Text, m.IsFromMe, tt.id, tt.text, tt.isFromMe)
+		}
+	}
+
+	if atts := msgs[5].Attachments; len(atts) != 1 || atts[0].Filename != "photo.jpeg" || !atts[0].IsImage {
+		t.Errorf("attachment-only message attachments = %+v", atts)
+	}
+}
+
+func TestGetMessageTextByGUID(t *testing.T) {
+	for guid, want := range map[string]string{
+		"msg-2":  "Hello there",
+		"msg-4":  "Sure, see you at noon",
+		"msg-5":  "[Attachment]",
+		"msg-12": "[Attachment]",
+	} {
+		if got, err := GetMessageTextByGUID(guid); err != nil || got != want {
+			t.Errorf("GetMessageTextByGUID(%q) = %q, %v; want %q", guid, got, err, want)
+		}
+	}
+}
+
+func TestGetMessagesLimit(t *testing.T) {
+	msgs, err := GetMessages(1, "", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The newest two, still oldest first.
+	if len(msgs) != 2 || msgs[0].MessageID != 4 || msgs[1].MessageID != 5 {
+		t.Errorf("GetMessages(limit 2) = %v, want messages 4 and 5", messageIDs(msgs))
+	}
+}
+
+func TestSearchMessages(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		opts  SearchOptions
+		want  []int64
+	}{
+		{"text and attributedBody", "noon", SearchOptions{}, []int64{6, 4}},
+		{"case sensitive", "NOON", SearchOptions{}, nil},
+		{"ignore case", "NOON", SearchOptions{IgnoreCase: true}, []int64{6, 4}},
+		{"attributedBody only", "snacks", SearchOptions{}, []int64{7}},
+		{"serialization bytes don't match", "NSString", SearchOptions{}, nil},
+		{"from me", "Lunch", SearchOptions{Direction: FromMe}, []int64{3}},
+		{"from them", "Lunch", SearchOptions{Direction: FromThem}, nil},
+		{"attachment name", "photo", SearchOptions{Attachments: true}, []int64{5}},
+		{"attachment name ignoring case", "PHOTO", SearchOptions{Attachments: true, IgnoreCase: true}, []int64{5}},
+		{"percent is literal", "%", SearchOptions{IgnoreCase: true}, nil},
+		{"underscore is literal", "_", SearchOptions{IgnoreCase: true, Attachments: true}, []int64{12}}, // IMG_0042.heic
+		{"backslash is literal", `\`, SearchOptions{IgnoreCase: true}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SearchMessages(tt.query, 0, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ids := messageIDs(got); !reflect.DeepEqual(ids, tt.want) {
+				t.Errorf("SearchMessages(%q) = %v, want %v", tt.query, ids, tt.want)
+			}
+
+			count, err := CountSearchMessages(tt.query, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if count != len(tt.want) {
+				t.Errorf("CountSearchMessages(%q) = %d, want %d", tt.query, count, len(tt.want))
+			}
+		})
+	}
+}
+
+func TestSearchMessagesResultFields(t *testing.T) {
+	got, err := SearchMessages("noon", 1, SearchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("got %d results with limit 1", len(got))
+	}
+	m := got[0]
+	if m.Text != "Group meeting at noon" || m.ChatName != "Weekend Plans" || m.Sender != "bob@example.com" {
+		t.Errorf("result = {text %q chat %q sender %q}", m.Text, m.ChatName, m.Sender)
+	}
+}
+
+// messageIDs returns the ROWIDs of msgs, or nil for none.
+func messageIDs(msgs []Message) []int64 {
+	var ids []int64
+	for _, m := range msgs {
+		ids = append(ids, m.MessageID)
+	}
+	return ids
+}
+
+func TestSearchMatcherWholeWord(t *testing.T) {
+	tests := []struct {
+		query, text string
+		ignoreCase  bool
+		want        bool
+	}{
+		{"noon", "see you at noon", false, true},
+		{"noon", "afternoon tea", false, false},
+		{"café", "café au lait", false, true},
+		{"café", "cafés", false, false},
+		{"CAFÉ", "café au lait", true, true},
+		{"naïve", "so naïve!", false, true},
+		{"c++", "c++ rocks", false, true},
+		{"c++", "I like c++", false, true},
+		{"日本語", "日本語 を 話す", false, true},
+		{"日本", "日本語", false, false},
+		{"привет", "Привет, мир", true, true},
+		{"snake", "snake_case", false, false},
+		{"v2", "v2.1 is out", false, true},
+	}
+	for _, tt := range tests {
+		_, _, matches, err := searchMatcher(tt.query, SearchOptions{WholeWord: true, IgnoreCase: tt.ignoreCase})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := matches(tt.text); got != tt.want {
+			t.Errorf("whole word %q in %q (ignore case %v) = %v, want %v", tt.query, tt.text, tt.ignoreCase, got, tt.want)
+		}
+	}
+}
+
+// benchMessages is the size of the database BenchmarkSearchMessages
+// generates.
+const benchMessages = 100_000
+
+// createBenchDB writes a chat.db with n messages spread over the given
+// number of one-to-one chats, in the fixture's schema. Chat and handle i are
+// +1555 followed by i as seven digits. Like recent macOS versions, every message has an
+// attributedBody and one in five has no text column. One in 100 contains
+// "needle", half of those without a text column; "the" is in every message.
+func createBenchDB(b *testing.B, chats, n int) string {
+	b.Helper()
+	schema, err := os.ReadFile(strings.TrimSuffix(fixtureDB, ".db") + ".sql")
+	if err != nil {
+		b.Fatal(err)
+	}
+	var ddl []string
+	for _, line := range strings.Split(string(schema), "\n") {
+		if strings.HasPrefix(line, "CREATE ") || strings.HasPrefix(line, "PRAGMA") {
+			ddl = append(ddl, line)
+		}
+	}
+
+	path := filepath.Join(b.TempDir(), "chat.db")
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(strings.Join(ddl, "\n")); err != nil {
+		b.Fatal(err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		b.Fatal(err)
+	}
+	for i := 1; i <= chats; i++ {
+		tx.Exec(`INSERT INTO handle VALUES (?, ?, 'iMessage')`, i, fmt.Sprintf("+1555%07d", i))
+		tx.Exec(`INSERT INTO chat (ROWID, guid, chat_identifier, service_name) VALUES (?, ?, ?, 'iMessage')`,
+			i, fmt.Sprintf("iMessage;-;+1555%07d", i), fmt.Sprintf("+1555%07d", i))
+		tx.Exec(`INSERT INTO chat_handle_join VALUES (?, ?)`, i, i)
+	}
+	insertMsg, err := tx.Prepare(`INSERT INTO message (ROWID, guid, text, attributedBody, date, is_from_me, handle_id) VALUES (?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		b.Fatal(err)
+	}
+	insertJoin, err := tx.Prepare(`INSERT INTO chat_message_join VALUES (?, ?, ?)`)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for i := 1; i <= n; i++ {
+		text := fmt.Sprintf("message %d about the weekend plans", i)
+		if i%200 == 0 || i%200 == 101 {
+			text = fmt.Sprintf("message %d with the needle in it", i)
+		}
+		var textColumn interface{} = text
+		if i%5 == 0 {
+			textColumn = nil
+		}
+		date := int64(700000000000000000) + int64(i)*1e9
+		chat := i%chats + 1
+		if _, err := insertMsg.Exec(i, fmt.Sprintf("msg-%d", i), textColumn, testAttributedBody(text), date, i%2, chat); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := insertJoin.Exec(chat, i, date); err != nil {
+			b.Fatal(err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		b.Fatal(err)
+	}
+	return path
+}
+
+// likeSearch is the search SearchMessages replaced: LIKE on the text column
+// and on the attributedBody bytes cast to text, decoding the matched blobs.
+func likeSearch(db *sql.DB, query string, limit int) (int, error) {
+	rows, err := db.Query(`
+		SELECT m.ROWID, m.text, m.attributedBody, m.date, m.is_from_me, c.chat_identifier, c.display_name, h.id
+		FROM message m
+		LEFT JOIN chat_message_join cmj ON m.ROWID = cmj.message_id
+		LEFT JOIN chat c ON cmj.chat_id = c.ROWID
+		LEFT JOIN handle h ON m.handle_id = h.ROWID
+		WHERE m.text LIKE ? OR CAST(m.attributedBody AS TEXT) LIKE ?
+		ORDER BY m.date DESC
+		LIMIT ?`, "%"+query+"%", "%"+query+"%", sqlLimit(limit))
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	n := 0
+	for rows.Next() {
+		var id int64
+		var text, chatIdent, chatName, sender sql.NullString
+		var body []byte
+		var date sql.NullInt64
+		var isFromMe int
+		if err := rows.Scan(&id, &text, &body, &date, &isFromMe, &chatIdent, &chatName, &sender); err != nil {
+			return 0, err
+		}
+		if !text.Valid {
+			ExtractTextFromAttributedBody(body)
+		}
+		n++
+	}
+	return n, rows.Err()
+}
+
+func BenchmarkSearchMessages(b *testing.B) {
+	SetDBPath(createBenchDB(b, 100, benchMessages))
+	defer SetDBPath(testDBPath)
+
+	cases := []struct {
+		name  string
+		query string
+		limit int
+		opts  SearchOptions
+	}{
+		{"rare/limit50", "needle", 50, SearchOptions{}},
+		{"rare/all", "needle", 0, SearchOptions{}},
+		{"rare/ignorecase", "NEEDLE", 50, SearchOptions{IgnoreCase: true}},
+		{"rare/word", "needle", 50, SearchOptions{WholeWord: true}},
+		{"common/limit50", "the", 50, SearchOptions{}},
+		{"none", "absent", 50, SearchOptions{}},
+	}
+	for _, c := range cases {
+		b.Run(c.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := SearchMessages(c.query, c.limit, c.opts); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+
+	// The LIKE search, for comparison.
+	db, err := DB()
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, c := range cases {
+		if c.opts != (SearchOptions{}) {
+			continue
+		}
+		b.Run("like/"+c.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := likeSearch(db, c.query, c.limit); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestExtractTextFromAttributedBodyInlineImage(t *testing.T) {
+	// A photo sent with a caption: U+FFFC marks where the image sits.
+	blob, want := readBlob(t, "inline-image")
+	if !bytes.Contains(blob, []byte(string(objectReplacementChar))) {
+		t.Fatal("fixture has no U+FFFC")
+	}
+	if got := ExtractTextFromAttributedBody(blob); got != want {
+		t.Errorf("ExtractTextFromAttributedBody = %q, want %q", got, want)
+	}
+
+	tests := []struct{ in, want string }{
+		{"￼Look at this view", "Look at this view"},
+		{"Before ￼ after", "Before  after"},
+		{"￼￼", ""},
+	}
+	for _, tt := range tests {
+		if got := ExtractTextFromAttributedBody(testAttributedBody(tt.in)); got != tt.want {
+			t.Errorf("ExtractTextFromAttributedBody(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package database
+
+import "strings"
+
+// effectNames maps message.expressive_send_style_id to the effect's name in
+// Messages' "Send with effect" picker. Bubble effects are
+// com.apple.MobileSMS.expressivesend.*, screen effects
+// com.apple.messages.effect.CK*Effect.
+var effectNames = map[string]string{
+	"com.apple.MobileSMS.expressivesend.impact":       "slam",
+	"com.apple.MobileSMS.expressivesend.loud":         "loud",
+	"com.apple.MobileSMS.expressivesend.gentle":       "gentle",
+	"com.apple.MobileSMS.expressivesend.invisibleink": "invisible ink",
+	"com.apple.messages.effect.CKEchoEffect":          "echo",
+	"com.apple.messages.effect.CKSpotlightEffect":     "spotlight",
+	"com.apple.messages.effect.CKHappyBirthdayEffect": "balloons",
+	"com.apple.messages.effect.CKConfettiEffect":      "confetti",
+	"com.apple.messages.effect.CKHeartEffect":         "love",
+	"com.apple.messages.effect.CKLasersEffect":        "lasers",
+	"com.apple.messages.effect.CKFireworksEffect":     "fireworks",
+	"com.apple.messages.effect.CKShootingStarEffect":  "shooting star",
+	"com.apple.messages.effect.CKSparklesEffect":      "celebration",
+}
+
+// EffectName returns the name of the effect a message was sent with, given
+// its expressive_send_style_id, or "" for none. Style IDs newer than this
+// list fall back to the last part of the ID, e.g. "CKRainEffect" → "rain".
+func EffectName(styleID string) string {
+	if styleID == "" {
+		return ""
+	}
+	if name, ok := effectNames[styleID]; ok {
+		return name
+	}
+	name := styleID[strings.LastIndex(styleID, ".")+1:]
+	name = strings.TrimSuffix(strings.TrimPrefix(name, "CK"), "Effect")
+	return strings.ToLower(name)
+}
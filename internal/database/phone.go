@@ -0,0 +1,144 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// callingCountry is how a country's numbers are written: its calling code,
+// and the trunk prefix dialled before national numbers at home ("0" in
+// most of Europe, none in the North American plan).
+type callingCountry struct {
+	code  string
+	trunk string
+}
+
+// countries maps two-letter region codes to their numbering, for
+// SetDefaultCountry.
+var countries = map[string]callingCountry{
+	"US": {"1", ""},
+	"CA": {"1", ""},
+	"GB": {"44", "0"},
+	"UK": {"44", "0"},
+	"IE": {"353", "0"},
+	"DE": {"49", "0"},
+	"AT": {"43", "0"},
+	"CH": {"41", "0"},
+	"FR": {"33", "0"},
+	"BE": {"32", "0"},
+	"NL": {"31", "0"},
+	"SE": {"46", "0"},
+	"FI": {"358", "0"},
+	"IT": {"39", ""},
+	"ES": {"34", ""},
+	"PT": {"351", ""},
+	"DK": {"45", ""},
+	"NO": {"47", ""},
+	"PL": {"48", ""},
+	"AU": {"61", "0"},
+	"NZ": {"64", "0"},
+	"JP": {"81", "0"},
+	"IN": {"91", "0"},
+	"BR": {"55", "0"},
+	"MX": {"52", ""},
+	"ZA": {"27", "0"},
+}
+
+// nationalLengths is how many digits national numbers have in the
+// countries above without a trunk prefix, by calling code. With no prefix
+// to tell them apart, only numbers of this length are taken as national.
+var nationalLengths = map[string]struct{ min, max int }{
+	"39":  {6, 11},  // IT; landlines keep their leading 0
+	"34":  {9, 9},   // ES
+	"351": {9, 9},   // PT
+	"45":  {8, 8},   // DK
+	"47":  {8, 8},   // NO
+	"48":  {9, 9},   // PL
+	"52":  {10, 10}, // MX
+}
+
+var (
+	countryMu      sync.RWMutex
+	defaultCountry = countries["US"]
+)
+
+// SetDefaultCountry sets the country that phone numbers written without a
+// country code belong to, so a contact saved as "07700 900123" matches the
+// handle "+447700900123" with country "GB". country is a two-letter region
+// code or a calling code such as "+44" (numbered like the country above
+// with that code, or else assumed to use the trunk prefix "0"); "" restores
+// the default, US. Contacts are indexed with it, so call it before the
+// first lookup.
+func SetDefaultCountry(country string) error {
+	c, ok := countries[strings.ToUpper(country)]
+	switch {
+	case country == "":
+		c = countries["US"]
+	case ok:
+	default:
+		code := strings.TrimPrefix(country, "+")
+		if code == "" || len(code) > 3 || strings.IndexFunc(code, func(r rune) bool { return r < '0' || r > '9' }) >= 0 {
+			return fmt.Errorf("unknown country %q; use a two-letter code such as GB or a calling code such as +44", country)
+		}
+		c = callingCountry{code: code, trunk: "0"}
+		for _, known := range countries {
+			if known.code == code {
+				c = known
+				break
+			}
+		}
+	}
+
+	countryMu.Lock()
+	defer countryMu.Unlock()
+	defaultCountry = c
+	return nil
+}
+
+// getDefaultCountry returns the country set with SetDefaultCountry.
+func getDefaultCountry() callingCountry {
+	countryMu.RLock()
+	defer countryMu.RUnlock()
+	return defaultCountry
+}
+
+// internationalDigits returns phone in international form without the
+// "+": numbers with "+" or the "00" international prefix keep their country
+// code, and national numbers get the default country's in place of its
+// trunk prefix, or in front of them in countries without one (see
+// nationalLengths). "+44 (0)20 7946 0018" drops the "(0)" written for domestic
+// callers. Numbers it can't place, such as short codes, are returned as
+// digits.
+func internationalDigits(phone string) string {
+	phone = strings.Replace(strings.TrimSpace(phone), "(0)", "", 1)
+	var digits strings.Builder
+	for _, c := range phone {
+		if unicode.IsDigit(c) {
+			digits.WriteRune(c)
+		}
+	}
+	d := digits.String()
+
+	country := getDefaultCountry()
+	switch {
+	case strings.HasPrefix(phone, "+"):
+		return d
+	case strings.HasPrefix(d, "00"):
+		return d[2:]
+	case country.code == "1":
+		// North American numbers are 10 digits, or 11 with the leading 1
+		if len(d) == 10 {
+			return "1" + d
+		}
+		return d
+	case country.trunk != "" && strings.HasPrefix(d, country.trunk):
+		return country.code + d[len(country.trunk):]
+	case country.trunk == "":
+		if l, ok := nationalLengths[country.code]; ok && len(d) >= l.min && len(d) <= l.max {
+			return country.code + d
+		}
+	}
+	return d
+}
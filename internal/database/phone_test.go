@@ -0,0 +1,79 @@
+package database
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestNationalNumbersMatchE164(t *testing.T) {
+	tests := []struct {
+		country  string
+		national string // as saved in Contacts
+		handle   string // as Messages stores it
+		match    bool
+	}{
+		{"GB", "07700 900123", "+447700900123", true},
+		{"GB", "020 7946 0018", "+442079460018", true},
+		{"GB", "+44 (0)20 7946 0018", "+442079460018", true},
+		{"GB", "0044 20 7946 0018", "+442079460018", true},
+		{"GB", "07700 900123", "+497700900123", false},
+		{"GB", "07700 900123", "+447700900124", false},
+		{"DE", "030 1234567", "+49301234567", true},
+		{"DE", "0151 23456789", "+4915123456789", true},
+		{"DE", "+49 (0)151 23456789", "+4915123456789", true},
+		{"DE", "030 1234567", "+44301234567", false},
+		{"IT", "333 123 4567", "+393331234567", true},
+		{"IT", "06 1234 5678", "+390612345678", true},
+		{"IT", "+39 333 123 4567", "+393331234567", true},
+		{"IT", "333 123 4567", "+343331234567", false},
+		{"ES", "612 34 56 78", "+34612345678", true},
+		{"ES", "0034 612 34 56 78", "+34612345678", true},
+		{"ES", "612 34 56 78", "+39612345678", false},
+		{"+39", "333 123 4567", "+393331234567", true},
+		{"", "07700 900123", "+447700900123", false},
+		{"", "(555) 123-4567", "+15551234567", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.country+"/"+tt.national+"/"+tt.handle, func(t *testing.T) {
+			if err := SetDefaultCountry(tt.country); err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { SetDefaultCountry("") })
+
+			national, handle := phoneKey(NormalizePhoneNumber(tt.national)), phoneKey(tt.handle)
+			if (national == handle) != tt.match {
+				t.Errorf("phoneKey(%q) = %q, phoneKey(%q) = %q, want match %v",
+					tt.national, national, tt.handle, handle, tt.match)
+			}
+			if got := slices.Contains(GetPhoneVariants(tt.national), tt.handle); got != tt.match {
+				t.Errorf("GetPhoneVariants(%q) contains %q = %v, want %v",
+					tt.national, tt.handle, got, tt.match)
+			}
+
+			cr := NewContactResolver()
+			cr.loaded = true
+			cr.phoneToName[national] = "Contact"
+			want := "Contact"
+			if !tt.match {
+				want = FormatPhoneNumber(tt.handle)
+			}
+			if got := cr.Resolve(tt.handle); got != want {
+				t.Errorf("Resolve(%q) = %q, want %q", tt.handle, got, want)
+			}
+		})
+	}
+}
+
+func TestSetDefaultCountry(t *testing.T) {
+	t.Cleanup(func() { SetDefaultCountry("") })
+	for _, country := range []string{"GB", "gb", "DE", "+44", "49", ""} {
+		if err := SetDefaultCountry(country); err != nil {
+			t.Errorf("SetDefaultCountry(%q): %v", country, err)
+		}
+	}
+	for _, country := range []string{"XX", "+", "+4444", "4a"} {
+		if err := SetDefaultCountry(country); err == nil {
+			t.Errorf("SetDefaultCountry(%q) succeeded, want an error", country)
+		}
+	}
+}
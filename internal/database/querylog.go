@@ -0,0 +1,65 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"strings"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// queryLogDriverName is the sqlite3 driver with every statement written to
+// the logger, used instead of "sqlite3" after SetLogQueries(true).
+const queryLogDriverName = "sqlite3-querylog"
+
+// logQueries is set by SetLogQueries; guarded by dbMu.
+var logQueries bool
+
+func init() {
+	sql.Register(queryLogDriverName, queryLogDriver{&sqlite3.SQLiteDriver{}})
+}
+
+// SetLogQueries makes the package log the SQL of every query it runs on
+// chat.db to the logger set with SetLogger, for debugging. Like
+// SetBusyTimeout it applies to connections opened afterwards, so call it
+// before the first query.
+func SetLogQueries(enabled bool) {
+	dbMu.Lock()
+	defer dbMu.Unlock()
+	logQueries = enabled
+}
+
+// queryLogDriver wraps the sqlite3 driver so its connections log queries.
+type queryLogDriver struct {
+	driver.Driver
+}
+
+func (d queryLogDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.Driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return queryLogConn{conn}, nil
+}
+
+// queryLogConn hides sqlite3's direct query methods, so database/sql
+// prepares every query through Prepare, where it is logged. That costs an
+// extra call per query, which is why it is opt-in.
+type queryLogConn struct {
+	driver.Conn
+}
+
+func (c queryLogConn) Prepare(query string) (driver.Stmt, error) {
+	logf("query: %s", strings.Join(strings.Fields(query), " "))
+	return c.Conn.Prepare(query)
+}
+
+// Ping passes through to sqlite3; without it database/sql would skip the
+// connection check in openDB.
+func (c queryLogConn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ErrUnsupportedSchema is returned when chat.db lacks a table or column the
+// queries can't do without, e.g. on a macOS release with a reworked schema.
+var ErrUnsupportedSchema = errors.New("unsupported Messages database schema")
+
+// requiredColumns must exist in every supported chat.db. ROWID is implicit
+// and not listed by PRAGMA table_info.
+var requiredColumns = map[string][]string{
+	"message":           {"guid", "text", "date", "is_from_me", "is_read", "handle_id"},
+	"chat":              {"chat_identifier"},
+	"handle":            {"id"},
+	"chat_message_join": {"chat_id", "message_id"},
+	"chat_handle_join":  {"chat_id", "handle_id"},
+}
+
+// optionalColumns were added or renamed across macOS releases. Queries read
+// them through Column, which substitutes NULL when they're missing.
+var optionalColumns = map[string][]string{
+	"message": {
+		"attributedBody",          // macOS 10.13
+		"thread_originator_guid",  // inline replies, macOS 11
+		"associated_message_type", // tapbacks
+		"date_edited",             // macOS 13
+		"date_retracted",          // macOS 13
+		"is_delivered",
+		"date_read",
+		"service",
+		"other_handle",
+		"expressive_send_style_id", // message effects, iOS 10 / macOS 10.12
+		"subject",                  // MMS subject lines
+	},
+	"chat": {"display_name", "service_name", "is_archived"},
+}
+
+// optionalTables exist only on some macOS releases, with the columns the
+// queries need from them. hasTable reports whether the open database has one.
+var optionalTables = map[string][]string{
+	"chat_recoverable_message_join": {"chat_id", "message_id", "delete_date"}, // Recently Deleted, macOS 13
+}
+
+// schema records the columns the open database has, keyed by lower-case
+// "table.column", and the optional tables it has, keyed by table name.
+type schema map[string]bool
+
+// sharedSchema describes the database behind sharedDB; guarded by dbMu.
+var sharedSchema schema
+
+// loadSchema reads the columns of the tables the queries use, failing with
+// ErrUnsupportedSchema if a required one is missing.
+func loadSchema(db *sql.DB) (schema, error) {
+	s := make(schema)
+	for table := range requiredColumns {
+		columns, err := tableColumns(db, table)
+		if err != nil {
+			return nil, err
+		}
+		if len(columns) == 0 {
+			return nil, fmt.Errorf("%w: table %s is missing", ErrUnsupportedSchema, table)
+		}
+		for _, column := range requiredColumns[table] {
+			if !columns[strings.ToLower(column)] {
+				return nil, fmt.Errorf("%w: column %s.%s is missing", ErrUnsupportedSchema, table, column)
+			}
+		}
+		for column := range columns {
+			s[table+"."+column] = true
+		}
+		for _, column := range optionalColumns[table] {
+			if !columns[strings.ToLower(column)] {
+				logf("schema: %s.%s is missing, reading it as NULL", table, column)
+			}
+		}
+	}
+	for table, required := range optionalTables {
+		columns, err := tableColumns(db, table)
+		if err != nil {
+			return nil, err
+		}
+		if len(columns) == 0 {
+			logf("schema: table %s is missing", table)
+			continue
+		}
+		missing := slices.ContainsFunc(required, func(column string) bool {
+			return !columns[column]
+		})
+		if missing {
+			logf("schema: table %s lacks a needed column, ignoring it", table)
+			continue
+		}
+		s[table] = true
+	}
+	return s, nil
+}
+
+// tableColumns returns the lower-case column names of table.
+func tableColumns(db *sql.DB, table string) (map[string]bool, error) {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return nil, accessError(err)
+	}
+	defer rows.Close()
+
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var dflt sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dflt, &pk); err != nil {
+			return nil, err
+		}
+		columns[strings.ToLower(name)] = true
+	}
+	return columns, rows.Err()
+}
+
+// Column returns "alias.column" for use in a query, or NULL if the open
+// database's table doesn't have that column (see optionalColumns). Call it
+// after DB(), which detects the schema; before that every column is assumed
+// present.
+func Column(alias, table, column string) string {
+	dbMu.Lock()
+	defer dbMu.Unlock()
+	if sharedSchema != nil && !sharedSchema[strings.ToLower(table+"."+column)] {
+		return "NULL"
+	}
+	return alias + "." + column
+}
+
+// hasTable reports whether the open database has one of optionalTables.
+// Call it after DB().
+func hasTable(table string) bool {
+	dbMu.Lock()
+	defer dbMu.Unlock()
+	return sharedSchema[table]
+}
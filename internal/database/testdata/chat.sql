@@ -0,0 +1,52 @@
+-- Source of chat.db, a minimal chat.db used by the database tests.
+-- Regenerate it after editing with:
+--   rm -f chat.db && sqlite3 chat.db < chat.sql
+--
+-- attributedBody blobs are NSAttributedString typedstreams in the layout
+-- Messages writes: the NSString with the text, then its attribute run.
+
+-- Like Messages' own database; the package opens it read-only in WAL mode.
+PRAGMA journal_mode = WAL;
+
+CREATE TABLE message (ROWID INTEGER PRIMARY KEY, guid TEXT, text TEXT, attributedBody BLOB, date INTEGER, is_from_me INTEGER DEFAULT 0, is_read INTEGER DEFAULT 0, is_delivered INTEGER DEFAULT 0, date_read INTEGER DEFAULT 0, date_edited INTEGER DEFAULT 0, date_retracted INTEGER DEFAULT 0, service TEXT, handle_id INTEGER, thread_originator_guid TEXT, associated_message_type INTEGER DEFAULT 0, associated_message_guid TEXT, cache_has_attachments INTEGER DEFAULT 0, expressive_send_style_id TEXT, account TEXT, item_type INTEGER DEFAULT 0, is_empty INTEGER DEFAULT 0);
+CREATE TABLE chat (ROWID INTEGER PRIMARY KEY, guid TEXT, chat_identifier TEXT, display_name TEXT, service_name TEXT, is_archived INTEGER DEFAULT 0, account_login TEXT);
+CREATE TABLE chat_message_join (chat_id INTEGER, message_id INTEGER, message_date INTEGER);
+CREATE TABLE handle (ROWID INTEGER PRIMARY KEY, id TEXT, service TEXT);
+CREATE TABLE chat_handle_join (chat_id INTEGER, handle_id INTEGER);
+CREATE TABLE attachment (ROWID INTEGER PRIMARY KEY, filename TEXT, transfer_name TEXT, mime_type TEXT, uti TEXT, total_bytes INTEGER);
+CREATE TABLE message_attachment_join (message_id INTEGER, attachment_id INTEGER);
+CREATE INDEX chat_message_join_idx_message_id_only ON chat_message_join (message_id);
+
+INSERT INTO handle VALUES (1, '+15551234567', 'iMessage'), (2, 'bob@example.com', 'iMessage'), (3, '+15557654321', 'iMessage'),
+  (4, '+15559876543', 'SMS'), (5, '5559876543', 'SMS'), (6, '+15551234567', 'SMS');
+
+-- 1: one-to-one; 2: named group; 3: one-to-one with an email; 4: archived;
+-- 5: unnamed SMS/MMS group, whose handles include one number in two forms
+INSERT INTO chat (ROWID, guid, chat_identifier, display_name, service_name, is_archived) VALUES
+  (1, 'iMessage;-;+15551234567', '+15551234567', '', 'iMessage', 0),
+  (2, 'iMessage;+;chat100', 'chat100', 'Weekend Plans', 'iMessage', 0),
+  (3, 'iMessage;-;bob@example.com', 'bob@example.com', '', 'iMessage', 0),
+  (4, 'iMessage;-;+15557654321', '+15557654321', '', 'iMessage', 1),
+  (5, 'SMS;+;chat200', 'chat200', NULL, 'SMS', 0);
+INSERT INTO chat_handle_join VALUES (1, 1), (2, 1), (2, 2), (3, 2), (4, 3), (5, 4), (5, 5), (5, 6);
+
+-- 4, 7, 9 and 10 only have an attributedBody; 5 is attachment-only; 12 is
+-- a photo whose text column is just the U+FFFC marking where it sits
+INSERT INTO message (ROWID, guid, text, attributedBody, date, is_from_me, is_read, service, handle_id, cache_has_attachments) VALUES
+  (1, 'msg-1', 'Old news', NULL, 700000000000000000, 0, 1, 'iMessage', 2, 0),
+  (2, 'msg-2', 'Hello there', NULL, 700000060000000000, 0, 1, 'iMessage', 1, 0),
+  (3, 'msg-3', 'Lunch tomorrow?', NULL, 700000120000000000, 1, 1, 'iMessage', 0, 0),
+  (4, 'msg-4', NULL, X'040b73747265616d747970656481e803840140848484124e5341747472696275746564537472696e67008484084e534f626a656374008592848484084e53537472696e67019484012b15537572652c2073656520796f75206174206e6f6f6e86840269490115928484840c4e5344696374696f6e617279009484016901928496961d5f5f6b494d4d657373616765506172744174747269627574654e616d658692848484084e534e756d626572008484074e5356616c7565009484012a84999900868686', 700000180000000000, 0, 1, 'iMessage', 1, 0),
+  (5, 'msg-5', NULL, NULL, 700000240000000000, 1, 1, 'iMessage', 0, 1),
+  (6, 'msg-6', 'Group meeting at noon', NULL, 700000300000000000, 0, 1, 'iMessage', 2, 0),
+  (7, 'msg-7', NULL, X'040b73747265616d747970656481e803840140848484124e5341747472696275746564537472696e67008484084e534f626a656374008592848484084e53537472696e67019484012b0c4272696e6720736e61636b738684026949010c928484840c4e5344696374696f6e617279009484016901928496961d5f5f6b494d4d657373616765506172744174747269627574654e616d658692848484084e534e756d626572008484074e5356616c7565009484012a84999900868686', 700000360000000000, 0, 1, 'iMessage', 1, 0),
+  (8, 'msg-8', 'Archived hello', NULL, 700000420000000000, 0, 1, 'iMessage', 3, 0),
+  (9, 'msg-9', NULL, X'040b73747265616d747970656481e803840140848484124e5341747472696275746564537472696e67008484084e534f626a656374008592848484084e53537472696e67019484012b1352756e6e696e67203130206d696e206c61746586840269490113928484840c4e5344696374696f6e617279009484016901928496961d5f5f6b494d4d657373616765506172744174747269627574654e616d658692848484084e534e756d626572008484074e5356616c7565009484012a84999900868686', 700000480000000000, 0, 1, 'SMS', 4, 0),
+  (10, 'msg-10', NULL, X'040b73747265616d747970656481e803840140848484124e5341747472696275746564537472696e67008484084e534f626a656374008592848484084e53537472696e67019484012b0e53617665206d65206120736561748684026949010e928484840c4e5344696374696f6e617279009484016901928496961d5f5f6b494d4d657373616765506172744174747269627574654e616d658692848484084e534e756d626572008484074e5356616c7565009484012a84999900868686', 700000540000000000, 0, 0, 'SMS', 6, 0),
+  (11, 'msg-11', 'On my way', NULL, 700000600000000000, 1, 1, 'SMS', 0, 0),
+  (12, 'msg-12', char(65532), NULL, 700000150000000000, 0, 1, 'iMessage', 1, 1);
+INSERT INTO chat_message_join VALUES (1, 1, 700000000000000000), (1, 2, 700000060000000000), (1, 3, 700000120000000000), (1, 12, 700000150000000000), (1, 4, 700000180000000000), (1, 5, 700000240000000000), (2, 6, 700000300000000000), (2, 7, 700000360000000000), (3, 1, 700000000000000000), (4, 8, 700000420000000000), (5, 9, 700000480000000000), (5, 10, 700000540000000000), (5, 11, 700000600000000000);
+
+INSERT INTO attachment VALUES (1, '~/Library/Messages/Attachments/ab/00/photo.jpeg', 'photo.jpeg', 'image/jpeg', 'public.jpeg', 2048),
+  (2, '~/Library/Messages/Attachments/cd/01/IMG_0042.heic', 'IMG_0042.heic', 'image/heic', 'public.heic', 4096);
+INSERT INTO message_attachment_join VALUES (5, 1), (12, 2);
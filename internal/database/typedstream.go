@@ -0,0 +1,409 @@
+// Package database provides a minimal decoder for Apple's typedstream format.
+package database
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// typedstream tags. Each tag is a single signed byte; values from
+// typedstreamFirstRef upwards (and the multi-byte integer forms) are
+// back-references into the shared string or object tables.
+const (
+	typedstreamInt16    = -127 // 0x81: little-endian int16 follows
+	typedstreamInt32    = -126 // 0x82: little-endian int32 follows
+	typedstreamFloat    = -125 // 0x83: float32/float64 follows
+	typedstreamNew      = -124 // 0x84: a new string, class or object follows
+	typedstreamNil      = -123 // 0x85: nil
+	typedstreamEnd      = -122 // 0x86: end of object contents
+	typedstreamFirstRef = -110 // 0x92: first back-reference number
+)
+
+const typedstreamSignature = "streamtyped"
+
+// errTypedstreamFound unwinds the decoder once the message string is found.
+var errTypedstreamFound = errors.New("typedstream: string found")
+
+// typedstreamDecoder walks a typedstream (the NSArchiver format Messages uses
+// for the attributedBody column) and captures the contents of the first
+// NSString it encounters, which is the plain text of the NSAttributedString.
+type typedstreamDecoder struct {
+	data    []byte
+	pos     int
+	strings [][]byte
+	objects []string // class name of each shared class/object, "" if unknown
+	classes []string // stack of classes whose contents are being decoded
+	text    string
+}
+
+// decodeTypedstreamText returns the text of the first NSString archived in a
+// typedstream blob.
+func decodeTypedstreamText(data []byte) (string, error) {
+	d := &typedstreamDecoder{data: data}
+	if err := d.readHeader(); err != nil {
+		return "", err
+	}
+
+	for d.pos < len(d.data) {
+		err := d.readTypedGroup()
+		if err == errTypedstreamFound {
+			return d.text, nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return "", errors.New("typedstream: no NSString found")
+}
+
+func (d *typedstreamDecoder) readHeader() error {
+	version, err := d.readInt()
+	if err != nil {
+		return err
+	}
+	if version != 4 {
+		return fmt.Errorf("typedstream: unsupported streamer version %d", version)
+	}
+
+	sig, err := d.readUnsharedBytes()
+	if err != nil {
+		return err
+	}
+	if string(sig) != typedstreamSignature {
+		return errors.New("typedstream: missing signature")
+	}
+
+	// System version; not needed for decoding.
+	_, err = d.readInt()
+	return err
+}
+
+func (d *typedstreamDecoder) readByte() (int8, error) {
+	if d.pos >= len(d.data) {
+		return 0, errors.New("typedstream: unexpected end of data")
+	}
+	b := int8(d.data[d.pos])
+	d.pos++
+	return b, nil
+}
+
+func (d *typedstreamDecoder) readN(n int) ([]byte, error) {
+	if n < 0 || d.pos+n > len(d.data) {
+		return nil, errors.New("typedstream: unexpected end of data")
+	}
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+// readIntWithHead decodes an integer whose first byte has already been read.
+func (d *typedstreamDecoder) readIntWithHead(head int8) (int64, error) {
+	switch head {
+	case typedstreamInt16:
+		b, err := d.readN(2)
+		if err != nil {
+			return 0, err
+		}
+		return int64(int16(binary.LittleEndian.Uint16(b))), nil
+	case typedstreamInt32:
+		b, err := d.readN(4)
+		if err != nil {
+			return 0, err
+		}
+		return int64(int32(binary.LittleEndian.Uint32(b))), nil
+	default:
+		return int64(head), nil
+	}
+}
+
+func (d *typedstreamDecoder) readInt() (int64, error) {
+	head, err := d.readByte()
+	if err != nil {
+		return 0, err
+	}
+	return d.readIntWithHead(head)
+}
+
+// readLength decodes a non-negative length. Lengths use the integer encoding
+// but are unsigned, so 2- and 4-byte forms are not sign-extended.
+func (d *typedstreamDecoder) readLength() (int, error) {
+	head, err := d.readByte()
+	if err != nil {
+		return 0, err
+	}
+	switch head {
+	case typedstreamInt16:
+		b, err := d.readN(2)
+		if err != nil {
+			return 0, err
+		}
+		return int(binary.LittleEndian.Uint16(b)), nil
+	case typedstreamInt32:
+		b, err := d.readN(4)
+		if err != nil {
+			return 0, err
+		}
+		return int(binary.LittleEndian.Uint32(b)), nil
+	default:
+		return int(uint8(head)), nil
+	}
+}
+
+func (d *typedstreamDecoder) readUnsharedBytes() ([]byte, error) {
+	n, err := d.readLength()
+	if err != nil {
+		return nil, err
+	}
+	return d.readN(n)
+}
+
+// readRef decodes a back-reference whose head byte has already been read.
+func (d *typedstreamDecoder) readRef(head int8) (int, error) {
+	v, err := d.readIntWithHead(head)
+	if err != nil {
+		return 0, err
+	}
+	return int(v - typedstreamFirstRef), nil
+}
+
+func (d *typedstreamDecoder) readSharedString() ([]byte, error) {
+	head, err := d.readByte()
+	if err != nil {
+		return nil, err
+	}
+	switch head {
+	case typedstreamNil:
+		return nil, nil
+	case typedstreamNew:
+		b, err := d.readUnsharedBytes()
+		if err != nil {
+			return nil, err
+		}
+		d.strings = append(d.strings, b)
+		return b, nil
+	default:
+		ref, err := d.readRef(head)
+		if err != nil {
+			return nil, err
+		}
+		if ref < 0 || ref >= len(d.strings) {
+			return nil, fmt.Errorf("typedstream: invalid string reference %d", ref)
+		}
+		return d.strings[ref], nil
+	}
+}
+
+// readClass decodes a class and its superclass chain, returning the class name.
+func (d *typedstreamDecoder) readClass() (string, error) {
+	head, err := d.readByte()
+	if err != nil {
+		return "", err
+	}
+	switch head {
+	case typedstreamNil:
+		return "", nil
+	case typedstreamNew:
+		name, err := d.readSharedString()
+		if err != nil {
+			return "", err
+		}
+		if _, err := d.readInt(); err != nil { // class version
+			return "", err
+		}
+		d.objects = append(d.objects, string(name))
+		if _, err := d.readClass(); err != nil { // superclass
+			return "", err
+		}
+		return string(name), nil
+	default:
+		ref, err := d.readRef(head)
+		if err != nil {
+			return "", err
+		}
+		if ref < 0 || ref >= len(d.objects) {
+			return "", fmt.Errorf("typedstream: invalid class reference %d", ref)
+		}
+		return d.objects[ref], nil
+	}
+}
+
+// readObject decodes an object: its class followed by typed groups until
+// the end-of-object tag.
+func (d *typedstreamDecoder) readObject() error {
+	head, err := d.readByte()
+	if err != nil {
+		return err
+	}
+	switch head {
+	case typedstreamNil:
+		return nil
+	case typedstreamNew:
+	default:
+		ref, err := d.readRef(head)
+		if err != nil {
+			return err
+		}
+		if ref < 0 || ref >= len(d.objects) {
+			return fmt.Errorf("typedstream: invalid object reference %d", ref)
+		}
+		return nil
+	}
+
+	// Reserve the object's slot before its class, matching the encoder.
+	slot := len(d.objects)
+	d.objects = append(d.objects, "")
+	class, err := d.readClass()
+	if err != nil {
+		return err
+	}
+	d.objects[slot] = class
+
+	d.classes = append(d.classes, class)
+	defer func() { d.classes = d.classes[:len(d.classes)-1] }()
+
+	for {
+		if d.pos >= len(d.data) {
+			return errors.New("typedstream: unterminated object")
+		}
+		if int8(d.data[d.pos]) == typedstreamEnd {
+			d.pos++
+			return nil
+		}
+		if err := d.readTypedGroup(); err != nil {
+			return err
+		}
+	}
+}
+
+// readTypedGroup decodes a type-encoding string followed by one value per
+// type in the encoding.
+func (d *typedstreamDecoder) readTypedGroup() error {
+	enc, err := d.readSharedString()
+	if err != nil {
+		return err
+	}
+	if len(enc) == 0 {
+		return errors.New("typedstream: empty type encoding")
+	}
+
+	for i := 0; i < len(enc); {
+		n, err := d.readValue(enc[i:])
+		if err != nil {
+			return err
+		}
+		i += n
+	}
+	return nil
+}
+
+// readValue decodes the value for the type at the start of enc and returns
+// the number of encoding bytes consumed.
+func (d *typedstreamDecoder) readValue(enc []byte) (int, error) {
+	switch enc[0] {
+	case '@':
+		return 1, d.readObject()
+	case '#':
+		_, err := d.readClass()
+		return 1, err
+	case '*', ':', '%':
+		_, err := d.readSharedString()
+		return 1, err
+	case '+':
+		b, err := d.readUnsharedBytes()
+		if err != nil {
+			return 0, err
+		}
+		if d.inStringObject() {
+			d.text = decodeStringBytes(b)
+			return 1, errTypedstreamFound
+		}
+		return 1, nil
+	case 'c', 'C', 's', 'S', 'i', 'I', 'l', 'L', 'q', 'Q', 'B':
+		_, err := d.readInt()
+		return 1, err
+	case 'f', 'd':
+		return 1, d.readFloat(enc[0])
+	case '[':
+		return d.readArray(enc)
+	default:
+		return 0, fmt.Errorf("typedstream: unsupported type %q", enc[0])
+	}
+}
+
+func (d *typedstreamDecoder) readFloat(kind byte) error {
+	head, err := d.readByte()
+	if err != nil {
+		return err
+	}
+	if head != typedstreamFloat {
+		_, err := d.readIntWithHead(head)
+		return err
+	}
+	size := 4
+	if kind == 'd' {
+		size = 8
+	}
+	_, err = d.readN(size)
+	return err
+}
+
+// readArray decodes a fixed-size array encoding such as "[12c]". Only byte
+// arrays are supported, which is all NSAttributedString archives use.
+func (d *typedstreamDecoder) readArray(enc []byte) (int, error) {
+	end := 1
+	for end < len(enc) && enc[end] >= '0' && enc[end] <= '9' {
+		end++
+	}
+	if end+1 >= len(enc) || enc[end+1] != ']' {
+		return 0, fmt.Errorf("typedstream: unsupported array encoding %q", enc)
+	}
+	count, err := strconv.Atoi(string(enc[1:end]))
+	if err != nil {
+		return 0, err
+	}
+	if enc[end] != 'c' && enc[end] != 'C' {
+		return 0, fmt.Errorf("typedstream: unsupported array element %q", enc[end])
+	}
+	if _, err := d.readN(count); err != nil {
+		return 0, err
+	}
+	return end + 2, nil
+}
+
+func (d *typedstreamDecoder) inStringObject() bool {
+	if len(d.classes) == 0 {
+		return false
+	}
+	class := d.classes[len(d.classes)-1]
+	return class == "NSString" || class == "NSMutableString"
+}
+
+// decodeStringBytes converts an NSString payload to a Go string. Messages
+// writes UTF-8, but payloads that are not valid UTF-8 and look like UTF-16
+// (even length, optional byte-order mark) are decoded as UTF-16.
+func decodeStringBytes(b []byte) string {
+	if utf8.Valid(b) || len(b)%2 != 0 {
+		return string(b)
+	}
+
+	order := binary.ByteOrder(binary.LittleEndian)
+	if len(b) >= 2 {
+		switch {
+		case b[0] == 0xFE && b[1] == 0xFF:
+			order = binary.BigEndian
+			b = b[2:]
+		case b[0] == 0xFF && b[1] == 0xFE:
+			b = b[2:]
+		}
+	}
+
+	units := make([]uint16, len(b)/2)
+	for i := range units {
+		units[i] = order.Uint16(b[i*2:])
+	}
+	return string(utf16.Decode(units))
+}
@@ -0,0 +1,191 @@
+package database
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+// testAttributedBody returns an attributedBody blob for text in the layout
+// Messages writes: an NSAttributedString whose NSString holds the text,
+// followed by its attribute run.
+func testAttributedBody(text string) []byte {
+	var b bytes.Buffer
+	b.WriteString("\x04\x0bstreamtyped\x81\xe8\x03\x84\x01@\x84\x84\x84\x12NSAttributedString\x00" +
+		"\x84\x84\x08NSObject\x00\x85\x92\x84\x84\x84\x08NSString\x01\x94\x84\x01+")
+	writeLength := func(n int) {
+		switch {
+		case n < 0x80:
+			b.WriteByte(byte(n))
+		case n <= 0xFFFF:
+			b.Write([]byte{0x81, byte(n), byte(n >> 8)})
+		default:
+			b.Write([]byte{0x82, byte(n), byte(n >> 8), byte(n >> 16), byte(n >> 24)})
+		}
+	}
+	writeLength(len(text))
+	b.WriteString(text)
+	b.WriteString("\x86\x84\x02iI\x01")
+	writeLength(len(utf16.Encode([]rune(text))))
+	b.WriteString("\x92\x84\x84\x84\x0cNSDictionary\x00\x94\x84\x01i\x01\x92\x84\x96\x96" +
+		"\x1d__kIMMessagePartAttributeName\x86\x92\x84\x84\x84\x08NSNumber\x00" +
+		"\x84\x84\x07NSValue\x00\x94\x84\x01*\x84\x99\x99\x00\x86\x86\x86")
+	return b.Bytes()
+}
+
+// readBlob returns testdata/attributedbody/name.bin and the text it should
+// decode to, from name.txt.
+func readBlob(t *testing.T, name string) (blob []byte, want string) {
+	t.Helper()
+	dir := filepath.Join("testdata", "attributedbody")
+	blob, err := os.ReadFile(filepath.Join(dir, name+".bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, err := os.ReadFile(filepath.Join(dir, name+".txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return blob, string(text)
+}
+
+// stringLengthTag returns the first byte of the NSString length in blob: the
+// length itself when short, or typedstreamInt16/typedstreamInt32.
+func stringLengthTag(t *testing.T, blob []byte) int8 {
+	t.Helper()
+	i := bytes.Index(blob, []byte("NSString\x01\x94\x84\x01+"))
+	if i < 0 {
+		t.Fatal("blob has no NSString")
+	}
+	return int8(blob[i+len("NSString\x01\x94\x84\x01+")])
+}
+
+// long32Blob returns a blob whose text is too long for a 16-bit length, and
+// that text. It's generated rather than checked in, being over 64 KB.
+func long32Blob() (blob []byte, text string) {
+	text = strings.Repeat("All work and no play makes Jack a dull boy. ", 1500) + "✓"
+	return testAttributedBody(text), text
+}
+
+func TestDecodeTypedstreamText(t *testing.T) {
+	tests := []struct {
+		name      string
+		lengthTag int8 // 0 for a one-byte length
+	}{
+		{"short", 0},
+		{"utf8", 0},
+		{"long16", typedstreamInt16},
+		{"long32", typedstreamInt32},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var blob []byte
+			var want string
+			if tt.name == "long32" {
+				blob, want = long32Blob()
+			} else {
+				blob, want = readBlob(t, tt.name)
+			}
+			tag := stringLengthTag(t, blob)
+			if tt.lengthTag == 0 && tag < 0 || tt.lengthTag != 0 && tag != tt.lengthTag {
+				t.Fatalf("fixture has length tag %#x", uint8(tag))
+			}
+
+			got, err := decodeTypedstreamText(blob)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != want {
+				t.Errorf("decodeTypedstreamText = %q, want %q", abbreviate(got), abbreviate(want))
+			}
+			if got := ExtractTextFromAttributedBody(blob); got != want {
+				t.Errorf("ExtractTextFromAttributedBody = %q, want %q", abbreviate(got), abbreviate(want))
+			}
+		})
+	}
+}
+
+func TestBlobHelperMatchesFixtures(t *testing.T) {
+	for _, name := range []string{"short", "utf8", "long16"} {
+		blob, text := readBlob(t, name)
+		if !bytes.Equal(testAttributedBody(text), blob) {
+			t.Errorf("testAttributedBody(%s.txt) differs from %s.bin", name, name)
+		}
+	}
+}
+
+// TestAttributedBodyFixtures checks every blob in testdata/attributedbody,
+// so captured blobs can be added without a test of their own (see the
+// README there).
+func TestAttributedBodyFixtures(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "attributedbody", "*.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("no fixtures found")
+	}
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), ".bin")
+		t.Run(name, func(t *testing.T) {
+			blob, want := readBlob(t, name)
+			if got := ExtractTextFromAttributedBody(blob); got != want {
+				t.Errorf("ExtractTextFromAttributedBody = %q, want %q", abbreviate(got), abbreviate(want))
+			}
+		})
+	}
+}
+
+func TestExtractTextFromAttributedBodyFallback(t *testing.T) {
+	// The streamer version byte is missing, so the decoder rejects the blob
+	// and the string-splitting heuristics find the text.
+	blob, want := readBlob(t, "fallback")
+	if _, err := decodeTypedstreamText(blob); err == nil {
+		t.Fatal("decodeTypedstreamText accepted the damaged blob")
+	}
+	if got := ExtractTextFromAttributedBody(blob); got != want {
+		t.Errorf("ExtractTextFromAttributedBody = %q, want %q", got, want)
+	}
+}
+
+func TestDecodeTypedstreamTextErrors(t *testing.T) {
+	blob, _ := readBlob(t, "short")
+	tests := map[string][]byte{
+		"empty":        nil,
+		"no signature": append([]byte{4, 11}, "streamtypex"...),
+		"truncated":    blob[:len(blob)/3],
+	}
+	for name, data := range tests {
+		if got, err := decodeTypedstreamText(data); err == nil {
+			t.Errorf("%s: decodeTypedstreamText = %q, want an error", name, got)
+		}
+	}
+}
+
+func TestDecodeStringBytesUTF16(t *testing.T) {
+	tests := []struct {
+		in   []byte
+		want string
+	}{
+		{[]byte{0xFF, 0xFE, 'h', 0, 0xE9, 0, 0x3D, 0xD8, 0x4D, 0xDC}, "hé👍"},
+		{[]byte{0xFE, 0xFF, 0, 'h', 0, 0xE9}, "hé"},
+		{[]byte("plain"), "plain"},
+	}
+	for _, tt := range tests {
+		if got := decodeStringBytes(tt.in); got != tt.want {
+			t.Errorf("decodeStringBytes(% x) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// abbreviate shortens long strings in failure messages.
+func abbreviate(s string) string {
+	if len(s) <= 80 {
+		return s
+	}
+	return fmt.Sprintf("%s…%s (%d bytes)", s[:40], s[len(s)-40:], len(s))
+}
@@ -0,0 +1,236 @@
+// Package outbox keeps messages that failed to send on disk so they can be
+// retried later, even after a crash.
+package outbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// FileName is the name of the outbox file in the user's home directory.
+const FileName = ".imessage-outbox.json"
+
+// Entry is a message waiting to be sent again.
+type Entry struct {
+	Recipient string `json:"recipient"`
+	Message   string `json:"message"`
+	// From is the handle of the account to send from; "" uses the default
+	From     string    `json:"from,omitempty"`
+	FailedAt time.Time `json:"failed_at"`
+	// Error is why the last attempt failed
+	Error    string `json:"error"`
+	Attempts int    `json:"attempts"`
+}
+
+// mu serializes read-modify-write cycles within this process; lock extends
+// that to other processes, such as the TUI queueing a message while
+// `outbox flush` runs.
+var mu sync.Mutex
+
+// Path returns the location of the outbox file.
+func Path() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, FileName)
+}
+
+// Load returns the queued messages, oldest first. A missing file yields an
+// empty outbox.
+func Load() ([]Entry, error) {
+	unlock, err := lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return load()
+}
+
+// Add queues a message whose send failed with sendErr.
+func Add(recipient, message, from string, sendErr error) error {
+	unlock, err := lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	entries, err := load()
+	if err != nil {
+		return err
+	}
+	entries = append(entries, Entry{
+		Recipient: recipient,
+		Message:   message,
+		From:      from,
+		FailedAt:  time.Now(),
+		Error:     sendErr.Error(),
+		Attempts:  1,
+	})
+	return save(entries)
+}
+
+// Remove drops the first queued message with this recipient and text, e.g.
+// once it was sent by hand. It reports whether one was found.
+func Remove(recipient, message string) (bool, error) {
+	unlock, err := lock()
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+
+	entries, err := load()
+	if err != nil {
+		return false, err
+	}
+	for i, e := range entries {
+		if e.Recipient == recipient && e.Message == message {
+			entries = append(entries[:i], entries[i+1:]...)
+			return true, save(entries)
+		}
+	}
+	return false, nil
+}
+
+// Flush tries to send every queued message with send, oldest first, waiting
+// delay between attempts. Sent messages are removed; the rest stay queued
+// with their error and attempt count updated, and messages queued while it
+// runs are kept. It returns how many were sent and how many remain.
+func Flush(send func(Entry) error, delay time.Duration) (sent, remaining int, err error) {
+	entries, err := Load()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// The outbox isn't locked while sending, which can take minutes, so
+	// messages may be queued or removed meanwhile. The results are merged
+	// into the file as it is by then: only the entries sent are dropped.
+	done := make([]bool, len(entries))
+	for i := range entries {
+		if i > 0 && delay > 0 {
+			time.Sleep(delay)
+		}
+		if sendErr := send(entries[i]); sendErr != nil {
+			entries[i].Error = sendErr.Error()
+			continue
+		}
+		done[i] = true
+		sent++
+	}
+
+	unlock, err := lock()
+	if err != nil {
+		return sent, 0, err
+	}
+	defer unlock()
+
+	current, err := load()
+	if err != nil {
+		return sent, 0, err
+	}
+	for i, e := range entries {
+		j := indexOf(current, e)
+		if j < 0 {
+			continue // removed meanwhile
+		}
+		if done[i] {
+			current = append(current[:j], current[j+1:]...)
+			continue
+		}
+		current[j].Attempts++
+		current[j].FailedAt = time.Now()
+		current[j].Error = e.Error
+	}
+	return sent, len(current), save(current)
+}
+
+// indexOf returns the position of the first entry in entries with e's
+// recipient, text, account and failure time, or -1.
+func indexOf(entries []Entry, e Entry) int {
+	for i, c := range entries {
+		if c.Recipient == e.Recipient && c.Message == e.Message && c.From == e.From && c.FailedAt.Equal(e.FailedAt) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Clear empties the outbox, returning how many messages were dropped.
+func Clear() (int, error) {
+	unlock, err := lock()
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	entries, err := load()
+	if err != nil {
+		return 0, err
+	}
+	return len(entries), save(nil)
+}
+
+// lock takes mu and an exclusive flock on a file next to the outbox, held
+// by every process while it loads and saves the outbox. The returned
+// function releases both. The lock file is left in place: removing it would
+// let two processes lock different files.
+func lock() (func(), error) {
+	mu.Lock()
+	f, err := os.OpenFile(Path()+".lock", os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		mu.Unlock()
+		return nil, fmt.Errorf("failed to lock outbox: %w", err)
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		f.Close()
+		mu.Unlock()
+		return nil, fmt.Errorf("failed to lock outbox: %w", err)
+	}
+	return func() {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+		mu.Unlock()
+	}, nil
+}
+
+// load reads the outbox file; the caller must hold the lock.
+func load() ([]Entry, error) {
+	data, err := os.ReadFile(Path())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read outbox: %w", err)
+	}
+	var entries []Entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("invalid outbox file %s: %w", Path(), err)
+	}
+	return entries, nil
+}
+
+// save writes entries atomically, removing the file when there are none;
+// the caller must hold the lock.
+func save(entries []Entry) error {
+	path := Path()
+	if len(entries) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to write outbox: %w", err)
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to write outbox: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write outbox: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package outbox
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFlushKeepsEntriesAddedWhileSending(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	for _, text := range []string{"first", "second", "third"} {
+		if err := Add("+15551234567", text, "", errors.New("timed out")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	send := func(e Entry) error {
+		switch e.Message {
+		case "first":
+			// Another process queues a message mid-flush.
+			if err := Add("bob@example.com", "late", "", errors.New("timed out")); err != nil {
+				t.Fatal(err)
+			}
+		case "second":
+			return errors.New("still offline")
+		}
+		return nil
+	}
+	sent, remaining, err := Flush(send, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sent != 2 || remaining != 2 {
+		t.Errorf("Flush = %d sent, %d remaining, want 2, 2", sent, remaining)
+	}
+
+	entries, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2: %+v", len(entries), entries)
+	}
+	if e := entries[0]; e.Message != "second" || e.Attempts != 2 || e.Error != "still offline" {
+		t.Errorf("entries[0] = %+v, want second with 2 attempts and the new error", e)
+	}
+	if e := entries[1]; e.Message != "late" || e.Attempts != 1 {
+		t.Errorf("entries[1] = %+v, want the message queued during the flush", e)
+	}
+}
+
+func TestFlushSkipsEntriesRemovedWhileSending(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	for _, text := range []string{"first", "second"} {
+		if err := Add("+15551234567", text, "", errors.New("timed out")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	send := func(e Entry) error {
+		if e.Message == "first" {
+			// The user sends "second" by hand meanwhile.
+			if _, err := Remove("+15551234567", "second"); err != nil {
+				t.Fatal(err)
+			}
+			return nil
+		}
+		return errors.New("still offline")
+	}
+	if _, remaining, err := Flush(send, 0); err != nil || remaining != 0 {
+		t.Errorf("Flush = %d remaining, %v; want 0, nil", remaining, err)
+	}
+}
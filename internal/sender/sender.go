@@ -3,12 +3,79 @@ package sender
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
+	"net/url"
 	"os/exec"
 	"strings"
+	"sync"
 	"time"
 )
 
+var (
+	logMu  sync.Mutex
+	logger *log.Logger
+)
+
+// SetLogger makes the package log every osascript (and open) invocation
+// and its output, to diagnose failed sends. A nil logger (the default)
+// discards them.
+func SetLogger(l *log.Logger) {
+	logMu.Lock()
+	defer logMu.Unlock()
+	logger = l
+}
+
+// logf writes to the logger set with SetLogger, if any.
+func logf(format string, v ...interface{}) {
+	logMu.Lock()
+	defer logMu.Unlock()
+	if logger != nil {
+		logger.Printf(format, v...)
+	}
+}
+
+// runCommand runs name with args and returns its combined output, logging
+// the invocation (scripts squeezed onto one line) and the result.
+func runCommand(ctx context.Context, name string, args ...string) ([]byte, error) {
+	logf("run: %s %s", name, strings.Join(strings.Fields(strings.Join(args, " ")), " "))
+	output, err := exec.CommandContext(ctx, name, args...).CombinedOutput()
+	if err != nil {
+		logf("%s failed: %v %s", name, err, strings.TrimSpace(string(output)))
+	} else {
+		logf("%s ok %s", name, strings.TrimSpace(string(output)))
+	}
+	return output, err
+}
+
+// ErrNoAutomationPermission is returned when macOS blocks osascript from
+// controlling Messages (Privacy & Security → Automation).
+var ErrNoAutomationPermission = errors.New("not authorized to control Messages; grant Automation permission to your terminal")
+
+// ErrNoAccessibilityPermission is returned when UI scripting through System
+// Events is blocked (Privacy & Security → Accessibility).
+var ErrNoAccessibilityPermission = errors.New("UI scripting is not allowed; grant Accessibility permission to your terminal")
+
+// scriptError returns ErrNoAutomationPermission if osascript's output shows
+// the Apple event was denied (error -1743), ErrNoAccessibilityPermission if
+// UI scripting was refused (-1719, -25211), or nil otherwise.
+func scriptError(output []byte) error {
+	out := string(output)
+	lower := strings.ToLower(out)
+	switch {
+	case strings.Contains(out, "-1743") || strings.Contains(lower, "not authorized to send apple events"):
+		return fmt.Errorf("%w: %s", ErrNoAutomationPermission, strings.TrimSpace(out))
+	case strings.Contains(out, "-1719") || strings.Contains(out, "-25211") || strings.Contains(lower, "assistive access"):
+		return fmt.Errorf("%w: %s", ErrNoAccessibilityPermission, strings.TrimSpace(out))
+	}
+	return nil
+}
+
+// DefaultSendDelay is the pause between consecutive sends. Messages handed to
+// the Messages app back to back can arrive out of order or be dropped.
+const DefaultSendDelay = time.Second
+
 // SendMessage sends an iMessage to a recipient.
 func SendMessage(recipient, message string) error {
 	escapedMessage := escapeForAppleScript(message)
@@ -25,18 +92,63 @@ func SendMessage(recipient, message string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, "osascript", "-e", applescript)
-	output, err := cmd.CombinedOutput()
+	output, err := runCommand(ctx, "osascript", "-e", applescript)
 
 	if err != nil {
+		// The fallbacks would be denied too.
+		if permErr := scriptError(output); permErr != nil {
+			return permErr
+		}
 		// Try alternative method
 		return sendMessageAlternative(recipient, message)
 	}
 
-	_ = output
 	return nil
 }
 
+// SendMessageFrom sends an iMessage to a recipient from a specific account
+// (see FindAccount). Unlike SendMessage it has no fallbacks, since they
+// could send from a different account.
+func SendMessageFrom(account Account, recipient, message string) error {
+	applescript := fmt.Sprintf(`
+		tell application "Messages"
+			set targetAccount to 1st account whose id = "%s"
+			set targetParticipant to participant "%s" of targetAccount
+			send "%s" to targetParticipant
+		end tell
+	`, escapeForAppleScript(account.ID), escapeForAppleScript(recipient), escapeForAppleScript(message))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	output, err := runCommand(ctx, "osascript", "-e", applescript)
+
+	if err != nil {
+		if permErr := scriptError(output); permErr != nil {
+			return permErr
+		}
+		return fmt.Errorf("failed to send message from %s: %s", account.Handle, strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
+
+// SendAll calls send for each recipient in order, waiting delay between the
+// calls so Messages delivers them in sequence. A failure doesn't stop the
+// rest; it returns each recipient's error, nil for those that succeeded.
+// send is SendMessage or SendMessageFrom wrapped with the message, plus
+// whatever progress the caller reports.
+func SendAll(recipients []string, delay time.Duration, send func(recipient string) error) []error {
+	errs := make([]error, len(recipients))
+	for i, recipient := range recipients {
+		if i > 0 && delay > 0 {
+			time.Sleep(delay)
+		}
+		errs[i] = send(recipient)
+	}
+	return errs
+}
+
 // sendMessageAlternative is an alternative method to send message using chat specifier.
 func sendMessageAlternative(recipient, message string) error {
 	escapedMessage := escapeForAppleScript(message)
@@ -51,8 +163,7 @@ func sendMessageAlternative(recipient, message string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, "osascript", "-e", applescript)
-	_, err := cmd.CombinedOutput()
+	_, err := runCommand(ctx, "osascript", "-e", applescript)
 
 	if err != nil {
 		return sendNewMessage(recipient, message)
@@ -79,10 +190,12 @@ func sendNewMessage(recipient, message string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, "osascript", "-e", applescript)
-	output, err := cmd.CombinedOutput()
+	output, err := runCommand(ctx, "osascript", "-e", applescript)
 
 	if err != nil {
+		if permErr := scriptError(output); permErr != nil {
+			return permErr
+		}
 		return fmt.Errorf("failed to send message: %s", string(output))
 	}
 
@@ -104,16 +217,133 @@ func SendToGroup(chatName, message string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, "osascript", "-e", applescript)
-	output, err := cmd.CombinedOutput()
+	output, err := runCommand(ctx, "osascript", "-e", applescript)
 
 	if err != nil {
+		if permErr := scriptError(output); permErr != nil {
+			return permErr
+		}
 		return fmt.Errorf("failed to send to group: %s", string(output))
 	}
 
 	return nil
 }
 
+// ErrAccountNotFound is returned when no Messages account has the
+// requested handle.
+var ErrAccountNotFound = errors.New("no such account in Messages")
+
+// Account is an account configured in Messages.
+type Account struct {
+	ID      string // unique id AppleScript selects the account by
+	Handle  string // login handle, e.g. jane@icloud.com or +15551234567
+	Service string // iMessage, SMS, ...
+}
+
+// String formats the account as ListAccounts does.
+func (a Account) String() string {
+	return fmt.Sprintf("%s (%s)", a.Handle, a.Service)
+}
+
+// ListAccounts returns the accounts configured in Messages, one entry per
+// account in the form "handle (service)", e.g. "jane@icloud.com (iMessage)".
+func ListAccounts() ([]string, error) {
+	accounts, err := listAccounts()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]string, len(accounts))
+	for i, a := range accounts {
+		list[i] = a.String()
+	}
+	return list, nil
+}
+
+// FindAccount returns the account whose handle (or id) is handle, ignoring
+// case. A handle with several accounts prefers iMessage. If there is none
+// the error wraps ErrAccountNotFound and lists the accounts there are.
+func FindAccount(handle string) (Account, error) {
+	accounts, err := listAccounts()
+	if err != nil {
+		return Account{}, err
+	}
+	var found *Account
+	for i, a := range accounts {
+		if !strings.EqualFold(a.Handle, handle) && !strings.EqualFold(a.ID, handle) {
+			continue
+		}
+		if found == nil || a.Service == "iMessage" {
+			found = &accounts[i]
+		}
+	}
+	if found == nil {
+		names := make([]string, len(accounts))
+		for i, a := range accounts {
+			names[i] = a.String()
+		}
+		return Account{}, fmt.Errorf("%w: %q (available: %s)", ErrAccountNotFound, handle, strings.Join(names, ", "))
+	}
+	return *found, nil
+}
+
+// listAccounts reads every account's id, handle and service from Messages.
+func listAccounts() ([]Account, error) {
+	applescript := `
+		tell application "Messages"
+			set output to ""
+			repeat with acct in (every account)
+				set output to output & (id of acct) & tab & (description of acct) & tab & (service type of acct as text) & linefeed
+			end repeat
+			return output
+		end tell
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	output, err := runCommand(ctx, "osascript", "-e", applescript)
+
+	if err != nil {
+		if permErr := scriptError(output); permErr != nil {
+			return nil, permErr
+		}
+		return nil, fmt.Errorf("failed to list accounts: %s", failureOutput(output, err))
+	}
+
+	var accounts []Account
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Split(strings.TrimSpace(line), "\t")
+		if len(fields) != 3 {
+			continue
+		}
+		accounts = append(accounts, Account{ID: fields[0], Handle: fields[1], Service: fields[2]})
+	}
+	return accounts, nil
+}
+
+// OpenConversation brings Messages to the front with the conversation for
+// chatIdentifier selected, using the imessage:// URL scheme. Group chats
+// (identifiers like "chat123...") have no URL, so Messages is just activated.
+func OpenConversation(chatIdentifier string) error {
+	if strings.HasPrefix(chatIdentifier, "chat") {
+		if !StartMessagesApp() {
+			return fmt.Errorf("failed to open Messages")
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	output, err := runCommand(ctx, "open", "imessage://"+url.PathEscape(chatIdentifier))
+
+	if err != nil {
+		return fmt.Errorf("failed to open conversation: %s", strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
+
 // CheckMessagesRunning checks if the Messages app is running.
 func CheckMessagesRunning() bool {
 	applescript := `
@@ -125,8 +355,7 @@ func CheckMessagesRunning() bool {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, "osascript", "-e", applescript)
-	output, err := cmd.Output()
+	output, err := runCommand(ctx, "osascript", "-e", applescript)
 
 	if err != nil {
 		return false
@@ -146,12 +375,46 @@ func StartMessagesApp() bool {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, "osascript", "-e", applescript)
-	err := cmd.Run()
+	_, err := runCommand(ctx, "osascript", "-e", applescript)
 
 	return err == nil
 }
 
+// CheckAccessibility asks System Events about Messages' menu bar, the kind
+// of UI scripting SendTapback and UnsendLastMessage rely on, without
+// clicking anything. It returns an error wrapping
+// ErrNoAccessibilityPermission if macOS refuses. Messages must be running.
+func CheckAccessibility() error {
+	applescript := `
+		tell application "System Events"
+			count menu bars of process "Messages"
+		end tell
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	output, err := runCommand(ctx, "osascript", "-e", applescript)
+
+	if err != nil {
+		if permErr := scriptError(output); permErr != nil {
+			return permErr
+		}
+		return fmt.Errorf("UI scripting check failed: %s", failureOutput(output, err))
+	}
+
+	return nil
+}
+
+// failureOutput describes a failed command by its output, or by err when
+// it printed nothing (e.g. osascript couldn't be started).
+func failureOutput(output []byte, err error) string {
+	if out := strings.TrimSpace(string(output)); out != "" {
+		return out
+	}
+	return err.Error()
+}
+
 func escapeForAppleScript(s string) string {
 	// Escape backslashes first (order matters)
 	s = strings.ReplaceAll(s, "\\", "\\\\")
@@ -0,0 +1,110 @@
+package sender
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Tapbacks lists the reactions in the order of the Messages tapback menu.
+var Tapbacks = []string{"love", "like", "dislike", "laugh", "emphasize", "question"}
+
+// tapbackAliases maps alternative names and emoji to Tapbacks entries.
+var tapbackAliases = map[string]string{
+	"heart":      "love",
+	"❤️":         "love",
+	"thumbsup":   "like",
+	"👍":          "like",
+	"thumbsdown": "dislike",
+	"👎":          "dislike",
+	"haha":       "laugh",
+	"😂":          "laugh",
+	"!!":         "emphasize",
+	"‼️":         "emphasize",
+	"?":          "question",
+	"❓":          "question",
+}
+
+// ErrNotLastMessage is returned by SendTapback when the message to react to
+// is no longer the conversation's last one, so ⌘T would reach another.
+var ErrNotLastMessage = errors.New("a newer message arrived in the conversation; the reaction would go to it instead")
+
+// ParseTapback returns the canonical name of a reaction and its position
+// (1-6) in the tapback menu.
+func ParseTapback(reaction string) (string, int, error) {
+	name := strings.ToLower(strings.TrimSpace(reaction))
+	if alias, ok := tapbackAliases[name]; ok {
+		name = alias
+	}
+	for i, t := range Tapbacks {
+		if t == name {
+			return t, i + 1, nil
+		}
+	}
+	return "", 0, fmt.Errorf("unknown reaction %q (want one of %s)", reaction, strings.Join(Tapbacks, ", "))
+}
+
+// SendTapback reacts to a message in a one-to-one conversation.
+//
+// Messages has no AppleScript command for reactions, so this opens the
+// conversation and drives the UI through System Events: ⌘T ("Tapback Last
+// Message") followed by the reaction's menu number. That only reaches the
+// most recent message, so messageGUID must be the GUID of the conversation's
+// last message. latestGUID returns the current one and is called once the
+// conversation is open, just before the keystrokes; if a message arrived in
+// the meantime the error is ErrNotLastMessage. UI scripting needs
+// Accessibility permission for the terminal; without it the error wraps
+// ErrNoAccessibilityPermission. Group chats can't be opened by URL, so they
+// are rejected rather than risking a reaction in the wrong chat.
+func SendTapback(chatIdentifier, messageGUID, reaction string, latestGUID func() (string, error)) error {
+	_, index, err := ParseTapback(reaction)
+	if err != nil {
+		return err
+	}
+	if messageGUID == "" {
+		return fmt.Errorf("no message to react to")
+	}
+	if strings.HasPrefix(chatIdentifier, "chat") {
+		return fmt.Errorf("reactions in group chats aren't supported")
+	}
+
+	if err := OpenConversation(chatIdentifier); err != nil {
+		return err
+	}
+
+	latest, err := latestGUID()
+	if err != nil {
+		return fmt.Errorf("failed to check the last message: %w", err)
+	}
+	if latest != messageGUID {
+		return ErrNotLastMessage
+	}
+
+	applescript := fmt.Sprintf(`
+		tell application "Messages" to activate
+		delay 1
+		tell application "System Events"
+			tell process "Messages"
+				keystroke "t" using command down
+				delay 0.5
+				keystroke "%d"
+			end tell
+		end tell
+	`, index)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	output, err := runCommand(ctx, "osascript", "-e", applescript)
+
+	if err != nil {
+		if permErr := scriptError(output); permErr != nil {
+			return permErr
+		}
+		return fmt.Errorf("failed to send reaction: %s", strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package sender
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// UnsendWindow is how long after sending Messages lets a message be unsent
+// (macOS 13 and later).
+const UnsendWindow = 2 * time.Minute
+
+// ErrCannotUnsend is returned by UnsendLastMessage when Messages offers no
+// "Undo Send", usually because the 2-minute window has passed.
+var ErrCannotUnsend = errors.New("Messages can't unsend the last message; unsending only works within 2 minutes of sending")
+
+// UnsendLastMessage unsends my most recent message in a one-to-one
+// conversation.
+//
+// Messages has no AppleScript command for it, so like SendTapback this opens
+// the conversation and drives the UI through System Events, choosing Edit →
+// Undo Send, which acts on the last message sent. Once UnsendWindow has
+// passed the menu item is gone and the error wraps ErrCannotUnsend; the
+// recipient may also have seen the message already. UI scripting needs
+// Accessibility permission for the terminal. Group chats can't be opened by
+// URL, so they are rejected rather than risking an unsend in the wrong chat.
+func UnsendLastMessage(chatIdentifier string) error {
+	if strings.HasPrefix(chatIdentifier, "chat") {
+		return fmt.Errorf("unsending in group chats isn't supported")
+	}
+
+	if err := OpenConversation(chatIdentifier); err != nil {
+		return err
+	}
+
+	applescript := `
+		tell application "Messages" to activate
+		delay 1
+		tell application "System Events"
+			tell process "Messages"
+				set undoItem to menu item "Undo Send" of menu "Edit" of menu bar 1
+				if not (enabled of undoItem) then error "Undo Send is disabled" number -1728
+				click undoItem
+			end tell
+		end tell
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	output, err := runCommand(ctx, "osascript", "-e", applescript)
+
+	if err != nil {
+		if permErr := scriptError(output); permErr != nil {
+			return permErr
+		}
+		// -1728: the menu item doesn't exist or is disabled
+		if strings.Contains(string(output), "-1728") {
+			return ErrCannotUnsend
+		}
+		return fmt.Errorf("failed to unsend message: %s", strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
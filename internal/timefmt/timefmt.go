@@ -0,0 +1,111 @@
+// Package timefmt formats message timestamps the same way across the CLI
+// and the TUI.
+package timefmt
+
+import (
+	"math"
+	"time"
+)
+
+// Layouts used for message timestamps.
+const (
+	Clock12Layout  = "03:04 PM"
+	Clock24Layout  = "15:04"
+	WeekdayLayout  = "Monday"
+	DateLayout     = "2006-01-02"
+	ExactLayout    = "2006-01-02 15:04:05"
+	shortDayLayout = "Mon"
+	shortDate      = "01/02"
+)
+
+// clockLayout is the time-of-day layout used by every formatter.
+var clockLayout = Clock12Layout
+
+// SetClock24 switches all formatters between 24-hour ("15:04") and
+// 12-hour ("03:04 PM") time. The default is 12-hour.
+func SetClock24(on bool) {
+	if on {
+		clockLayout = Clock24Layout
+	} else {
+		clockLayout = Clock12Layout
+	}
+}
+
+// ClockLayout returns the current time-of-day layout.
+func ClockLayout() string {
+	return clockLayout
+}
+
+// relativeDays returns how many calendar days t is before now (0 for today,
+// 1 for yesterday, -1 for tomorrow), in now's location. The 12 hours
+// absorb days made shorter or longer by daylight saving time.
+func relativeDays(t, now time.Time) int {
+	t = t.In(now.Location())
+	ty, tm, td := t.Date()
+	ny, nm, nd := now.Date()
+	day := time.Date(ty, tm, td, 0, 0, 0, 0, now.Location())
+	today := time.Date(ny, nm, nd, 0, 0, 0, 0, now.Location())
+	return int(math.Floor((today.Sub(day).Hours() + 12) / 24))
+}
+
+// FormatRelativeTime formats t relative to now: the time of day for today,
+// "Yesterday 03:04 PM", the weekday for the last week, and the full date
+// for anything older (or in the future). A nil time yields "".
+func FormatRelativeTime(t *time.Time, now time.Time) string {
+	if t == nil {
+		return ""
+	}
+	local := t.In(now.Location())
+	switch days := relativeDays(*t, now); {
+	case days == 0:
+		return local.Format(clockLayout)
+	case days == 1:
+		return "Yesterday " + local.Format(clockLayout)
+	case days > 1 && days < 7:
+		return local.Format(WeekdayLayout + " " + clockLayout)
+	}
+	return local.Format(DateLayout + " " + clockLayout)
+}
+
+// FormatRelativeTimeShort is the compact form of FormatRelativeTime used
+// where space is tight: the time of day for today, then "Yesterday", the
+// abbreviated weekday and finally the month and day.
+func FormatRelativeTimeShort(t *time.Time, now time.Time) string {
+	if t == nil {
+		return ""
+	}
+	local := t.In(now.Location())
+	switch days := relativeDays(*t, now); {
+	case days == 0:
+		return local.Format(clockLayout)
+	case days == 1:
+		return "Yesterday"
+	case days > 1 && days < 7:
+		return local.Format(shortDayLayout)
+	}
+	return local.Format(shortDate)
+}
+
+// FormatAbsoluteTime formats t as a full date and time in the local time
+// zone. A nil time yields "".
+func FormatAbsoluteTime(t *time.Time) string {
+	return FormatAbsoluteTimeIn(t, time.Local)
+}
+
+// FormatAbsoluteTimeIn formats t as a full date and time in loc. A nil time
+// yields "".
+func FormatAbsoluteTimeIn(t *time.Time, loc *time.Location) string {
+	if t == nil {
+		return ""
+	}
+	return t.In(loc).Format(DateLayout + " " + clockLayout)
+}
+
+// FormatExactTime formats t in the local time zone to the second, e.g. to
+// tell apart messages sent within the same minute. A nil time yields "".
+func FormatExactTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Local().Format(ExactLayout)
+}
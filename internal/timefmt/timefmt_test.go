@@ -0,0 +1,73 @@
+package timefmt
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestFormatRelativeTime(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(year int, month time.Month, day, hour, min int) time.Time {
+		return time.Date(year, month, day, hour, min, 0, 0, ny)
+	}
+	// A Wednesday afternoon
+	now := at(2024, time.June, 12, 15, 30)
+
+	tests := []struct {
+		name  string
+		t     time.Time
+		now   time.Time
+		full  string
+		short string
+	}{
+		{"today", at(2024, time.June, 12, 9, 5), now, "09:05 AM", "09:05 AM"},
+		{"today/midnight", at(2024, time.June, 12, 0, 0), now, "12:00 AM", "12:00 AM"},
+		{"yesterday", at(2024, time.June, 11, 23, 59), now, "Yesterday 11:59 PM", "Yesterday"},
+		{"this week", at(2024, time.June, 8, 14, 0), now, "Saturday 02:00 PM", "Sat"},
+		{"six days ago", at(2024, time.June, 6, 8, 0), now, "Thursday 08:00 AM", "Thu"},
+		{"a week ago", at(2024, time.June, 5, 16, 0), now, "2024-06-05 04:00 PM", "06/05"},
+		{"last year", at(2023, time.December, 25, 10, 0), now, "2023-12-25 10:00 AM", "12/25"},
+		{"tomorrow", at(2024, time.June, 13, 0, 5), now, "2024-06-13 12:05 AM", "06/13"},
+		{"next week", at(2024, time.June, 20, 9, 0), now, "2024-06-20 09:00 AM", "06/20"},
+		{"in another zone", time.Date(2024, time.June, 12, 2, 0, 0, 0, time.UTC), now, "Yesterday 10:00 PM", "Yesterday"},
+		// Clocks went forward on March 10, 2024, making it 23 hours long,
+		// and back on November 3, making it 25.
+		{"dst/spring yesterday", at(2024, time.March, 10, 1, 0), at(2024, time.March, 11, 0, 30), "Yesterday 01:00 AM", "Yesterday"},
+		{"dst/spring week", at(2024, time.March, 4, 12, 0), at(2024, time.March, 11, 12, 0), "2024-03-04 12:00 PM", "03/04"},
+		{"dst/fall yesterday", at(2024, time.November, 3, 0, 30), at(2024, time.November, 4, 23, 30), "Yesterday 12:30 AM", "Yesterday"},
+		{"dst/fall today", at(2024, time.November, 3, 0, 30), at(2024, time.November, 3, 23, 30), "12:30 AM", "12:30 AM"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FormatRelativeTime(&tt.t, tt.now); got != tt.full {
+				t.Errorf("FormatRelativeTime = %q, want %q", got, tt.full)
+			}
+			if got := FormatRelativeTimeShort(&tt.t, tt.now); got != tt.short {
+				t.Errorf("FormatRelativeTimeShort = %q, want %q", got, tt.short)
+			}
+		})
+	}
+
+	if got := FormatRelativeTime(nil, now); got != "" {
+		t.Errorf("FormatRelativeTime(nil) = %q, want \"\"", got)
+	}
+}
+
+func TestSetClock24(t *testing.T) {
+	t.Cleanup(func() { SetClock24(false) })
+	now := time.Date(2024, time.June, 12, 15, 30, 0, 0, time.UTC)
+	msg := now.Add(-time.Hour)
+
+	SetClock24(true)
+	if got := FormatRelativeTime(&msg, now); got != "14:30" {
+		t.Errorf("24-hour: got %q, want %q", got, "14:30")
+	}
+	SetClock24(false)
+	if got := FormatRelativeTime(&msg, now); got != "02:30 PM" {
+		t.Errorf("12-hour: got %q, want %q", got, "02:30 PM")
+	}
+}
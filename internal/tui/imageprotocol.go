@@ -0,0 +1,179 @@
+// Package tui provides full-resolution image output for terminals that
+// support the Sixel or iTerm2 inline image protocols.
+package tui
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"strings"
+)
+
+// ImageProtocol is a terminal graphics protocol.
+type ImageProtocol int
+
+// Supported image protocols.
+const (
+	ProtocolNone ImageProtocol = iota
+	ProtocolSixel
+	ProtocolITerm2
+)
+
+// Assumed terminal cell size in pixels, used to convert cell bounds to pixel
+// bounds for protocols that draw real pixels.
+const (
+	CellPixelWidth  = 10
+	CellPixelHeight = 20
+)
+
+// sixelTerms are $TERM values (or prefixes) of terminals known to support Sixel.
+var sixelTerms = []string{"foot", "mlterm", "yaft", "contour", "xterm-sixel"}
+
+// DetectImageProtocol guesses the best inline image protocol for the current
+// terminal from $TERM_PROGRAM and $TERM.
+func DetectImageProtocol() ImageProtocol {
+	switch os.Getenv("TERM_PROGRAM") {
+	case "iTerm.app", "WezTerm":
+		return ProtocolITerm2
+	}
+
+	term := os.Getenv("TERM")
+	if strings.Contains(term, "sixel") {
+		return ProtocolSixel
+	}
+	for _, t := range sixelTerms {
+		if strings.HasPrefix(term, t) {
+			return ProtocolSixel
+		}
+	}
+	return ProtocolNone
+}
+
+// RenderImageSixel renders an image file at full resolution using the
+// terminal's inline image protocol (Sixel, or the iTerm2 escape sequence),
+// fitted within maxWidth×maxHeight cells. The output is raw escape sequences
+// meant for a plain terminal, not a tview widget. When the terminal supports
+// neither protocol it falls back to RenderImageToText.
+func RenderImageSixel(filePath string, maxWidth, maxHeight int, opts ...RenderOption) (string, error) {
+	protocol := DetectImageProtocol()
+	if protocol == ProtocolNone {
+		return RenderImageToText(filePath, maxWidth, maxHeight, opts...)
+	}
+
+	cfg := newRenderConfig(opts)
+	img, err := decodeImageFile(filePath, cfg)
+	if err != nil {
+		return "", err
+	}
+	w, h := fitSize(img.Bounds(), maxWidth*CellPixelWidth, maxHeight*CellPixelHeight)
+	resized := resize(img, w, h, cfg)
+
+	if protocol == ProtocolITerm2 {
+		return encodeITerm2(resized, maxWidth)
+	}
+	return encodeSixel(resized), nil
+}
+
+// encodeITerm2 emits the iTerm2 inline image escape sequence for img as PNG.
+func encodeITerm2(img image.Image, widthCells int) (string, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return "", fmt.Errorf("cannot encode image: %w", err)
+	}
+
+	cells := (img.Bounds().Dx() + CellPixelWidth - 1) / CellPixelWidth
+	if cells > widthCells {
+		cells = widthCells
+	}
+
+	return fmt.Sprintf("\033]1337;File=inline=1;size=%d;width=%d;preserveAspectRatio=1:%s\a\n",
+		buf.Len(), cells, base64.StdEncoding.EncodeToString(buf.Bytes())), nil
+}
+
+// encodeSixel encodes img as a Sixel escape sequence using a fixed 6×6×6
+// color cube palette.
+func encodeSixel(img image.Image) string {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+
+	// Quantize every pixel to the color cube
+	indexes := make([]uint8, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			indexes[y*w+x] = sixelColor(rgbComponents(img.At(bounds.Min.X+x, bounds.Min.Y+y)))
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString("\033Pq")
+	sb.WriteString(fmt.Sprintf("\"1;1;%d;%d", w, h))
+	for i := 0; i < 216; i++ {
+		sb.WriteString(fmt.Sprintf("#%d;2;%d;%d;%d", i, i/36*20, i/6%6*20, i%6*20))
+	}
+
+	// Sixel rows are bands of six pixel rows; each color in a band is drawn
+	// as its own pass, returning to the start of the band with '$'.
+	for y := 0; y < h; y += 6 {
+		var used [216]bool
+		for dy := 0; dy < 6 && y+dy < h; dy++ {
+			for x := 0; x < w; x++ {
+				used[indexes[(y+dy)*w+x]] = true
+			}
+		}
+
+		for c := 0; c < 216; c++ {
+			if !used[c] {
+				continue
+			}
+			sb.WriteString(fmt.Sprintf("#%d", c))
+
+			var run byte
+			runLen := 0
+			for x := 0; x < w; x++ {
+				var bits byte
+				for dy := 0; dy < 6 && y+dy < h; dy++ {
+					if int(indexes[(y+dy)*w+x]) == c {
+						bits |= 1 << dy
+					}
+				}
+				ch := 63 + bits
+				if ch == run {
+					runLen++
+					continue
+				}
+				writeSixelRun(&sb, run, runLen)
+				run, runLen = ch, 1
+			}
+			writeSixelRun(&sb, run, runLen)
+			sb.WriteByte('$')
+		}
+		sb.WriteByte('-')
+	}
+
+	sb.WriteString("\033\\\n")
+	return sb.String()
+}
+
+// sixelColor returns the index of the palette color nearest to r, g, b: each
+// channel is rounded to the closest of the cube's six levels.
+func sixelColor(r, g, b uint8) uint8 {
+	level := func(v uint8) int { return (int(v)*5 + 127) / 255 }
+	return uint8(level(r)*36 + level(g)*6 + level(b))
+}
+
+// writeSixelRun writes n repetitions of a sixel character, using the
+// repeat introducer for longer runs.
+func writeSixelRun(sb *strings.Builder, ch byte, n int) {
+	switch {
+	case n <= 0:
+	case n > 3:
+		sb.WriteString(fmt.Sprintf("!%d%c", n, ch))
+	default:
+		for i := 0; i < n; i++ {
+			sb.WriteByte(ch)
+		}
+	}
+}
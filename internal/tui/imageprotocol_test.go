@@ -0,0 +1,96 @@
+package tui
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetectImageProtocol(t *testing.T) {
+	tests := []struct {
+		termProgram string
+		term        string
+		want        ImageProtocol
+	}{
+		{"iTerm.app", "xterm-256color", ProtocolITerm2},
+		{"WezTerm", "xterm-256color", ProtocolITerm2},
+		{"WezTerm", "foot", ProtocolITerm2},
+		{"Apple_Terminal", "xterm-256color", ProtocolNone},
+		{"", "foot", ProtocolSixel},
+		{"", "foot-extra", ProtocolSixel},
+		{"", "mlterm", ProtocolSixel},
+		{"", "xterm-sixel", ProtocolSixel},
+		{"", "screen.sixel", ProtocolSixel},
+		{"", "xterm-256color", ProtocolNone},
+		{"", "", ProtocolNone},
+	}
+	for _, tt := range tests {
+		t.Run(tt.termProgram+"/"+tt.term, func(t *testing.T) {
+			t.Setenv("TERM_PROGRAM", tt.termProgram)
+			t.Setenv("TERM", tt.term)
+			if got := DetectImageProtocol(); got != tt.want {
+				t.Errorf("DetectImageProtocol() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSixelColor(t *testing.T) {
+	tests := []struct {
+		r, g, b uint8
+		want    uint8
+	}{
+		{0, 0, 0, 0},
+		{255, 255, 255, 215},
+		{255, 0, 0, 180},
+		// Channels round to the nearest of 0, 51, 102, 153, 204 and 255.
+		{25, 0, 0, 0},
+		{26, 0, 0, 36},
+		{0, 128, 0, 3 * 6},
+		{0, 0, 230, 5},
+		{0, 0, 229, 4},
+	}
+	for _, tt := range tests {
+		if got := sixelColor(tt.r, tt.g, tt.b); got != tt.want {
+			t.Errorf("sixelColor(%d, %d, %d) = %d, want %d", tt.r, tt.g, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestRenderImageSixelGradient(t *testing.T) {
+	path := writeGradient(t, 256, 128)
+
+	tests := []struct {
+		name        string
+		termProgram string
+		term        string
+		golden      string
+	}{
+		{"sixel", "", "foot", "gradient-sixel.golden"},
+		{"iterm2", "iTerm.app", "xterm-256color", "gradient-iterm2.golden"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TERM_PROGRAM", tt.termProgram)
+			t.Setenv("TERM", tt.term)
+			got, err := RenderImageSixel(path, 4, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			golden := filepath.Join("testdata", tt.golden)
+			if *update {
+				if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%v (run with -update to create it)", err)
+			}
+			if got != string(want) {
+				t.Errorf("RenderImageSixel output differs from %s\ngot:\n%q\nwant:\n%q", golden, got, want)
+			}
+		})
+	}
+}
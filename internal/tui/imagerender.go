@@ -15,6 +15,7 @@ import (
 	"strings"
 
 	_ "golang.org/x/image/bmp"
+	xdraw "golang.org/x/image/draw"
 	_ "golang.org/x/image/tiff"
 	_ "golang.org/x/image/webp"
 )
@@ -27,11 +28,58 @@ import (
 // maxWidth and maxHeight are in terminal cells. The image is scaled to fit
 // within these bounds while preserving aspect ratio. maxHeight is in cell rows
 // (each row = 2 pixels).
-func RenderImageToText(filePath string, maxWidth, maxHeight int) (string, error) {
-	// Handle HEIC/HEIF by converting via sips (macOS built-in)
-	actualPath, cleanup, err := ensureDecodable(filePath)
+func RenderImageToText(filePath string, maxWidth, maxHeight int, opts ...RenderOption) (string, error) {
+	img, err := loadScaledImage(filePath, maxWidth, maxHeight, opts)
 	if err != nil {
-		return "", fmt.Errorf("cannot prepare image: %w", err)
+		return "", err
+	}
+
+	// tview uses #RRGGBB hex color tags
+	return renderHalfBlocks(img, func(sb *strings.Builder, tr, tg, tb, br, bg, bb uint8) {
+		sb.WriteString(fmt.Sprintf("[#%02x%02x%02x:#%02x%02x%02x]▀[-:-]",
+			tr, tg, tb, br, bg, bb))
+	}, ""), nil
+}
+
+// RenderImageToANSI renders an image file like RenderImageToText, but using
+// 24-bit ANSI escape sequences so it can be printed directly to a terminal.
+func RenderImageToANSI(filePath string, maxWidth, maxHeight int, opts ...RenderOption) (string, error) {
+	img, err := loadScaledImage(filePath, maxWidth, maxHeight, opts)
+	if err != nil {
+		return "", err
+	}
+
+	return renderHalfBlocks(img, func(sb *strings.Builder, tr, tg, tb, br, bg, bb uint8) {
+		sb.WriteString(fmt.Sprintf("\033[38;2;%d;%d;%dm\033[48;2;%d;%d;%dm▀",
+			tr, tg, tb, br, bg, bb))
+	}, "\033[0m"), nil
+}
+
+// loadScaledImage decodes an image file and scales it to fit within maxWidth
+// cells and maxHeight rows (two pixels per row).
+func loadScaledImage(filePath string, maxWidth, maxHeight int, opts []RenderOption) (image.Image, error) {
+	cfg := newRenderConfig(opts)
+	img, err := decodeImageFile(filePath, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// maxHeight is in rows; each row = 2 pixels
+	targetW, targetH := fitSize(img.Bounds(), maxWidth, maxHeight*2)
+	// Make targetH even for clean half-block pairing
+	if targetH%2 != 0 {
+		targetH++
+	}
+
+	return resize(img, targetW, targetH, cfg), nil
+}
+
+// decodeImageFile decodes an image file, converting HEIC/HEIF first.
+func decodeImageFile(filePath string, cfg renderConfig) (image.Image, error) {
+	// Handle HEIC/HEIF by converting to JPEG first
+	actualPath, cleanup, err := ensureDecodable(filePath, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("cannot prepare image: %w", err)
 	}
 	if cleanup != nil {
 		defer cleanup()
@@ -39,25 +87,25 @@ func RenderImageToText(filePath string, maxWidth, maxHeight int) (string, error)
 
 	f, err := os.Open(actualPath)
 	if err != nil {
-		return "", fmt.Errorf("cannot open image: %w", err)
+		return nil, fmt.Errorf("cannot open image: %w", err)
 	}
 	defer f.Close()
 
 	img, _, err := image.Decode(f)
 	if err != nil {
-		return "", fmt.Errorf("cannot decode image: %w", err)
+		return nil, fmt.Errorf("cannot decode image: %w", err)
 	}
+	return img, nil
+}
 
-	// Scale image to fit within bounds
-	bounds := img.Bounds()
+// fitSize returns the size of bounds scaled to fit within maxW×maxH pixels,
+// preserving aspect ratio and never upscaling.
+func fitSize(bounds image.Rectangle, maxW, maxH int) (int, int) {
 	imgW := bounds.Dx()
 	imgH := bounds.Dy()
 
-	// maxHeight is in rows; each row = 2 pixels
-	maxPixH := maxHeight * 2
-
-	scaleX := float64(maxWidth) / float64(imgW)
-	scaleY := float64(maxPixH) / float64(imgH)
+	scaleX := float64(maxW) / float64(imgW)
+	scaleY := float64(maxH) / float64(imgH)
 	scale := math.Min(scaleX, scaleY)
 	if scale > 1.0 {
 		scale = 1.0 // don't upscale
@@ -71,49 +119,133 @@ func RenderImageToText(filePath string, maxWidth, maxHeight int) (string, error)
 	if targetH < 1 {
 		targetH = 1
 	}
-	// Make targetH even for clean half-block pairing
-	if targetH%2 != 0 {
-		targetH++
-	}
-
-	// Simple nearest-neighbor resize
-	resized := resizeNearest(img, targetW, targetH)
+	return targetW, targetH
+}
 
-	// Render using half-block characters with tview color tags
+// renderHalfBlocks walks the image two rows at a time, calling cell to write
+// each half-block with its top and bottom colors. lineEnd is written before
+// each newline (e.g. to reset terminal colors).
+func renderHalfBlocks(img image.Image, cell func(sb *strings.Builder, tr, tg, tb, br, bg, bb uint8), lineEnd string) string {
+	bounds := img.Bounds()
 	var sb strings.Builder
-	for y := 0; y < targetH; y += 2 {
-		for x := 0; x < targetW; x++ {
-			top := colorAt(resized, x, y)
-			bot := colorAt(resized, x, y+1)
+	for y := 0; y < bounds.Dy(); y += 2 {
+		for x := 0; x < bounds.Dx(); x++ {
+			top := colorAt(img, x, y)
+			bot := colorAt(img, x, y+1)
 
 			tr, tg, tb := rgbComponents(top)
 			br, bg, bb := rgbComponents(bot)
-
-			// tview uses #RRGGBB hex color tags
-			sb.WriteString(fmt.Sprintf("[#%02x%02x%02x:#%02x%02x%02x]▀[-:-]",
-				tr, tg, tb, br, bg, bb))
+			cell(&sb, tr, tg, tb, br, bg, bb)
 		}
+		sb.WriteString(lineEnd)
 		sb.WriteString("\n")
 	}
+	return sb.String()
+}
+
+// ImageConverter converts the image at inPath to a JPEG at outPath.
+type ImageConverter func(inPath, outPath string) error
 
-	return sb.String(), nil
+// RenderOption configures image rendering.
+type RenderOption func(*renderConfig)
+
+type renderConfig struct {
+	converter  ImageConverter
+	fastResize bool
+}
+
+func newRenderConfig(opts []RenderOption) renderConfig {
+	var cfg renderConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// WithFastResize uses nearest-neighbor scaling instead of the default
+// Catmull-Rom resampling. It's blockier but much cheaper for large images.
+func WithFastResize() RenderOption {
+	return func(cfg *renderConfig) {
+		cfg.fastResize = true
+	}
+}
+
+// WithConverter overrides how HEIC/HEIF images are converted before decoding.
+func WithConverter(c ImageConverter) RenderOption {
+	return func(cfg *renderConfig) {
+		cfg.converter = c
+	}
+}
+
+// CommandConverter returns an ImageConverter that runs an external command.
+// The placeholders {in} and {out} in args are replaced with the input and
+// output paths, e.g. CommandConverter("magick", "{in}", "{out}").
+func CommandConverter(name string, args ...string) ImageConverter {
+	return func(inPath, outPath string) error {
+		expanded := make([]string, len(args))
+		for i, a := range args {
+			a = strings.ReplaceAll(a, "{in}", inPath)
+			expanded[i] = strings.ReplaceAll(a, "{out}", outPath)
+		}
+		output, err := exec.Command(name, expanded...).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("%s conversion failed: %w: %s", name, err, strings.TrimSpace(string(output)))
+		}
+		return nil
+	}
+}
+
+// heicConverters are tried in order when no custom converter is set. sips
+// ships with macOS; heif-convert (libheif) and ffmpeg cover other systems.
+var heicConverters = []struct {
+	name string
+	args []string
+}{
+	{"sips", []string{"-s", "format", "jpeg", "{in}", "--out", "{out}"}},
+	{"heif-convert", []string{"{in}", "{out}"}},
+	{"ffmpeg", []string{"-y", "-loglevel", "error", "-i", "{in}", "{out}"}},
 }
 
-// ensureDecodable converts HEIC/HEIF files to JPEG using macOS sips.
-// Returns the path to use for decoding and an optional cleanup function.
-func ensureDecodable(filePath string) (string, func(), error) {
+// defaultHEICConverter returns the first available HEIC converter, or an
+// actionable error if none is installed.
+func defaultHEICConverter() (ImageConverter, error) {
+	for _, c := range heicConverters {
+		if _, err := exec.LookPath(c.name); err == nil {
+			return CommandConverter(c.name, c.args...), nil
+		}
+	}
+	return nil, fmt.Errorf("no HEIC converter found: install libheif (heif-convert) or ffmpeg, or supply one with WithConverter (sips is only available on macOS)")
+}
+
+// ensureDecodable converts HEIC/HEIF files to JPEG, since Go can't decode
+// them natively. Returns the path to use for decoding and an optional
+// cleanup function.
+func ensureDecodable(filePath string, cfg renderConfig) (string, func(), error) {
 	ext := strings.ToLower(filepath.Ext(filePath))
 	if ext != ".heic" && ext != ".heif" {
 		return filePath, nil, nil
 	}
 
-	// Create temp file for conversion
-	tmpFile := filepath.Join(os.TempDir(), fmt.Sprintf("imsg-preview-%d.jpg", os.Getpid()))
+	convert := cfg.converter
+	if convert == nil {
+		var err error
+		if convert, err = defaultHEICConverter(); err != nil {
+			return "", nil, err
+		}
+	}
 
-	// sips is available on all macOS systems
-	cmd := exec.Command("sips", "-s", "format", "jpeg", filePath, "--out", tmpFile)
-	if err := cmd.Run(); err != nil {
-		return "", nil, fmt.Errorf("sips conversion failed: %w", err)
+	// Create a unique temp file for conversion; inline previews may convert
+	// several images concurrently
+	tmp, err := os.CreateTemp("", "imsg-preview-*.jpg")
+	if err != nil {
+		return "", nil, fmt.Errorf("cannot create temp file: %w", err)
+	}
+	tmpFile := tmp.Name()
+	tmp.Close()
+
+	if err := convert(filePath, tmpFile); err != nil {
+		os.Remove(tmpFile)
+		return "", nil, err
 	}
 
 	cleanup := func() {
@@ -122,6 +254,24 @@ func ensureDecodable(filePath string) (string, func(), error) {
 	return tmpFile, cleanup, nil
 }
 
+// resize scales img to w×h, using Catmull-Rom resampling unless fast
+// resizing was requested.
+func resize(img image.Image, w, h int, cfg renderConfig) image.Image {
+	if cfg.fastResize {
+		return resizeNearest(img, w, h)
+	}
+	return resizeSmooth(img, w, h)
+}
+
+// resizeSmooth resizes with Catmull-Rom resampling, which averages over the
+// source pixels instead of sampling one, so heavily downscaled photos keep
+// their detail instead of turning blocky.
+func resizeSmooth(img image.Image, w, h int) image.Image {
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	xdraw.CatmullRom.Scale(dst, dst.Bounds(), img, img.Bounds(), xdraw.Src, nil)
+	return dst
+}
+
 // resizeNearest performs nearest-neighbor image resize.
 func resizeNearest(img image.Image, w, h int) image.Image {
 	bounds := img.Bounds()
@@ -0,0 +1,95 @@
+package tui
+
+import (
+	"flag"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files in testdata")
+
+// writeGradient writes a w×h PNG whose red channel ramps left to right and
+// green channel ramps top to bottom, and returns its path.
+func writeGradient(tb testing.TB, w, h int) string {
+	tb.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{
+				R: uint8(x * 255 / (w - 1)),
+				G: uint8(y * 255 / (h - 1)),
+				B: 128,
+				A: 255,
+			})
+		}
+	}
+
+	path := filepath.Join(tb.TempDir(), "gradient.png")
+	f, err := os.Create(path)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		tb.Fatal(err)
+	}
+	return path
+}
+
+func TestRenderImageToANSIGradient(t *testing.T) {
+	path := writeGradient(t, 256, 128)
+
+	tests := []struct {
+		name   string
+		golden string
+		opts   []RenderOption
+	}{
+		{"smooth", "gradient-smooth.golden", nil},
+		{"nearest", "gradient-nearest.golden", []RenderOption{WithFastResize()}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RenderImageToANSI(path, 16, 4, tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			golden := filepath.Join("testdata", tt.golden)
+			if *update {
+				if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%v (run with -update to create it)", err)
+			}
+			if got != string(want) {
+				t.Errorf("RenderImageToANSI output differs from %s\ngot:\n%q\nwant:\n%q", golden, got, want)
+			}
+		})
+	}
+}
+
+func BenchmarkRenderImageToANSI(b *testing.B) {
+	path := writeGradient(b, 1024, 768)
+
+	b.Run("smooth", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := RenderImageToANSI(path, 80, 24); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("nearest", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := RenderImageToANSI(path, 80, 24, WithFastResize()); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,20 @@
+// Package tui provides text helpers shared by the TUI views.
+package tui
+
+import (
+	"strings"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// truncateWidth shortens text to at most maxWidth terminal columns, ending
+// with "..." when cut. Wide characters (emoji, CJK) count as two columns and
+// are never split.
+func truncateWidth(text string, maxWidth int) string {
+	return runewidth.Truncate(text, maxWidth, "...")
+}
+
+// replyPreview flattens and shortens a reply originator's text for quoting.
+func replyPreview(text string) string {
+	return truncateWidth(strings.Join(strings.Fields(text), " "), ReplyPreviewLength)
+}
@@ -0,0 +1,61 @@
+package tui
+
+import (
+	"testing"
+	"unicode/utf8"
+
+	"github.com/mattn/go-runewidth"
+)
+
+func TestTruncateWidth(t *testing.T) {
+	const family = "👨‍👩‍👧‍👦" // one grapheme, two columns
+
+	tests := []struct {
+		name string
+		text string
+		max  int
+		want string
+	}{
+		{"fits", "hello", 5, "hello"},
+		{"ascii", "hello world", 8, "hello..."},
+		{"cjk fits", "日本語", 6, "日本語"},
+		{"cjk", "日本語テキスト", 9, "日本語..."},
+		{"cut mid wide rune", "日本語テキスト", 8, "日本..."},
+		{"cut mid wide rune after ascii", "ab日本", 5, "ab..."},
+		{"emoji", "hi 😀😀😀", 8, "hi 😀..."},
+		{"zwj fits", family + " family", 9, family + " family"},
+		{"zwj kept whole", family + " family", 6, family + " ..."},
+		{"zwj dropped whole", "ab" + family + "cd", 5, "ab..."},
+		{"combining mark", "cafe\u0301 au lait", 7, "cafe\u0301..."},
+		{"empty", "", 5, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := truncateWidth(tt.text, tt.max)
+			if got != tt.want {
+				t.Errorf("truncateWidth(%q, %d) = %q, want %q", tt.text, tt.max, got, tt.want)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("truncateWidth(%q, %d) = %q is not valid UTF-8", tt.text, tt.max, got)
+			}
+			if w := runewidth.StringWidth(got); w > tt.max {
+				t.Errorf("truncateWidth(%q, %d) is %d columns wide", tt.text, tt.max, w)
+			}
+		})
+	}
+}
+
+func TestReplyPreview(t *testing.T) {
+	long := "日本語のテキストはとても長いのでここで切り詰められるはずです"
+	got := replyPreview(long)
+	if w := runewidth.StringWidth(got); w > ReplyPreviewLength {
+		t.Errorf("replyPreview is %d columns wide, want at most %d", w, ReplyPreviewLength)
+	}
+	if !utf8.ValidString(got) {
+		t.Errorf("replyPreview(%q) = %q is not valid UTF-8", long, got)
+	}
+
+	if got, want := replyPreview("line one\n\n  line   two"), "line one line two"; got != want {
+		t.Errorf("replyPreview flattened to %q, want %q", got, want)
+	}
+}
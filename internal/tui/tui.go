@@ -5,14 +5,21 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"syscall"
 	"time"
 
+	"github.com/danewalton/imessage-cli/internal/clipboard"
+	"github.com/danewalton/imessage-cli/internal/config"
+	"github.com/danewalton/imessage-cli/internal/database"
+	"github.com/danewalton/imessage-cli/internal/outbox"
 	"github.com/danewalton/imessage-cli/internal/sender"
+	"github.com/danewalton/imessage-cli/internal/timefmt"
 	"github.com/danewalton/imessage-cli/internal/watcher"
 	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
@@ -25,9 +32,17 @@ const (
 	MaxDisplayNameLength     = 30
 	MaxSenderNameLength      = 15
 	MessageRefreshDelay      = 500 * time.Millisecond
+	DraftIdleResume          = 3 * time.Second
 	LockFileName             = ".imessage-tui.lock"
 	PreviewMaxWidth          = 80
 	PreviewMaxHeight         = 30
+	ErrorStatusThrottle      = 30 * time.Second
+	SearchResultLimit        = 50
+	SearchMaxWidth           = 100
+	SearchMaxHeight          = 30
+	FilterBoxWidth           = 50
+	InlinePreviewMaxHeight   = 12
+	ReplyPreviewLength       = 50
 )
 
 // MessagesTUI is the main TUI application.
@@ -46,12 +61,58 @@ type MessagesTUI struct {
 	selectedChatID  int64
 	selectedChatIdx int
 	previewModal    *tview.TextView
+	// openedConvs holds conversations opened from search results, which
+	// may be older than the loaded list, so sending to them and switching
+	// back with o still work. Guarded by mu; see conversation.
+	openedConvs map[int64]watcher.Conversation
+	// convFilter narrows the conversation list; visibleConvs maps each list
+	// row to its index in conversations.
+	convFilter   string
+	visibleConvs []int
+	// draftPaused is set while the watcher is paused because a draft is
+	// being typed. draftEdits counts edits to the draft, so an idle timer
+	// that fires after a newer keystroke knows to do nothing. statusMsg is
+	// the status bar text without the paused indicator. Only touched on
+	// the UI goroutine.
+	draftPaused bool
+	draftEdits  int
+	statusMsg   string
+	// msgView lines map to messages through regions: shownMsgIDs lists the
+	// rendered messages in display order and selectedMsgID is the
+	// highlighted one. Only touched on the UI goroutine.
+	shownMsgIDs   []int64
+	selectedMsgID int64
+	// followEnd is set while msgView is scrolled to the end, so new
+	// messages scroll it along. scrollOffsets keeps the row each
+	// conversation was left scrolled to (conversations left at the end
+	// aren't kept), shownChatID is the conversation on screen and
+	// prevChatID the one before it, for o. Only touched on the UI goroutine.
+	followEnd     bool
+	scrollOffsets map[int64]int
+	shownChatID   int64
+	prevChatID    int64
 
 	mu sync.RWMutex
 	// sendingMessage tracks whether a message send is in progress
 	sendingMessage atomic.Bool
 	// refreshing tracks whether a refresh is in progress
 	refreshing atomic.Bool
+	// inline image previews: showImages toggles them, msgViewWidth is the
+	// message panel's inner width as of the last draw, and imageCache holds
+	// rendered previews keyed by path and width.
+	showImages   atomic.Bool
+	msgViewWidth atomic.Int64
+	imageMu      sync.Mutex
+	imageCache   map[string]string
+	// exactTimes shows message times to the second (toggled with t)
+	exactTimes atomic.Bool
+	// lastSentTo and lastSentAt are the chat and time of the last message
+	// sent from the TUI, for u (undo send); guarded by mu
+	lastSentTo string
+	lastSentAt time.Time
+	// lastError/lastErrorAt throttle repeated watcher errors in the status bar
+	lastError   string
+	lastErrorAt time.Time
 	// logging
 	logger  *log.Logger
 	logFile *os.File
@@ -60,39 +121,175 @@ type MessagesTUI struct {
 
 // NewMessagesTUI creates a new TUI instance.
 func NewMessagesTUI() *MessagesTUI {
-	return &MessagesTUI{
-		watcher: watcher.NewMessageWatcher(500 * time.Millisecond),
+	t := &MessagesTUI{
+		watcher:       watcher.NewMessageWatcher(500 * time.Millisecond),
+		imageCache:    make(map[string]string),
+		followEnd:     true,
+		scrollOffsets: make(map[int64]int),
+		openedConvs:   make(map[int64]watcher.Conversation),
 	}
+	t.showImages.Store(true)
+	return t
+}
+
+// LockPathEnv overrides the lock file location, e.g. to run one instance per
+// account.
+const LockPathEnv = "IMESSAGE_TUI_LOCK"
+
+// lockPathOverride is set by SetLockPath and takes precedence over LockPathEnv.
+var lockPathOverride string
+
+// SetLockPath overrides the lock file location. An empty path restores the
+// default (LockPathEnv, then ~/LockFileName).
+func SetLockPath(path string) {
+	lockPathOverride = path
+}
+
+// absoluteTimes shows full timestamps instead of relative ones.
+var absoluteTimes bool
+
+// SetAbsoluteTimes makes the TUI show full timestamps instead of relative
+// ones ("Yesterday", "Mon").
+func SetAbsoluteTimes(absolute bool) {
+	absoluteTimes = absolute
+}
+
+// contactRefresh is how often contacts are re-read from the AddressBook;
+// zero leaves them as loaded at startup.
+var contactRefresh time.Duration
+
+// SetContactRefresh makes the TUI re-read contacts every interval so new or
+// renamed contacts show up without a restart. Zero turns it off.
+func SetContactRefresh(interval time.Duration) {
+	contactRefresh = interval
+}
+
+// Colors are the TUI colors users can change, e.g. for light-background
+// terminals.
+type Colors struct {
+	Me        tcell.Color // my messages
+	Them      tcell.Color // everyone else's messages
+	StatusBar tcell.Color // status bar background
+}
+
+// DefaultColors are used unless SetColors overrides them.
+var DefaultColors = Colors{
+	Me:        tcell.ColorGreen,
+	Them:      tcell.ColorAqua,
+	StatusBar: tcell.ColorDarkGreen,
+}
+
+// colors is the palette set with SetColors.
+var colors = DefaultColors
+
+// SetColors sets the colors the TUI draws with. Call it before Run.
+func SetColors(c Colors) {
+	colors = c
+}
+
+// colorAliases are common color names tcell only knows by their W3C name.
+var colorAliases = map[string]string{
+	"cyan":    "aqua",
+	"magenta": "fuchsia",
+}
+
+// ParseColor maps a color name such as "blue" or "darkorange", or a hex
+// value such as "#ff8800", to a tcell color.
+func ParseColor(name string) (tcell.Color, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if alias, ok := colorAliases[name]; ok {
+		name = alias
+	}
+	c := tcell.GetColor(name)
+	if c == tcell.ColorDefault {
+		return c, fmt.Errorf("unknown color %q (use a name like blue or a hex value like #ff8800)", name)
+	}
+	return c, nil
+}
+
+// colorTag returns the tview style tag that switches to c.
+func colorTag(c tcell.Color) string {
+	return "[" + c.String() + "]"
+}
+
+// lockFilePath returns the lock file to use.
+func lockFilePath() (string, error) {
+	if lockPathOverride != "" {
+		return lockPathOverride, nil
+	}
+	if path := os.Getenv(LockPathEnv); path != "" {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("cannot get home directory: %w", err)
+	}
+	return filepath.Join(home, LockFileName), nil
 }
 
 // acquireLock attempts to acquire an exclusive lock to prevent multiple instances.
 // Returns the lock file handle (caller must close it) or an error.
 func acquireLock() (*os.File, error) {
-	home, err := os.UserHomeDir()
+	lockPath, err := lockFilePath()
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := tryLock(lockPath)
+	if err != nil && lockIsStale(lockPath) {
+		// The owner is gone but the lock wasn't released (this happens on
+		// some NFS homes). Replace the file so the next flock gets a fresh
+		// inode.
+		os.Remove(lockPath)
+		f, err = tryLock(lockPath)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("cannot get home directory: %w", err)
+		return nil, err
 	}
 
-	lockPath := filepath.Join(home, LockFileName)
+	// Write PID to lock file for debugging
+	f.Truncate(0)
+	f.Seek(0, 0)
+	fmt.Fprintf(f, "%d\n", os.Getpid())
+	f.Sync()
+
+	return f, nil
+}
+
+// tryLock opens lockPath and takes a non-blocking exclusive flock on it.
+func tryLock(lockPath string) (*os.File, error) {
 	f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_RDWR, 0600)
 	if err != nil {
 		return nil, fmt.Errorf("cannot open lock file: %w", err)
 	}
 
-	// Try to acquire an exclusive lock (non-blocking)
 	err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
 	if err != nil {
 		f.Close()
 		return nil, fmt.Errorf("another instance of imessage-tui is already running (lock file: %s)", lockPath)
 	}
+	return f, nil
+}
 
-	// Write PID to lock file for debugging
-	f.Truncate(0)
-	f.Seek(0, 0)
-	fmt.Fprintf(f, "%d\n", os.Getpid())
-	f.Sync()
+// lockIsStale reports whether the lock file names a PID that no longer exists.
+func lockIsStale(lockPath string) bool {
+	data, err := os.ReadFile(lockPath)
+	if err != nil {
+		return false
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 || pid == os.Getpid() {
+		return false
+	}
+	return syscall.Kill(pid, 0) == syscall.ESRCH
+}
 
-	return f, nil
+// releaseLock removes the lock file and releases the flock on it. The file is
+// removed while still locked so another instance can't grab it in between.
+func releaseLock(f *os.File) {
+	os.Remove(f.Name())
+	syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+	f.Close()
 }
 
 // RunWithDebug runs the TUI with optional debug logging to the provided path.
@@ -102,10 +299,7 @@ func RunWithDebug(enable bool, logPath string) error {
 	if err != nil {
 		return err
 	}
-	defer func() {
-		syscall.Flock(int(lockFile.Fd()), syscall.LOCK_UN)
-		lockFile.Close()
-	}()
+	defer releaseLock(lockFile)
 
 	t := NewMessagesTUI()
 	t.debug = enable
@@ -119,6 +313,8 @@ func RunWithDebug(enable bool, logPath string) error {
 		}
 		t.logFile = f
 		t.logger = log.New(f, "tui: ", log.LstdFlags|log.Lmicroseconds)
+		database.SetLogger(log.New(f, "database: ", log.LstdFlags|log.Lmicroseconds))
+		sender.SetLogger(log.New(f, "sender: ", log.LstdFlags|log.Lmicroseconds))
 		t.logf("debug logging enabled, file=%s", logPath)
 	}
 	defer func() {
@@ -138,10 +334,7 @@ func Run() error {
 	if err != nil {
 		return err
 	}
-	defer func() {
-		syscall.Flock(int(lockFile.Fd()), syscall.LOCK_UN)
-		lockFile.Close()
-	}()
+	defer releaseLock(lockFile)
 
 	tui := NewMessagesTUI()
 	return tui.run()
@@ -162,8 +355,10 @@ func (t *MessagesTUI) run() error {
 	t.convList.SetBorder(true).SetTitle(" Conversations ")
 
 	// Create message view
+	// Each message line is a region so one can be selected (by clicking)
 	t.msgView = tview.NewTextView().
 		SetDynamicColors(true).
+		SetRegions(true).
 		SetScrollable(true).
 		SetWrap(true).
 		SetWordWrap(true)
@@ -180,8 +375,8 @@ func (t *MessagesTUI) run() error {
 	t.statusBar = tview.NewTextView().
 		SetDynamicColors(true).
 		SetTextAlign(tview.AlignCenter)
-	t.statusBar.SetBackgroundColor(tcell.ColorDarkGreen)
-	t.setStatus("↑↓:Nav  Enter:Select  Tab:Switch  i:Input  r:Refresh  q:Quit")
+	t.statusBar.SetBackgroundColor(colors.StatusBar)
+	t.setStatus("↑↓:Nav  Enter:Select  Tab:Switch  o:Last chat  i:Input  f:Filter  /:Search  r:Refresh  q:Quit")
 
 	// Layout
 	rightPanel := tview.NewFlex().SetDirection(tview.FlexRow).
@@ -201,7 +396,17 @@ func (t *MessagesTUI) run() error {
 
 	// Setup watcher
 	t.watcher.OnNewMessages(t.onNewMessages)
+	t.watcher.OnMessagesAppended(t.onMessagesAppended)
 	t.watcher.OnConversationsUpdated(t.onConversationsUpdated)
+	t.watcher.OnError(t.onWatcherError)
+	// Messages that arrived since the last run are delivered on start, so
+	// the status bar reports them.
+	t.watcher.SetStateFile(watcher.DefaultStatePath())
+	if cfg, err := config.Load(); err == nil {
+		t.watcher.SetMuted(cfg.Muted)
+	} else {
+		t.logf("run: %v", err)
+	}
 
 	// Load initial data synchronously (before app.Run)
 	t.loadInitialData()
@@ -226,10 +431,39 @@ func (t *MessagesTUI) run() error {
 		t.watcher.Stop()
 	}()
 
+	if contactRefresh > 0 {
+		stop := database.AutoReloadContacts(contactRefresh)
+		defer stop()
+	}
+
 	// Run the application
 	if t.logger != nil {
 		t.logf("run: entering app.Run()")
 	}
+	// Track the message panel width for sizing inline image previews
+	t.app.SetAfterDrawFunc(func(screen tcell.Screen) {
+		_, _, width, _ := t.msgView.GetInnerRect()
+		t.msgViewWidth.Store(int64(width))
+	})
+
+	// Stop cleanly on SIGINT/SIGTERM, or SIGHUP when an SSH session drops, so
+	// the deferred watcher shutdown and lock release still run.
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(sigCh)
+	runDone := make(chan struct{})
+	defer close(runDone)
+	go func() {
+		select {
+		case sig := <-sigCh:
+			if t.logger != nil {
+				t.logf("run: received %v, stopping", sig)
+			}
+			t.app.Stop()
+		case <-runDone:
+		}
+	}()
+
 	err := t.app.SetRoot(t.pages, true).EnableMouse(true).Run()
 	if err != nil && t.logger != nil {
 		t.logf("run: app.Run error: %v", err)
@@ -244,21 +478,70 @@ func (t *MessagesTUI) setupCallbacks() {
 	// Conversation selection
 	t.convList.SetChangedFunc(func(index int, mainText, secondaryText string, shortcut rune) {
 		t.selectedChatIdx = index
-		t.mu.RLock()
-		if index >= 0 && index < len(t.conversations) {
-			conv := t.conversations[index]
+		if conv, ok := t.conversationAt(index); ok {
 			t.selectedChatID = conv.ChatID
-			t.mu.RUnlock()
 			// Run in goroutine to avoid deadlock when called from within QueueUpdateDraw
 			go t.loadMessages(conv.ChatID)
-		} else {
-			t.mu.RUnlock()
+		}
+	})
+
+	// tview scrolls msgView itself; note when that leaves the end, so new
+	// messages don't pull the view back down (see followEnd).
+	t.msgView.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyUp, tcell.KeyPgUp, tcell.KeyCtrlB, tcell.KeyHome:
+			t.followEnd = false
+		case tcell.KeyEnd:
+			t.followEnd = true
+		}
+		return event
+	})
+	t.msgView.SetMouseCapture(func(action tview.MouseAction, event *tcell.EventMouse) (tview.MouseAction, *tcell.EventMouse) {
+		if action == tview.MouseScrollUp {
+			t.followEnd = false
+		}
+		return action, event
+	})
+
+	t.msgView.SetHighlightedFunc(func(added, removed, remaining []string) {
+		if len(added) > 0 {
+			if id, ok := messageIDFromRegion(added[0]); ok {
+				t.selectedMsgID = id
+			}
+		} else if len(remaining) == 0 && t.selectedMsgID != 0 {
+			// Clicking between messages keeps the current selection
+			t.msgView.Highlight(messageRegion(t.selectedMsgID))
 		}
 	})
 
 	t.convList.SetSelectedFunc(func(index int, mainText, secondaryText string, shortcut rune) {
 		t.app.SetFocus(t.msgView)
-		t.setStatus("[MSG] ↑↓:Scroll  h/←:Back  i:Input  p:Preview  r:Refresh  q:Quit")
+		t.setStatus("[MSG] ↑↓:Scroll  j/k:Select  h/←:Back  o:Last chat  i:Input  p:Preview  v:Images  t:Times  y:Copy  r:Refresh  q:Quit")
+	})
+
+	// Live updates are paused while a draft is being typed so redraws
+	// don't distract, and resume once typing stops for DraftIdleResume or
+	// the draft is sent or cleared; anything that arrived meanwhile shows
+	// up then.
+	t.inputField.SetChangedFunc(func(text string) {
+		t.draftEdits++
+		if text == "" {
+			t.resumeDraftUpdates()
+			return
+		}
+		if !t.draftPaused {
+			t.draftPaused = true
+			t.watcher.Pause()
+			t.setStatus(t.statusMsg)
+		}
+		edit := t.draftEdits
+		time.AfterFunc(DraftIdleResume, func() {
+			t.app.QueueUpdateDraw(func() {
+				if edit == t.draftEdits {
+					t.resumeDraftUpdates()
+				}
+			})
+		})
 	})
 
 	// Input handling
@@ -273,7 +556,7 @@ func (t *MessagesTUI) setupCallbacks() {
 			t.app.SetFocus(t.inputField)
 		} else if key == tcell.KeyEscape {
 			t.app.SetFocus(t.msgView)
-			t.setStatus("[MSG] ↑↓:Scroll  h/←:Back  i:Input  p:Preview  r:Refresh  q:Quit")
+			t.setStatus("[MSG] ↑↓:Scroll  j/k:Select  h/←:Back  o:Last chat  i:Input  p:Preview  v:Images  t:Times  y:Copy  r:Refresh  q:Quit")
 		}
 	})
 
@@ -294,21 +577,26 @@ func (t *MessagesTUI) setupCallbacks() {
 			return event
 		}
 
+		// Overlays (preview, confirmations) handle their own keys
+		if front, _ := t.pages.GetFrontPage(); front != "main" {
+			return event
+		}
+
 		switch event.Key() {
 		case tcell.KeyTab:
 			if focused == t.convList {
 				t.app.SetFocus(t.msgView)
-				t.setStatus("[MSG] ↑↓:Scroll  h/←:Back  i:Input  p:Preview  r:Refresh  q:Quit")
+				t.setStatus("[MSG] ↑↓:Scroll  j/k:Select  h/←:Back  o:Last chat  i:Input  p:Preview  v:Images  t:Times  y:Copy  r:Refresh  q:Quit")
 			} else {
 				t.app.SetFocus(t.convList)
-				t.setStatus("[CONV] ↑↓:Nav  Enter:Select  Tab:Switch  i:Input  r:Refresh  q:Quit")
+				t.setStatus("[CONV] ↑↓:Nav  Enter:Select  Tab:Switch  o:Last chat  i:Input  f:Filter  /:Search  r:Refresh  q:Quit")
 			}
 			return nil
 
 		case tcell.KeyRune:
 			switch event.Rune() {
 			case 'q', 'Q':
-				t.app.Stop()
+				t.quit()
 				return nil
 			case 'i':
 				t.app.SetFocus(t.inputField)
@@ -317,40 +605,66 @@ func (t *MessagesTUI) setupCallbacks() {
 			case 'r', 'R':
 				t.refresh()
 				return nil
+			case 'C':
+				t.reloadContacts()
+				return nil
+			case 'u':
+				t.undoSend()
+				return nil
+			case 'o':
+				t.switchToLastChat()
+				return nil
+			case '/':
+				t.showSearch()
+				return nil
+			case 'f':
+				t.showFilter()
+				return nil
 			case 'h':
 				if focused == t.msgView {
 					t.app.SetFocus(t.convList)
-					t.setStatus("[CONV] ↑↓:Nav  Enter:Select  Tab:Switch  i:Input  r:Refresh  q:Quit")
+					t.setStatus("[CONV] ↑↓:Nav  Enter:Select  Tab:Switch  o:Last chat  i:Input  f:Filter  /:Search  r:Refresh  q:Quit")
 					return nil
 				}
 			case 'l':
 				if focused == t.convList {
 					t.app.SetFocus(t.msgView)
-					t.setStatus("[MSG] ↑↓:Scroll  h/←:Back  i:Input  p:Preview  r:Refresh  q:Quit")
+					t.setStatus("[MSG] ↑↓:Scroll  j/k:Select  h/←:Back  o:Last chat  i:Input  p:Preview  v:Images  t:Times  y:Copy  r:Refresh  q:Quit")
 					return nil
 				}
 			case 'j':
 				if focused == t.msgView {
-					row, col := t.msgView.GetScrollOffset()
-					t.msgView.ScrollTo(row+1, col)
+					t.moveMessageSelection(1)
 					return nil
 				}
 			case 'k':
 				if focused == t.msgView {
-					row, col := t.msgView.GetScrollOffset()
-					if row > 0 {
-						t.msgView.ScrollTo(row-1, col)
-					}
+					t.moveMessageSelection(-1)
 					return nil
 				}
 			case 'g':
 				if focused == t.msgView {
-					t.msgView.ScrollToBeginning()
+					t.selectMessageAt(0)
 					return nil
 				}
 			case 'G':
 				if focused == t.msgView {
-					t.msgView.ScrollToEnd()
+					t.selectMessageAt(len(t.shownMsgIDs) - 1)
+					return nil
+				}
+			case 'v':
+				if focused == t.msgView {
+					t.toggleInlineImages()
+					return nil
+				}
+			case 'y':
+				if focused == t.msgView {
+					t.copySelectedMessage()
+					return nil
+				}
+			case 't':
+				if focused == t.msgView {
+					t.toggleExactTimes()
 					return nil
 				}
 			case 'p':
@@ -368,13 +682,13 @@ func (t *MessagesTUI) setupCallbacks() {
 		case tcell.KeyLeft:
 			if focused == t.msgView {
 				t.app.SetFocus(t.convList)
-				t.setStatus("[CONV] ↑↓:Nav  Enter:Select  Tab:Switch  i:Input  r:Refresh  q:Quit")
+				t.setStatus("[CONV] ↑↓:Nav  Enter:Select  Tab:Switch  o:Last chat  i:Input  f:Filter  /:Search  r:Refresh  q:Quit")
 				return nil
 			}
 		case tcell.KeyRight:
 			if focused == t.convList {
 				t.app.SetFocus(t.msgView)
-				t.setStatus("[MSG] ↑↓:Scroll  h/←:Back  i:Input  p:Preview  r:Refresh  q:Quit")
+				t.setStatus("[MSG] ↑↓:Scroll  j/k:Select  h/←:Back  o:Last chat  i:Input  p:Preview  v:Images  t:Times  y:Copy  r:Refresh  q:Quit")
 				return nil
 			}
 		}
@@ -383,17 +697,72 @@ func (t *MessagesTUI) setupCallbacks() {
 	})
 }
 
+// quit stops the app, first asking for confirmation if there's an unsent draft.
+func (t *MessagesTUI) quit() {
+	if strings.TrimSpace(t.inputField.GetText()) == "" {
+		t.app.Stop()
+		return
+	}
+
+	prevFocus := t.app.GetFocus()
+	modal := tview.NewModal().
+		SetText("Discard unsent message? (y/n)").
+		AddButtons([]string{"Discard", "Cancel"}).
+		SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+			if buttonLabel == "Discard" {
+				t.app.Stop()
+				return
+			}
+			t.pages.RemovePage("confirm-quit")
+			t.app.SetFocus(prevFocus)
+		})
+	modal.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyRune {
+			switch event.Rune() {
+			case 'y', 'Y':
+				t.app.Stop()
+				return nil
+			case 'n', 'N':
+				t.pages.RemovePage("confirm-quit")
+				t.app.SetFocus(prevFocus)
+				return nil
+			}
+		}
+		return event
+	})
+
+	t.pages.AddPage("confirm-quit", modal, false, true)
+	t.app.SetFocus(modal)
+}
+
+// setStatus shows msg in the status bar, followed by an indicator while
+// live updates are paused for a draft.
 func (t *MessagesTUI) setStatus(msg string) {
+	t.statusMsg = msg
+	if t.draftPaused {
+		msg += "  [yellow::b]⏸ live updates paused[-::-]"
+	}
 	t.statusBar.SetText(" " + msg + " ")
 }
 
 // setStatusAndDraw updates the status bar and forces an immediate redraw.
 // Use this when calling from the main event loop to ensure the status is visible.
 func (t *MessagesTUI) setStatusAndDraw(msg string) {
-	t.statusBar.SetText(" " + msg + " ")
+	t.setStatus(msg)
 	t.app.Draw()
 }
 
+// resumeDraftUpdates resumes live updates paused for a draft. Must be
+// called on the UI goroutine.
+func (t *MessagesTUI) resumeDraftUpdates() {
+	if !t.draftPaused {
+		return
+	}
+	t.draftPaused = false
+	t.watcher.Resume()
+	t.setStatus(t.statusMsg)
+}
+
 func (t *MessagesTUI) logf(format string, v ...interface{}) {
 	if t.logger != nil {
 		t.logger.Printf(format, v...)
@@ -413,12 +782,52 @@ func (t *MessagesTUI) loadInitialData() {
 	t.mu.Unlock()
 
 	// Populate UI directly (no QueueUpdateDraw needed before Run())
-	t.convList.Clear()
-	for _, conv := range convs {
-		name := conv.DisplayName
-		if len(name) > MaxDisplayNameLength {
-			name = name[:MaxDisplayNameLength-3] + "..."
+	t.populateConvList(convs)
+
+	// Load first conversation's messages
+	if len(convs) > 0 {
+		t.selectedChatID = convs[0].ChatID
+		msgs := t.watcher.GetMessages(convs[0].ChatID, DefaultMessageLimit)
+		t.renderInlineImages(msgs)
+
+		t.mu.Lock()
+		t.messages = msgs
+		t.mu.Unlock()
+
+		t.displayMessages(convs[0].DisplayName, msgs)
+		t.restoreScrollOffset(convs[0].ChatID)
+	} else {
+		t.msgView.SetText("[yellow]No conversations found. Make sure Messages is configured and Full Disk Access is granted.[-]")
+	}
+}
+
+// populateConvList fills the conversation list from convs, showing only the
+// conversations that match the current filter. Must be called on the UI
+// goroutine (or before app.Run).
+func (t *MessagesTUI) populateConvList(convs []watcher.Conversation) {
+	t.mu.Lock()
+	filter := t.convFilter
+	visible := make([]int, 0, len(convs))
+	for i, conv := range convs {
+		if filter == "" || fuzzyMatch(filter, conv.DisplayName) || fuzzyMatch(filter, conv.ChatIdentifier) {
+			visible = append(visible, i)
 		}
+	}
+	t.visibleConvs = visible
+	t.mu.Unlock()
+
+	if filter != "" {
+		t.convList.SetTitle(fmt.Sprintf(" Conversations (%s) ", filter))
+	} else {
+		t.convList.SetTitle(" Conversations ")
+	}
+
+	// Adding items can fire the changed func, which takes t.mu, so the list
+	// is populated without holding the lock.
+	t.convList.Clear()
+	for _, i := range visible {
+		conv := convs[i]
+		name := truncateWidth(conv.DisplayName, MaxDisplayNameLength)
 
 		secondary := t.formatTime(conv.LastMessageDate)
 		if conv.UnreadCount > 0 {
@@ -427,30 +836,80 @@ func (t *MessagesTUI) loadInitialData() {
 
 		t.convList.AddItem(name, secondary, 0, nil)
 	}
+}
 
-	// Load first conversation's messages
-	if len(convs) > 0 {
-		t.selectedChatID = convs[0].ChatID
-		msgs := t.watcher.GetMessages(convs[0].ChatID, DefaultMessageLimit)
+// conversationAt returns the conversation shown at the given list row.
+func (t *MessagesTUI) conversationAt(row int) (watcher.Conversation, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if row < 0 || row >= len(t.visibleConvs) {
+		return watcher.Conversation{}, false
+	}
+	idx := t.visibleConvs[row]
+	if idx >= len(t.conversations) {
+		return watcher.Conversation{}, false
+	}
+	return t.conversations[idx], true
+}
 
+// fuzzyMatch reports whether all runes of pattern appear in s in order,
+// ignoring case.
+func fuzzyMatch(pattern, s string) bool {
+	pattern = strings.ToLower(pattern)
+	s = strings.ToLower(s)
+	pi := 0
+	pr := []rune(pattern)
+	for _, r := range s {
+		if pi < len(pr) && r == pr[pi] {
+			pi++
+		}
+	}
+	return pi == len(pr)
+}
+
+// showFilter opens a box that filters the conversation list as you type.
+// Enter keeps the filter, Esc clears it and restores the full list.
+func (t *MessagesTUI) showFilter() {
+	t.mu.RLock()
+	current := t.convFilter
+	t.mu.RUnlock()
+
+	input := tview.NewInputField().
+		SetLabel("Filter: ").
+		SetLabelColor(tcell.ColorYellow).
+		SetFieldBackgroundColor(tcell.ColorBlack).
+		SetText(current)
+	input.SetBorder(true).SetBorderColor(tcell.ColorYellow)
+
+	applyFilter := func(text string) {
 		t.mu.Lock()
-		t.messages = msgs
+		t.convFilter = strings.TrimSpace(text)
+		convs := t.conversations
 		t.mu.Unlock()
+		t.populateConvList(convs)
+	}
 
-		t.msgView.SetTitle(fmt.Sprintf(" %s ", convs[0].DisplayName))
-
-		if msgs == nil {
-			t.msgView.SetText("[yellow]No messages or unable to load messages[-]")
-		} else {
-			var builder strings.Builder
-			for _, msg := range msgs {
-				t.formatMessageLine(&builder, msg)
-			}
-			t.msgView.SetText(builder.String())
+	input.SetChangedFunc(applyFilter)
+	input.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEscape {
+			applyFilter("")
 		}
-	} else {
-		t.msgView.SetText("[yellow]No conversations found. Make sure Messages is configured and Full Disk Access is granted.[-]")
-	}
+		t.pages.RemovePage("filter")
+		t.app.SetFocus(t.convList)
+		t.setStatus("[CONV] ↑↓:Nav  Enter:Select  Tab:Switch  o:Last chat  i:Input  f:Filter  /:Search  r:Refresh  q:Quit")
+	})
+
+	modal := tview.NewFlex().
+		AddItem(nil, 0, 1, false).
+		AddItem(tview.NewFlex().SetDirection(tview.FlexRow).
+			AddItem(nil, 0, 1, false).
+			AddItem(input, 3, 0, true).
+			AddItem(nil, 0, 1, false), FilterBoxWidth, 0, true).
+		AddItem(nil, 0, 1, false)
+
+	t.pages.AddPage("filter", modal, true, true)
+	t.app.SetFocus(input)
+	t.setStatus("[FILTER] Type to filter  Enter:Keep  Esc:Clear")
 }
 
 func (t *MessagesTUI) loadConversations() {
@@ -461,20 +920,7 @@ func (t *MessagesTUI) loadConversations() {
 	t.mu.Unlock()
 
 	t.app.QueueUpdateDraw(func() {
-		t.convList.Clear()
-		for _, conv := range convs {
-			name := conv.DisplayName
-			if len(name) > MaxDisplayNameLength {
-				name = name[:MaxDisplayNameLength-3] + "..."
-			}
-
-			secondary := t.formatTime(conv.LastMessageDate)
-			if conv.UnreadCount > 0 {
-				name = fmt.Sprintf("(%d) %s", conv.UnreadCount, name)
-			}
-
-			t.convList.AddItem(name, secondary, 0, nil)
-		}
+		t.populateConvList(convs)
 
 		if len(convs) > 0 && t.selectedChatID == 0 {
 			t.selectedChatID = convs[0].ChatID
@@ -487,43 +933,67 @@ func (t *MessagesTUI) loadConversations() {
 func (t *MessagesTUI) loadMessages(chatID int64) {
 	// Show loading indicator
 	t.app.QueueUpdateDraw(func() {
+		t.saveScrollOffset(chatID)
 		t.msgView.SetText("[yellow]Loading messages...[-]")
 	})
 
 	msgs := t.watcher.GetMessages(chatID, DefaultMessageLimit)
+	t.renderInlineImages(msgs)
 
 	t.mu.Lock()
 	t.messages = msgs
 	t.selectedChatID = chatID
 	t.mu.Unlock()
 
-	// Find conversation name
-	var chatName string
 	t.mu.RLock()
-	for _, conv := range t.conversations {
-		if conv.ChatID == chatID {
-			chatName = conv.DisplayName
-			break
-		}
-	}
+	conv, _ := t.conversation(chatID)
 	t.mu.RUnlock()
 
 	t.app.QueueUpdateDraw(func() {
-		t.msgView.Clear()
-		t.msgView.SetTitle(fmt.Sprintf(" %s ", chatName))
+		t.displayMessages(conv.DisplayName, msgs)
+		t.restoreScrollOffset(chatID)
+	})
+}
 
-		if msgs == nil {
-			t.msgView.SetText("[red]Unable to load messages[-]")
-			return
+// saveScrollOffset records where msgView is scrolled in the conversation it
+// shows, before it's replaced by chatID's (or reloaded), so
+// restoreScrollOffset can put it back. The view then starts out following
+// the end.
+func (t *MessagesTUI) saveScrollOffset(chatID int64) {
+	if t.shownChatID != 0 {
+		if t.followEnd {
+			delete(t.scrollOffsets, t.shownChatID)
+		} else {
+			row, _ := t.msgView.GetScrollOffset()
+			t.scrollOffsets[t.shownChatID] = row
 		}
-
-		var builder strings.Builder
-		for _, msg := range msgs {
-			t.formatMessageLine(&builder, msg)
+		if t.shownChatID != chatID {
+			t.prevChatID = t.shownChatID
 		}
-		t.msgView.SetText(builder.String())
-		t.msgView.ScrollToEnd()
-	})
+	}
+	t.shownChatID = 0
+	t.followEnd = true
+}
+
+// restoreScrollOffset scrolls msgView, now showing chatID, back to where it
+// was when the conversation was left. Conversations left at the end stay
+// at the end.
+func (t *MessagesTUI) restoreScrollOffset(chatID int64) {
+	t.shownChatID = chatID
+	if row, ok := t.scrollOffsets[chatID]; ok {
+		t.msgView.ScrollTo(row, 0)
+		t.followEnd = false
+	}
+}
+
+// switchToLastChat goes back to the conversation shown before the current
+// one, scrolled to where it was left.
+func (t *MessagesTUI) switchToLastChat() {
+	if t.prevChatID == 0 {
+		t.setStatus("No other conversation to switch to")
+		return
+	}
+	t.jumpToChat(t.prevChatID)
 }
 
 func (t *MessagesTUI) sendMessage(text string) {
@@ -537,13 +1007,8 @@ func (t *MessagesTUI) sendMessage(text string) {
 
 	t.mu.RLock()
 	chatID := t.selectedChatID
-	var chatIdent string
-	for _, conv := range t.conversations {
-		if conv.ChatID == chatID {
-			chatIdent = conv.ChatIdentifier
-			break
-		}
-	}
+	conv, _ := t.conversation(chatID)
+	chatIdent := conv.ChatIdentifier
 	t.mu.RUnlock()
 
 	if chatIdent == "" {
@@ -554,9 +1019,12 @@ func (t *MessagesTUI) sendMessage(text string) {
 		return
 	}
 
-	// Run async to avoid blocking UI (AppleScript can take up to 30s)
+	// Run async to avoid blocking UI (AppleScript can take up to 30s).
+	// Live updates are paused meanwhile; the messages are reloaded after.
+	t.watcher.Pause()
 	go func() {
 		defer t.sendingMessage.Store(false)
+		defer t.watcher.Resume()
 
 		t.app.QueueUpdateDraw(func() {
 			t.setStatus("📤 Sending...")
@@ -564,14 +1032,26 @@ func (t *MessagesTUI) sendMessage(text string) {
 
 		err := sender.SendMessage(chatIdent, text)
 		if err != nil {
+			// Queued so the message survives a crash; sending it again from
+			// the input field takes it back out.
+			if qErr := outbox.Add(chatIdent, text, "", err); qErr != nil {
+				t.logf("sendMessage: %v", qErr)
+			}
 			t.app.QueueUpdateDraw(func() {
-				t.setStatus(fmt.Sprintf("❌ Error: %v", err))
+				t.setStatus(fmt.Sprintf("❌ Error: %v (saved to outbox)", err))
 				// Restore the message text so user can retry
 				t.inputField.SetText(text)
 			})
 		} else {
+			if _, qErr := outbox.Remove(chatIdent, text); qErr != nil {
+				t.logf("sendMessage: %v", qErr)
+			}
+			t.mu.Lock()
+			t.lastSentTo = chatIdent
+			t.lastSentAt = time.Now()
+			t.mu.Unlock()
 			t.app.QueueUpdateDraw(func() {
-				t.setStatus("✓ Message sent!")
+				t.setStatus("✓ Message sent! (u: Undo send, for 2 minutes)")
 			})
 			// Refresh messages after a short delay
 			time.Sleep(MessageRefreshDelay)
@@ -649,6 +1129,7 @@ func (t *MessagesTUI) refresh() {
 			go func() {
 				t.logf("refresh: calling GetMessages for chatID=%d...", chatID)
 				result := t.watcher.GetMessages(chatID, DefaultMessageLimit)
+				t.renderInlineImages(result)
 				t.logf("refresh: GetMessages returned %d items", len(result))
 				msgCh <- msgResult{msgs: result}
 			}()
@@ -672,12 +1153,8 @@ func (t *MessagesTUI) refresh() {
 
 			// Find conversation name
 			t.mu.RLock()
-			for _, conv := range t.conversations {
-				if conv.ChatID == chatID {
-					chatName = conv.DisplayName
-					break
-				}
-			}
+			conv, _ := t.conversation(chatID)
+			chatName = conv.DisplayName
 			t.mu.RUnlock()
 		}
 
@@ -687,32 +1164,11 @@ func (t *MessagesTUI) refresh() {
 		t.app.QueueUpdateDraw(func() {
 			t.logf("refresh: inside QueueUpdateDraw callback")
 			// Update conversation list
-			t.convList.Clear()
-			for _, conv := range convs {
-				name := conv.DisplayName
-				if len(name) > MaxDisplayNameLength {
-					name = name[:MaxDisplayNameLength-3] + "..."
-				}
-
-				secondary := t.formatTime(conv.LastMessageDate)
-				if conv.UnreadCount > 0 {
-					name = fmt.Sprintf("(%d) %s", conv.UnreadCount, name)
-				}
-
-				t.convList.AddItem(name, secondary, 0, nil)
-			}
+			t.populateConvList(convs)
 
 			// Update messages if we have a selected chat
-			if chatID > 0 && msgs != nil {
-				t.msgView.Clear()
-				t.msgView.SetTitle(fmt.Sprintf(" %s ", chatName))
-
-				var builder strings.Builder
-				for _, msg := range msgs {
-					t.formatMessageLine(&builder, msg)
-				}
-				t.msgView.SetText(builder.String())
-				t.msgView.ScrollToEnd()
+			if chatID > 0 {
+				t.displayMessages(chatName, msgs)
 			}
 
 			t.setStatus("✓ Refreshed!")
@@ -722,6 +1178,56 @@ func (t *MessagesTUI) refresh() {
 	}()
 }
 
+// onMessagesAppended adds a chat's new messages to the message view if it's
+// the one shown. The new lines are written after the existing ones rather
+// than re-rendering every message, unless the view no longer matches the
+// loaded messages (e.g. a chat switch is still being drawn). The watcher
+// calls it for one batch at a time, in order, and QueueUpdateDraw keeps
+// that order, so only messages past the last loaded one are new.
+func (t *MessagesTUI) onMessagesAppended(chatID int64, appended []watcher.Message) {
+	t.renderInlineImages(appended)
+
+	t.app.QueueUpdateDraw(func() {
+		t.mu.Lock()
+		if chatID != t.selectedChatID {
+			t.mu.Unlock()
+			return
+		}
+		before := t.messages
+		var lastID int64
+		if len(before) > 0 {
+			lastID = before[len(before)-1].MessageID
+		}
+		// A reload may already include some of them
+		var fresh []watcher.Message
+		for _, msg := range appended {
+			if msg.MessageID > lastID {
+				fresh = append(fresh, msg)
+			}
+		}
+		t.messages = append(before, fresh...)
+		msgs := t.messages
+		t.mu.Unlock()
+
+		if len(fresh) == 0 {
+			return
+		}
+		t.logf("onMessagesAppended: %d new messages in chat %d", len(fresh), chatID)
+
+		shown := t.shownMsgIDs
+		if len(before) == 0 || len(shown) != len(before) || shown[len(shown)-1] != lastID {
+			t.msgView.SetText(t.renderMessages(msgs))
+		} else {
+			var builder strings.Builder
+			for _, msg := range fresh {
+				t.formatMessageLine(&builder, msg)
+			}
+			t.msgView.Write([]byte(builder.String()))
+		}
+		t.restoreMessageSelection(msgs)
+	})
+}
+
 func (t *MessagesTUI) onNewMessages(msgs []watcher.Message) {
 	if t.logger != nil {
 		t.logf("onNewMessages: received %d messages", len(msgs))
@@ -730,19 +1236,29 @@ func (t *MessagesTUI) onNewMessages(msgs []watcher.Message) {
 	currentChatID := t.selectedChatID
 	t.mu.RUnlock()
 
-	// Check if any messages are for the current chat
+	// The current chat is extended by onMessagesAppended from the watcher's
+	// cache; it only needs reloading if it never loaded.
 	for _, msg := range msgs {
 		if msg.ChatID == currentChatID {
-			t.loadMessages(currentChatID)
+			if _, ok := t.watcher.CachedMessages(currentChatID); !ok {
+				t.loadMessages(currentChatID)
+			}
 			break
 		}
 	}
 
-	// Show notification for incoming messages
-	if len(msgs) > 0 && !msgs[len(msgs)-1].IsFromMe {
-		t.app.QueueUpdateDraw(func() {
-			t.setStatus(fmt.Sprintf("📬 New message from %s", msgs[len(msgs)-1].Sender))
-		})
+	// Show notification for incoming messages, skipping muted chats
+	for i := len(msgs) - 1; i >= 0; i-- {
+		if msgs[i].IsMuted {
+			continue
+		}
+		if !msgs[i].IsFromMe {
+			sender := msgs[i].Sender
+			t.app.QueueUpdateDraw(func() {
+				t.setStatus(fmt.Sprintf("📬 New message from %s", sender))
+			})
+		}
+		break
 	}
 }
 
@@ -758,61 +1274,186 @@ func (t *MessagesTUI) onConversationsUpdated(convs []watcher.Conversation) {
 		// Preserve selection
 		selectedIdx := t.convList.GetCurrentItem()
 
-		t.convList.Clear()
-		for _, conv := range convs {
-			name := conv.DisplayName
-			if len(name) > MaxDisplayNameLength {
-				name = name[:MaxDisplayNameLength-3] + "..."
-			}
+		t.populateConvList(convs)
 
-			secondary := t.formatTime(conv.LastMessageDate)
-			if conv.UnreadCount > 0 {
-				name = fmt.Sprintf("(%d) %s", conv.UnreadCount, name)
-			}
-
-			t.convList.AddItem(name, secondary, 0, nil)
-		}
-
-		if selectedIdx >= 0 && selectedIdx < len(convs) {
+		if selectedIdx >= 0 && selectedIdx < t.convList.GetItemCount() {
 			t.convList.SetCurrentItem(selectedIdx)
 		}
 	})
 }
 
+func (t *MessagesTUI) onWatcherError(err error) {
+	t.logf("onWatcherError: %v", err)
+
+	msg := err.Error()
+	t.mu.Lock()
+	if msg == t.lastError && time.Since(t.lastErrorAt) < ErrorStatusThrottle {
+		t.mu.Unlock()
+		return
+	}
+	t.lastError = msg
+	t.lastErrorAt = time.Now()
+	t.mu.Unlock()
+
+	t.app.QueueUpdateDraw(func() {
+		t.setStatus(fmt.Sprintf("⚠️ DB error: %v", err))
+	})
+}
+
 func (t *MessagesTUI) formatTime(tm *time.Time) string {
-	if tm == nil {
-		return ""
+	if absoluteTimes {
+		return timefmt.FormatAbsoluteTime(tm)
+	}
+	return timefmt.FormatRelativeTimeShort(tm, time.Now())
+}
+
+// formatMessageTime formats a time in the message view: like formatTime,
+// or to the second while exact times are toggled on.
+func (t *MessagesTUI) formatMessageTime(tm *time.Time) string {
+	if t.exactTimes.Load() {
+		return timefmt.FormatExactTime(tm)
+	}
+	return t.formatTime(tm)
+}
+
+// undoSend unsends the last message sent from the TUI while it's still within
+// sender.UnsendWindow. Messages comes to the front to do it.
+func (t *MessagesTUI) undoSend() {
+	t.mu.RLock()
+	chatIdent, sentAt, chatID := t.lastSentTo, t.lastSentAt, t.selectedChatID
+	t.mu.RUnlock()
+
+	if chatIdent == "" {
+		t.setStatus("Nothing to unsend")
+		return
+	}
+	if time.Since(sentAt) > sender.UnsendWindow {
+		t.setStatus("Too late to unsend: Messages only allows it for 2 minutes after sending")
+		return
+	}
+
+	t.setStatus("↩️ Unsending...")
+	go func() {
+		err := sender.UnsendLastMessage(chatIdent)
+		t.app.QueueUpdateDraw(func() {
+			if err != nil {
+				t.setStatus(fmt.Sprintf("❌ Error: %v", err))
+				return
+			}
+			t.setStatus("↩️ Message unsent")
+		})
+		if err != nil {
+			return
+		}
+
+		t.mu.Lock()
+		if t.lastSentTo == chatIdent {
+			t.lastSentTo = ""
+		}
+		t.mu.Unlock()
+		// Reload so it shows as unsent
+		time.Sleep(MessageRefreshDelay)
+		t.loadMessages(chatID)
+	}()
+}
+
+// reloadContacts re-reads the AddressBook in the background, then refreshes
+// so conversation and sender names pick up the changes.
+func (t *MessagesTUI) reloadContacts() {
+	t.setStatus("👤 Reloading contacts...")
+	go func() {
+		n := database.ReloadContacts()
+		t.logf("reloadContacts: %d contacts", n)
+		t.app.QueueUpdateDraw(func() {
+			t.refresh()
+		})
+	}()
+}
+
+// toggleExactTimes switches the message view between the usual timestamps
+// and exact ones, re-rendering the loaded messages in place.
+func (t *MessagesTUI) toggleExactTimes() {
+	exact := !t.exactTimes.Load()
+	t.exactTimes.Store(exact)
+	if exact {
+		t.setStatus("🕐 Exact times on")
+	} else {
+		t.setStatus("Exact times off")
 	}
 
-	now := time.Now()
-	diff := now.Sub(*tm)
+	t.mu.RLock()
+	msgs := t.messages
+	t.mu.RUnlock()
+	t.msgView.SetText(t.renderMessages(msgs))
+	t.restoreMessageSelection(msgs)
+}
 
-	if diff.Hours() < 24 {
-		return tm.Format("15:04")
-	} else if diff.Hours() < 48 {
-		return "Yesterday"
-	} else if diff.Hours() < 168 {
-		return tm.Format("Mon")
+// renderMessages returns the message view text for msgs, oldest first. An
+// empty (or failed, which the watcher reports as nil) load gets a
+// placeholder.
+func (t *MessagesTUI) renderMessages(msgs []watcher.Message) string {
+	if len(msgs) == 0 {
+		return "[yellow]No messages or unable to load messages[-]"
 	}
-	return tm.Format("01/02")
+	var builder strings.Builder
+	for _, msg := range msgs {
+		t.formatMessageLine(&builder, msg)
+	}
+	return builder.String()
+}
+
+// displayMessages shows msgs in the message view under the chat's name,
+// keeping the selected message. Must be called on the UI goroutine (or
+// before app.Run).
+func (t *MessagesTUI) displayMessages(chatName string, msgs []watcher.Message) {
+	t.msgView.SetTitle(fmt.Sprintf(" %s ", chatName))
+	t.msgView.SetText(t.renderMessages(msgs))
+	t.restoreMessageSelection(msgs)
 }
 
 // formatMessageLine renders a single message (with attachment info) into the builder.
 func (t *MessagesTUI) formatMessageLine(builder *strings.Builder, msg watcher.Message) {
-	timeStr := t.formatTime(msg.Date)
+	if msg.ReplyToGUID != "" {
+		if msg.ReplyToText != "" {
+			builder.WriteString(fmt.Sprintf("[gray]  ↳ replying to: \"%s\"[-]\n", tview.Escape(replyPreview(msg.ReplyToText))))
+		} else {
+			builder.WriteString("[gray]  ↳ replying to an earlier message[-]\n")
+		}
+	}
+
+	timeStr := t.formatMessageTime(msg.Date)
+	// Escaped so message text can't open or close a region
+	text := tview.Escape(msg.Text)
+	if msg.IsRetracted {
+		text = "[gray::i]" + text + "[-::-]"
+	} else if msg.IsEdited {
+		text += " [gray](edited)[-]"
+	}
+	if msg.Effect != "" && !msg.IsRetracted {
+		text += " [gray]" + tview.Escape(fmt.Sprintf("[sent with %s]", msg.Effect)) + "[-]"
+	}
+
+	builder.WriteString(fmt.Sprintf(`["%s"]`, messageRegion(msg.MessageID)))
 	if msg.IsFromMe {
-		builder.WriteString(fmt.Sprintf("[green][%s] Me:[-] %s\n", timeStr, msg.Text))
-	} else {
-		sender := msg.Sender
-		if len(sender) > MaxSenderNameLength {
-			sender = sender[:MaxSenderNameLength-3] + "..."
+		builder.WriteString(fmt.Sprintf("%s[%s] Me:[-] %s", colorTag(colors.Me), timeStr, text))
+		if msg.DateRead != nil {
+			builder.WriteString(fmt.Sprintf(" [gray]✓✓ Read at %s[-]", t.formatMessageTime(msg.DateRead)))
+		} else if msg.IsDelivered {
+			builder.WriteString(" [gray]✓ Delivered[-]")
 		}
-		builder.WriteString(fmt.Sprintf("[cyan][%s] %s:[-] %s\n", timeStr, sender, msg.Text))
+	} else {
+		sender := tview.Escape(truncateWidth(msg.Sender, MaxSenderNameLength))
+		builder.WriteString(fmt.Sprintf("%s[%s] %s:[-] %s", colorTag(colors.Them), timeStr, sender, text))
 	}
+	builder.WriteString("[\"\"]\n")
 
 	// Show attachment indicators
 	for _, att := range msg.Attachments {
 		if att.IsImage {
+			if preview, ok := t.cachedInlineImage(att); ok {
+				builder.WriteString(preview)
+				continue
+			}
 			builder.WriteString(fmt.Sprintf("              [yellow]📎 %s (image · p to preview)[-]\n", att.Filename))
 		} else {
 			builder.WriteString(fmt.Sprintf("              [gray]📎 %s[-]\n", att.Filename))
@@ -820,6 +1461,210 @@ func (t *MessagesTUI) formatMessageLine(builder *strings.Builder, msg watcher.Me
 	}
 }
 
+// messageRegion returns the msgView region ID of a message's line.
+func messageRegion(messageID int64) string {
+	return fmt.Sprintf("msg-%d", messageID)
+}
+
+// messageIDFromRegion is the inverse of messageRegion.
+func messageIDFromRegion(region string) (int64, bool) {
+	id, err := strconv.ParseInt(strings.TrimPrefix(region, "msg-"), 10, 64)
+	return id, err == nil && strings.HasPrefix(region, "msg-")
+}
+
+// restoreMessageSelection records which messages msgView now shows and
+// highlights the selected one again after a re-render. If the newest message
+// was selected it still is, and the view follows the end unless it was
+// scrolled up (see followEnd). If the selection is gone (e.g. another
+// conversation was opened), the newest message is selected at the end.
+func (t *MessagesTUI) restoreMessageSelection(msgs []watcher.Message) {
+	newestSelected := len(t.shownMsgIDs) == 0 || t.selectedMsgID == t.shownMsgIDs[len(t.shownMsgIDs)-1]
+
+	t.shownMsgIDs = make([]int64, len(msgs))
+	for i, msg := range msgs {
+		t.shownMsgIDs[i] = msg.MessageID
+	}
+	if len(msgs) == 0 {
+		t.selectedMsgID = 0
+		t.msgView.Highlight()
+		return
+	}
+
+	if !newestSelected {
+		if i := t.shownMessageIndex(t.selectedMsgID); i >= 0 {
+			t.msgView.Highlight(messageRegion(t.selectedMsgID)).ScrollToHighlight()
+			t.followEnd = false
+			return
+		}
+		t.followEnd = true
+	}
+	t.selectedMsgID = t.shownMsgIDs[len(t.shownMsgIDs)-1]
+	t.msgView.Highlight(messageRegion(t.selectedMsgID))
+	if t.followEnd {
+		t.msgView.ScrollToEnd()
+	}
+}
+
+// shownMessageIndex returns the display position of a message, or -1.
+func (t *MessagesTUI) shownMessageIndex(messageID int64) int {
+	for i, id := range t.shownMsgIDs {
+		if id == messageID {
+			return i
+		}
+	}
+	return -1
+}
+
+// moveMessageSelection selects the message delta positions away from the
+// current one, stopping at the first and last.
+func (t *MessagesTUI) moveMessageSelection(delta int) {
+	i := t.shownMessageIndex(t.selectedMsgID)
+	if i < 0 {
+		i = len(t.shownMsgIDs) - 1
+	} else {
+		i += delta
+	}
+	t.selectMessageAt(max(0, min(i, len(t.shownMsgIDs)-1)))
+}
+
+// selectMessageAt highlights the message at display position i and scrolls
+// it into view. The newest message scrolls to the end so its attachments
+// show too.
+func (t *MessagesTUI) selectMessageAt(i int) {
+	if i < 0 || i >= len(t.shownMsgIDs) {
+		return
+	}
+	t.selectedMsgID = t.shownMsgIDs[i]
+	t.msgView.Highlight(messageRegion(t.selectedMsgID))
+	t.followEnd = i == len(t.shownMsgIDs)-1
+	if t.followEnd {
+		t.msgView.ScrollToEnd()
+	} else {
+		t.msgView.ScrollToHighlight()
+	}
+}
+
+// selectedMessage returns the highlighted message.
+func (t *MessagesTUI) selectedMessage() (watcher.Message, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	for _, msg := range t.messages {
+		if msg.MessageID == t.selectedMsgID {
+			return msg, true
+		}
+	}
+	return watcher.Message{}, false
+}
+
+// copySelectedMessage copies the text of the highlighted message to the
+// clipboard.
+func (t *MessagesTUI) copySelectedMessage() {
+	msg, ok := t.selectedMessage()
+	if !ok {
+		t.setStatus("No message selected")
+		return
+	}
+	text := msg.Text
+	if len(msg.Attachments) > 0 && text == "[Attachment]" {
+		text = ""
+	}
+	if text == "" {
+		t.setStatus("Selected message has no text to copy")
+		return
+	}
+
+	go func() {
+		err := clipboard.Copy(text)
+		t.app.QueueUpdateDraw(func() {
+			if err != nil {
+				t.setStatus(fmt.Sprintf("❌ Copy failed: %v", err))
+				return
+			}
+			t.setStatus("📋 Copied message to clipboard")
+		})
+	}()
+}
+
+// inlineImageWidth returns the width for inline previews: the message panel
+// width, capped at PreviewMaxWidth.
+func (t *MessagesTUI) inlineImageWidth() int {
+	width := int(t.msgViewWidth.Load())
+	if width <= 0 || width > PreviewMaxWidth {
+		width = PreviewMaxWidth
+	}
+	return width
+}
+
+func inlineImageKey(att watcher.Attachment, width int) string {
+	return fmt.Sprintf("%s@%d", att.FilePath, width)
+}
+
+// renderInlineImages renders previews for image attachments in msgs that
+// aren't cached yet. Decoding can be slow (HEIC goes through sips), so this
+// must run off the UI goroutine; formatMessageLine only reads the cache.
+func (t *MessagesTUI) renderInlineImages(msgs []watcher.Message) {
+	if !t.showImages.Load() {
+		return
+	}
+	width := t.inlineImageWidth()
+
+	for _, msg := range msgs {
+		for _, att := range msg.Attachments {
+			if !att.IsImage {
+				continue
+			}
+			key := inlineImageKey(att, width)
+			t.imageMu.Lock()
+			_, ok := t.imageCache[key]
+			t.imageMu.Unlock()
+			if ok {
+				continue
+			}
+
+			rendered, err := RenderImageToText(att.FilePath, width, InlinePreviewMaxHeight)
+			if err != nil {
+				t.logf("renderInlineImages: %s: %v", att.FilePath, err)
+				rendered = fmt.Sprintf("              [yellow][Image: %s][-]\n", tview.Escape(att.Filename))
+			}
+
+			t.imageMu.Lock()
+			t.imageCache[key] = rendered
+			t.imageMu.Unlock()
+		}
+	}
+}
+
+// cachedInlineImage returns the rendered inline preview for att, if previews
+// are enabled and it has been rendered.
+func (t *MessagesTUI) cachedInlineImage(att watcher.Attachment) (string, bool) {
+	if !t.showImages.Load() {
+		return "", false
+	}
+	t.imageMu.Lock()
+	defer t.imageMu.Unlock()
+	preview, ok := t.imageCache[inlineImageKey(att, t.inlineImageWidth())]
+	return preview, ok
+}
+
+// toggleInlineImages switches inline image previews on or off and re-renders
+// the current conversation.
+func (t *MessagesTUI) toggleInlineImages() {
+	enabled := !t.showImages.Load()
+	t.showImages.Store(enabled)
+	if enabled {
+		t.setStatus("🖼️  Inline image previews on")
+	} else {
+		t.setStatus("Inline image previews off")
+	}
+
+	t.mu.RLock()
+	chatID := t.selectedChatID
+	t.mu.RUnlock()
+	if chatID > 0 {
+		go t.loadMessages(chatID)
+	}
+}
+
 // showImagePreview shows a modal with a half-block rendered image.
 func (t *MessagesTUI) showImagePreview(att watcher.Attachment) {
 	go func() {
@@ -847,13 +1692,13 @@ func (t *MessagesTUI) showImagePreview(att watcher.Attachment) {
 					case tcell.KeyEscape, tcell.KeyEnter:
 						t.pages.RemovePage("preview")
 						t.app.SetFocus(t.msgView)
-						t.setStatus("[MSG] ↑↓:Scroll  h/←:Back  i:Input  p:Preview  r:Refresh  q:Quit")
+						t.setStatus("[MSG] ↑↓:Scroll  j/k:Select  h/←:Back  o:Last chat  i:Input  p:Preview  v:Images  t:Times  y:Copy  r:Refresh  q:Quit")
 						return nil
 					case tcell.KeyRune:
 						if event.Rune() == 'q' {
 							t.pages.RemovePage("preview")
 							t.app.SetFocus(t.msgView)
-							t.setStatus("[MSG] ↑↓:Scroll  h/←:Back  i:Input  p:Preview  r:Refresh  q:Quit")
+							t.setStatus("[MSG] ↑↓:Scroll  j/k:Select  h/←:Back  o:Last chat  i:Input  p:Preview  v:Images  t:Times  y:Copy  r:Refresh  q:Quit")
 							return nil
 						}
 					}
@@ -882,6 +1727,156 @@ func (t *MessagesTUI) showImagePreview(att watcher.Attachment) {
 	}()
 }
 
+// showSearch opens a search prompt overlay. Results are listed below the
+// prompt and selecting one jumps to its conversation.
+func (t *MessagesTUI) showSearch() {
+	prevFocus := t.app.GetFocus()
+
+	results := tview.NewList().
+		ShowSecondaryText(true).
+		SetHighlightFullLine(true).
+		SetSelectedBackgroundColor(tcell.ColorDarkCyan).
+		SetSelectedTextColor(tcell.ColorWhite)
+
+	input := tview.NewInputField().
+		SetLabel("Search: ").
+		SetLabelColor(tcell.ColorYellow).
+		SetFieldBackgroundColor(tcell.ColorBlack)
+
+	layout := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(input, 1, 0, true).
+		AddItem(results, 0, 1, false)
+	layout.SetBorder(true).
+		SetBorderColor(tcell.ColorYellow).
+		SetTitle(" 🔍 Search (Esc to close) ")
+
+	closeSearch := func() {
+		t.pages.RemovePage("search")
+		t.app.SetFocus(prevFocus)
+		t.setStatus("↑↓:Nav  Enter:Select  Tab:Switch  o:Last chat  i:Input  f:Filter  /:Search  r:Refresh  q:Quit")
+	}
+
+	input.SetDoneFunc(func(key tcell.Key) {
+		switch key {
+		case tcell.KeyEscape:
+			closeSearch()
+		case tcell.KeyEnter:
+			query := strings.TrimSpace(input.GetText())
+			if query != "" {
+				t.runSearch(query, results)
+			}
+		}
+	})
+
+	results.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyEscape:
+			closeSearch()
+			return nil
+		case tcell.KeyRune:
+			if event.Rune() == '/' {
+				t.app.SetFocus(input)
+				return nil
+			}
+		}
+		return event
+	})
+
+	modal := tview.NewFlex().
+		AddItem(nil, 0, 1, false).
+		AddItem(tview.NewFlex().SetDirection(tview.FlexRow).
+			AddItem(nil, 0, 1, false).
+			AddItem(layout, SearchMaxHeight, 0, true).
+			AddItem(nil, 0, 1, false), SearchMaxWidth, 0, true).
+		AddItem(nil, 0, 1, false)
+
+	t.pages.AddPage("search", modal, true, true)
+	t.app.SetFocus(input)
+	t.setStatus("[SEARCH] Enter:Search  ↑↓:Results  Enter:Open  /:Edit query  Esc:Close")
+}
+
+// runSearch queries the database in the background and fills the results list.
+func (t *MessagesTUI) runSearch(query string, results *tview.List) {
+	t.setStatus(fmt.Sprintf("🔍 Searching for '%s'...", query))
+
+	go func() {
+		msgs, err := database.SearchMessages(query, SearchResultLimit, database.SearchOptions{IgnoreCase: true})
+		t.logf("runSearch: query=%q results=%d err=%v", query, len(msgs), err)
+
+		t.app.QueueUpdateDraw(func() {
+			results.Clear()
+			if err != nil {
+				t.setStatus(fmt.Sprintf("❌ Search failed: %v", err))
+				return
+			}
+			if len(msgs) == 0 {
+				t.setStatus(fmt.Sprintf("No messages found matching '%s'", query))
+				return
+			}
+
+			for _, msg := range msgs {
+				msg := msg
+				main := fmt.Sprintf("[%s] %s", t.formatTime(msg.Date), msg.ChatName)
+				secondary := fmt.Sprintf("%s: %s", msg.Sender, strings.ReplaceAll(msg.Text, "\n", " "))
+				results.AddItem(tview.Escape(main), tview.Escape(secondary), 0, func() {
+					t.pages.RemovePage("search")
+					t.mu.Lock()
+					t.openedConvs[msg.ChatID] = watcher.Conversation{
+						ChatID:         msg.ChatID,
+						ChatIdentifier: msg.ChatIdent,
+						DisplayName:    msg.ChatName,
+					}
+					t.mu.Unlock()
+					t.jumpToChat(msg.ChatID)
+				})
+			}
+			t.app.SetFocus(results)
+			t.setStatus(fmt.Sprintf("Found %d message(s) · Enter:Open  /:Edit query  Esc:Close", len(msgs)))
+		})
+	}()
+}
+
+// conversation returns the conversation with the given chat ID from the
+// loaded list, or from openedConvs if it's older than the list. The caller
+// must hold mu.
+func (t *MessagesTUI) conversation(chatID int64) (watcher.Conversation, bool) {
+	for _, conv := range t.conversations {
+		if conv.ChatID == chatID {
+			return conv, true
+		}
+	}
+	conv, ok := t.openedConvs[chatID]
+	return conv, ok
+}
+
+// jumpToChat selects the conversation with the given chat ID and focuses the
+// message view. Conversations outside the loaded list are opened directly;
+// they must be in openedConvs for sending to them to work.
+func (t *MessagesTUI) jumpToChat(chatID int64) {
+	t.mu.RLock()
+	idx := -1
+	for row, i := range t.visibleConvs {
+		if i < len(t.conversations) && t.conversations[i].ChatID == chatID {
+			idx = row
+			break
+		}
+	}
+	t.mu.RUnlock()
+
+	if idx >= 0 && idx != t.convList.GetCurrentItem() {
+		// Triggers the changed func, which loads the messages
+		t.convList.SetCurrentItem(idx)
+	} else {
+		t.mu.Lock()
+		t.selectedChatID = chatID
+		t.mu.Unlock()
+		go t.loadMessages(chatID)
+	}
+
+	t.app.SetFocus(t.msgView)
+	t.setStatus("[MSG] ↑↓:Scroll  j/k:Select  h/←:Back  o:Last chat  i:Input  p:Preview  v:Images  t:Times  y:Copy  r:Refresh  q:Quit")
+}
+
 // findNearestImageAttachment scans messages for the nearest image attachment,
 // searching backwards from the most recent message.
 func (t *MessagesTUI) findNearestImageAttachment() *watcher.Attachment {
@@ -0,0 +1,97 @@
+package tui
+
+import (
+	"testing"
+	"time"
+
+	"github.com/danewalton/imessage-cli/internal/watcher"
+)
+
+// newTestTUI returns a MessagesTUI that renders exact timestamps and no
+// inline images, so its output doesn't depend on the clock or on files.
+func newTestTUI() *MessagesTUI {
+	t := NewMessagesTUI()
+	t.exactTimes.Store(true)
+	t.showImages.Store(false)
+	return t
+}
+
+func TestRenderMessages(t *testing.T) {
+	at := func(min int) *time.Time {
+		tm := time.Date(2024, 3, 1, 9, min, 0, 0, time.Local)
+		return &tm
+	}
+
+	msgs := []watcher.Message{
+		{MessageID: 1, Text: "Lunch tomorrow?", Date: at(0), Sender: "Alice"},
+		{MessageID: 2, Text: "Sure", Date: at(1), IsFromMe: true, DateRead: at(2)},
+		{MessageID: 3, Text: "Noon [works]", Date: at(3), IsFromMe: true, IsDelivered: true, IsEdited: true},
+		{MessageID: 4, Text: "Great", Date: at(4), Sender: "Alice", ReplyToGUID: "g3", ReplyToText: "Noon\nworks"},
+		{MessageID: 5, Text: "[Attachment]", Date: at(5), Sender: "Alice", Attachments: []watcher.Attachment{
+			{Filename: "photo.jpeg", IsImage: true},
+			{Filename: "menu.pdf"},
+		}},
+		{MessageID: 6, Text: "Yay", Date: at(6), IsFromMe: true, Effect: "confetti"},
+		{MessageID: 7, Text: "This message was unsent.", Date: at(7), Sender: "Alice", IsRetracted: true, Effect: "slam"},
+	}
+
+	want := `["msg-1"][aqua][2024-03-01 09:00:00] Alice:[-] Lunch tomorrow?[""]
+["msg-2"][green][2024-03-01 09:01:00] Me:[-] Sure [gray]✓✓ Read at 2024-03-01 09:02:00[-][""]
+["msg-3"][green][2024-03-01 09:03:00] Me:[-] Noon [works[] [gray](edited)[-] [gray]✓ Delivered[-][""]
+[gray]  ↳ replying to: "Noon works"[-]
+["msg-4"][aqua][2024-03-01 09:04:00] Alice:[-] Great[""]
+["msg-5"][aqua][2024-03-01 09:05:00] Alice:[-] [Attachment[][""]
+              [yellow]📎 photo.jpeg (image · p to preview)[-]
+              [gray]📎 menu.pdf[-]
+["msg-6"][green][2024-03-01 09:06:00] Me:[-] Yay [gray][sent with confetti[][-][""]
+["msg-7"][aqua][2024-03-01 09:07:00] Alice:[-] [gray::i]This message was unsent.[-::-][""]
+`
+	if got := newTestTUI().renderMessages(msgs); got != want {
+		t.Errorf("renderMessages:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestRenderMessagesEmpty(t *testing.T) {
+	// The initial load, chat switches and refreshes used to disagree on
+	// this text; they all go through renderMessages now.
+	const want = "[yellow]No messages or unable to load messages[-]"
+	tui := newTestTUI()
+	for _, msgs := range [][]watcher.Message{nil, {}} {
+		if got := tui.renderMessages(msgs); got != want {
+			t.Errorf("renderMessages(%#v) = %q, want %q", msgs, got, want)
+		}
+	}
+}
+
+func TestRenderMessagesLongSender(t *testing.T) {
+	msgs := []watcher.Message{{MessageID: 1, Text: "hi", Sender: "Bartholomew Fitzgerald"}}
+	want := `["msg-1"][aqua][] Bartholomew ...:[-] hi[""]` + "\n"
+	if got := newTestTUI().renderMessages(msgs); got != want {
+		t.Errorf("renderMessages = %q, want %q", got, want)
+	}
+}
+
+func TestConversationLookup(t *testing.T) {
+	tui := newTestTUI()
+	tui.conversations = []watcher.Conversation{
+		{ChatID: 1, ChatIdentifier: "+15551234567", DisplayName: "Alice"},
+	}
+	// Opened from a search result, older than the loaded list
+	tui.openedConvs[9] = watcher.Conversation{ChatID: 9, ChatIdentifier: "+15559876543", DisplayName: "Old Friend"}
+
+	tests := []struct {
+		chatID int64
+		ident  string
+		ok     bool
+	}{
+		{1, "+15551234567", true},
+		{9, "+15559876543", true},
+		{5, "", false},
+	}
+	for _, tt := range tests {
+		conv, ok := tui.conversation(tt.chatID)
+		if ok != tt.ok || conv.ChatIdentifier != tt.ident {
+			t.Errorf("conversation(%d) = %q, %v; want %q, %v", tt.chatID, conv.ChatIdentifier, ok, tt.ident, tt.ok)
+		}
+	}
+}
@@ -3,8 +3,12 @@ package watcher
 
 import (
 	"database/sql"
+	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -16,6 +20,18 @@ import (
 const (
 	DefaultPollInterval      = 500 * time.Millisecond
 	DefaultConversationLimit = 50
+	StateFileName            = ".imessage-watcher-state"
+	// MaxRetryBackoff caps the delay between polls while the database is
+	// unavailable (e.g. locked during an iCloud sync).
+	MaxRetryBackoff = 10 * time.Second
+	// ErrorThreshold is the number of consecutive failed polls before the
+	// error callbacks are notified.
+	ErrorThreshold = 3
+	// StopTimeout bounds how long Stop waits for an in-flight poll to finish.
+	StopTimeout = 2 * time.Second
+	// DefaultConversationDebounce is the minimum time between conversation
+	// list refreshes while messages are arriving in bursts.
+	DefaultConversationDebounce = time.Second
 )
 
 // Attachment mirrors database.Attachment for the watcher layer.
@@ -32,10 +48,19 @@ type Attachment struct {
 // Message represents an iMessage for the watcher.
 type Message struct {
 	MessageID      int64
+	GUID           string
+	ReplyToGUID    string
+	ReplyToText    string // originator's text for inline replies, "" if not found
 	Text           string
 	Date           *time.Time
 	IsFromMe       bool
 	IsRead         bool
+	IsDelivered    bool
+	DateRead       *time.Time
+	IsEdited       bool
+	IsRetracted    bool
+	Effect         string // e.g. "confetti"; see database.EffectName
+	IsMuted        bool   // chat is on the mute list; don't notify
 	Sender         string
 	ChatID         int64
 	ChatIdentifier string
@@ -64,6 +89,10 @@ type ConversationCallback func([]Conversation)
 // ErrorCallback is called when an error occurs.
 type ErrorCallback func(error)
 
+// AppendCallback is called when new messages are added to a cached chat
+// (see CachedMessages), with only the added messages, oldest first.
+type AppendCallback func(chatID int64, appended []Message)
+
 // MessageWatcher watches the iMessage database for new messages.
 type MessageWatcher struct {
 	pollInterval          time.Duration
@@ -73,11 +102,56 @@ type MessageWatcher struct {
 	messageCallbacks      []MessageCallback
 	conversationCallbacks []ConversationCallback
 	errorCallbacks        []ErrorCallback
+	appendCallbacks       []AppendCallback
 	mu                    sync.RWMutex
 	stopCh                chan struct{}
 	wg                    sync.WaitGroup
+	// stateFile persists the last seen message ID between runs; empty disables it
+	stateFile string
+	// chatID limits new-message callbacks to one chat; 0 watches all chats
+	chatID int64
+	// startAfterID is where delivery starts (see StartAfter); 0 uses the
+	// newest message
+	startAfterID int64
+	// muted holds chat identifiers whose new messages are flagged IsMuted
+	muted map[string]bool
+	// Conversation refresh debouncing, only touched by the poll goroutine
+	// (convDebounce is set before Start).
+	convDebounce    time.Duration
+	convPending     bool
+	lastConvRefresh time.Time
+	lastConvKey     string
+	// paused counts outstanding Pause calls; polls are skipped while it's
+	// above zero.
+	paused atomic.Int32
+	// Retry state, only touched by the poll goroutine.
+	initialized bool
+	failures    int
+	retryAt     time.Time
 	// logger for debugging callback issues
 	logger *log.Logger
+	// cache holds the messages of each chat loaded with GetMessages, kept
+	// current by the poll loop.
+	cache   map[int64]*cachedChat
+	cacheMu sync.Mutex
+	// appendQueue holds append batches not yet passed to the callbacks;
+	// appendRunning is set while a goroutine is delivering them.
+	appendQueue   []appendBatch
+	appendRunning bool
+	appendMu      sync.Mutex
+}
+
+// appendBatch is one chat's messages for the append callbacks.
+type appendBatch struct {
+	chatID   int64
+	appended []Message
+}
+
+// cachedChat is a chat's newest messages, oldest first, at most limit of
+// them (no limit if limit <= 0).
+type cachedChat struct {
+	msgs  []Message
+	limit int
 }
 
 // NewMessageWatcher creates a new MessageWatcher.
@@ -85,6 +159,7 @@ func NewMessageWatcher(pollInterval time.Duration) *MessageWatcher {
 	return &MessageWatcher{
 		pollInterval: pollInterval,
 		stopCh:       make(chan struct{}),
+		convDebounce: DefaultConversationDebounce,
 	}
 }
 
@@ -102,6 +177,14 @@ func (w *MessageWatcher) OnConversationsUpdated(callback ConversationCallback) {
 	w.conversationCallbacks = append(w.conversationCallbacks, callback)
 }
 
+// OnMessagesAppended registers a callback for messages added to cached
+// chats, so a chat on screen can be extended instead of reloaded.
+func (w *MessageWatcher) OnMessagesAppended(callback AppendCallback) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.appendCallbacks = append(w.appendCallbacks, callback)
+}
+
 // OnError registers a callback for errors.
 func (w *MessageWatcher) OnError(callback ErrorCallback) {
 	w.mu.Lock()
@@ -109,18 +192,101 @@ func (w *MessageWatcher) OnError(callback ErrorCallback) {
 	w.errorCallbacks = append(w.errorCallbacks, callback)
 }
 
-func (w *MessageWatcher) getLastMessageID() int64 {
+// WatchChat limits new-message callbacks to a single chat, filtering in SQL.
+// Conversation callbacks are unaffected. Call it before Start.
+func (w *MessageWatcher) WatchChat(chatID int64) {
+	w.chatID = chatID
+}
+
+// StartAfter makes the watcher deliver every message after the one with
+// ROWID id, instead of only those arriving once it starts, e.g. to continue
+// exactly where a listing of history ended. It takes precedence over the
+// state file. Call it before Start.
+func (w *MessageWatcher) StartAfter(id int64) {
+	w.startAfterID = id
+}
+
+// SetMuted sets the chat identifiers whose new messages are delivered with
+// IsMuted set, so callers can skip notifying about them. Call it before Start.
+func (w *MessageWatcher) SetMuted(identifiers []string) {
+	w.muted = make(map[string]bool, len(identifiers))
+	for _, id := range identifiers {
+		w.muted[id] = true
+	}
+}
+
+// SetConversationDebounce sets the minimum time between conversation list
+// refreshes. Database changes within the interval are coalesced into one
+// refresh at the end of it. Call it before Start.
+func (w *MessageWatcher) SetConversationDebounce(d time.Duration) {
+	w.convDebounce = d
+}
+
+// DefaultStatePath returns the default location of the watcher state file.
+func DefaultStatePath() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, StateFileName)
+}
+
+// SetStateFile enables persisting the last seen message ID to path. On Start,
+// messages that arrived after the persisted ID (i.e. while the watcher wasn't
+// running) are replayed through the message callbacks. The TUI uses
+// DefaultStatePath. Watchers limited with WatchChat read the file but don't
+// update it. Must be called before Start.
+func (w *MessageWatcher) SetStateFile(path string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.stateFile = path
+}
+
+// loadState reads the persisted last seen message ID.
+func (w *MessageWatcher) loadState() (int64, bool) {
+	if w.stateFile == "" {
+		return 0, false
+	}
+	data, err := os.ReadFile(w.stateFile)
+	if err != nil {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil || id < 0 {
+		return 0, false
+	}
+	return id, true
+}
+
+// saveState persists the last seen message ID, writing to a temp file first
+// so a crash never leaves a truncated state file behind. A watcher limited
+// to one chat (WatchChat) only sees that chat's messages, so it leaves the
+// file alone rather than skip past other chats' messages.
+func (w *MessageWatcher) saveState(id int64) {
+	if w.stateFile == "" || w.chatID != 0 {
+		return
+	}
+	tmp := w.stateFile + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strconv.FormatInt(id, 10)+"\n"), 0600); err != nil {
+		if w.logger != nil {
+			w.logger.Printf("cannot write watcher state: %v", err)
+		}
+		return
+	}
+	if err := os.Rename(tmp, w.stateFile); err != nil && w.logger != nil {
+		w.logger.Printf("cannot write watcher state: %v", err)
+	}
+}
+
+func (w *MessageWatcher) getLastMessageID() (int64, error) {
 	db, err := database.DB()
 	if err != nil {
-		return 0
+		return 0, err
 	}
 
 	var maxID sql.NullInt64
 	err = db.QueryRow("SELECT MAX(ROWID) FROM message").Scan(&maxID)
-	if err != nil || !maxID.Valid {
-		return 0
+	if err != nil {
+		return 0, err
 	}
-	return maxID.Int64
+	return maxID.Int64, nil
 }
 
 func (w *MessageWatcher) getDBMtime() int64 {
@@ -152,7 +318,11 @@ func (w *MessageWatcher) GetConversations(limit int) []Conversation {
 	if err != nil {
 		return nil
 	}
+	return toWatcherConversations(convs)
+}
 
+// toWatcherConversations converts database conversations to the watcher type.
+func toWatcherConversations(convs []database.Conversation) []Conversation {
 	var result []Conversation
 	for _, c := range convs {
 		result = append(result, Conversation{
@@ -169,116 +339,272 @@ func (w *MessageWatcher) GetConversations(limit int) []Conversation {
 	return result
 }
 
-// GetMessages returns messages for a specific chat.
+// GetMessages returns messages for a specific chat, always from the
+// database. The result is cached for CachedMessages and extended with the
+// chat's new messages as they arrive.
 func (w *MessageWatcher) GetMessages(chatID int64, limit int) []Message {
 	msgs, err := database.GetMessages(chatID, "", limit)
 	if err != nil {
 		return nil
 	}
 
+	result := toWatcherMessages(msgs)
+
+	w.cacheMu.Lock()
+	if w.cache == nil {
+		w.cache = make(map[int64]*cachedChat)
+	}
+	w.cache[chatID] = &cachedChat{msgs: append([]Message(nil), result...), limit: limit}
+	w.cacheMu.Unlock()
+
+	return result
+}
+
+// CachedMessages returns the messages of a chat loaded with GetMessages,
+// including those that arrived since, without querying the database. ok is
+// false if the chat hasn't been loaded. Edits, unsends and read receipts on
+// cached messages only show after the next GetMessages.
+func (w *MessageWatcher) CachedMessages(chatID int64) (msgs []Message, ok bool) {
+	w.cacheMu.Lock()
+	defer w.cacheMu.Unlock()
+	chat, ok := w.cache[chatID]
+	if !ok {
+		return nil, false
+	}
+	return append([]Message(nil), chat.msgs...), true
+}
+
+// appendToCache adds new messages to the chats they belong to, if cached,
+// dropping the oldest beyond each chat's limit. It returns the added
+// messages by chat, with their attachments loaded.
+func (w *MessageWatcher) appendToCache(newMessages []Message) map[int64][]Message {
+	w.cacheMu.Lock()
+	defer w.cacheMu.Unlock()
+
+	appended := make(map[int64][]Message)
+	var ids []int64
+	for _, m := range newMessages {
+		chat, ok := w.cache[m.ChatID]
+		if !ok {
+			continue
+		}
+		// GetMessages may already have loaded it
+		if n := len(chat.msgs); n > 0 && m.MessageID <= chat.msgs[n-1].MessageID {
+			continue
+		}
+		appended[m.ChatID] = append(appended[m.ChatID], m)
+		ids = append(ids, m.MessageID)
+	}
+	if len(appended) == 0 {
+		return nil
+	}
+
+	// New messages are fetched without attachments; load them for the few
+	// that are cached so they show like reloaded ones.
+	attachments, err := database.GetAttachmentsForMessages(ids)
+	if err != nil && w.logger != nil {
+		w.logger.Printf("cannot load attachments of new messages: %v", err)
+	}
+	for chatID, msgs := range appended {
+		for i := range msgs {
+			msgs[i].Attachments = toWatcherAttachments(attachments[msgs[i].MessageID])
+		}
+		chat := w.cache[chatID]
+		chat.msgs = append(chat.msgs, msgs...)
+		if chat.limit > 0 && len(chat.msgs) > chat.limit {
+			chat.msgs = append([]Message(nil), chat.msgs[len(chat.msgs)-chat.limit:]...)
+		}
+	}
+	return appended
+}
+
+// toWatcherMessages converts database messages to the watcher type.
+func toWatcherMessages(msgs []database.Message) []Message {
+	replies := database.ReplyTexts(msgs)
+
 	var result []Message
 	for _, m := range msgs {
 		msg := Message{
 			MessageID:      m.MessageID,
+			GUID:           m.GUID,
+			ReplyToGUID:    m.ReplyToGUID,
+			ReplyToText:    replies[m.ReplyToGUID],
 			Text:           m.Text,
 			Date:           m.Date,
 			IsFromMe:       m.IsFromMe,
 			IsRead:         m.IsRead,
+			IsDelivered:    m.IsDelivered,
+			DateRead:       m.DateRead,
+			IsEdited:       m.IsEdited,
+			IsRetracted:    m.IsRetracted,
+			Effect:         m.Effect,
 			Sender:         m.Sender,
 			ChatID:         m.ChatID,
 			ChatIdentifier: m.ChatIdent,
 			ChatName:       m.ChatName,
-		}
-		for _, a := range m.Attachments {
-			msg.Attachments = append(msg.Attachments, Attachment{
-				AttachmentID: a.AttachmentID,
-				Filename:     a.Filename,
-				FilePath:     a.FilePath,
-				MIMEType:     a.MIMEType,
-				UTI:          a.UTI,
-				TotalBytes:   a.TotalBytes,
-				IsImage:      a.IsImage,
-			})
+			Attachments:    toWatcherAttachments(m.Attachments),
 		}
 		result = append(result, msg)
 	}
 	return result
 }
 
-// GetNewMessages returns messages newer than the given ID.
+// toWatcherAttachments converts database attachments to the watcher type.
+func toWatcherAttachments(atts []database.Attachment) []Attachment {
+	var result []Attachment
+	for _, a := range atts {
+		result = append(result, Attachment{
+			AttachmentID: a.AttachmentID,
+			Filename:     a.Filename,
+			FilePath:     a.FilePath,
+			MIMEType:     a.MIMEType,
+			UTI:          a.UTI,
+			TotalBytes:   a.TotalBytes,
+			IsImage:      a.IsImage,
+		})
+	}
+	return result
+}
+
+// GetNewMessages returns messages newer than the given ID. Tapback reactions
+// and unsent messages are excluded, since they aren't new messages.
 func (w *MessageWatcher) GetNewMessages(sinceID int64) []Message {
-	db, err := database.DB()
+	messages, err := w.fetchNewMessages(sinceID, 0)
+	if err != nil {
+		w.notifyError(err)
+		return nil
+	}
+	return messages
+}
+
+// GetNewMessagesForChat returns messages in one chat newer than the given ID.
+// The chat is filtered in SQL, so other conversations aren't fetched.
+func (w *MessageWatcher) GetNewMessagesForChat(sinceID, chatID int64) []Message {
+	messages, err := w.fetchNewMessages(sinceID, chatID)
 	if err != nil {
 		w.notifyError(err)
 		return nil
 	}
+	return messages
+}
+
+// fetchNewMessages returns messages newer than the given ID, limited to one
+// chat unless chatID is 0. Reaction rows (associated_message_type != 0) and
+// retracted messages are skipped. Query failures are reported so the poll
+// loop can retry instead of skipping ahead.
+func (w *MessageWatcher) fetchNewMessages(sinceID, chatID int64) ([]Message, error) {
+	db, err := database.DB()
+	if err != nil {
+		return nil, err
+	}
 
-	query := `
+	// Columns missing from older macOS versions read as NULL.
+	query := fmt.Sprintf(`
 		SELECT 
 			m.ROWID as message_id,
+			m.guid,
+			%s,
 			m.text,
-			m.attributedBody,
+			%s,
 			m.date,
 			m.is_from_me,
 			m.is_read,
-			h.id as sender_id,
+			%s,
+			%s,
+			%s,
+			%s as sender_id,
 			c.ROWID as chat_id,
 			c.chat_identifier,
-			c.display_name
+			%s,
+			%s
 		FROM message m
 		LEFT JOIN chat_message_join cmj ON m.ROWID = cmj.message_id
 		LEFT JOIN chat c ON cmj.chat_id = c.ROWID
 		LEFT JOIN handle h ON m.handle_id = h.ROWID
 		WHERE m.ROWID > ?
-		ORDER BY m.date ASC
-	`
+			AND COALESCE(%s, 0) = 0
+			AND COALESCE(%s, 0) = 0
+	`,
+		database.Column("m", "message", "thread_originator_guid"),
+		database.Column("m", "message", "attributedBody"),
+		database.Column("m", "message", "date_edited"),
+		database.Column("m", "message", "expressive_send_style_id"),
+		database.Column("m", "message", "subject"),
+		database.SenderColumn(),
+		database.Column("c", "chat", "display_name"),
+		database.ParticipantsColumn(),
+		database.Column("m", "message", "associated_message_type"),
+		database.Column("m", "message", "date_retracted"))
+	args := []interface{}{sinceID}
+	if chatID != 0 {
+		query += " AND c.ROWID = ?"
+		args = append(args, chatID)
+	}
+	query += " ORDER BY m.date ASC"
 
-	rows, err := db.Query(query, sinceID)
+	rows, err := db.Query(query, args...)
 	if err != nil {
-		return nil
+		return nil, err
 	}
 	defer rows.Close()
 
 	var messages []Message
 	for rows.Next() {
 		var m Message
-		var text, senderID, chatIdent, chatName sql.NullString
+		var guid, replyTo, text, styleID, subject, senderID, chatIdent, chatName, participants sql.NullString
 		var attributedBody []byte
-		var date sql.NullInt64
+		var date, dateEdited sql.NullInt64
 		var isFromMe, isRead int
 
-		err := rows.Scan(&m.MessageID, &text, &attributedBody, &date, &isFromMe, &isRead, &senderID, &m.ChatID, &chatIdent, &chatName)
+		err := rows.Scan(&m.MessageID, &guid, &replyTo, &text, &attributedBody, &date, &isFromMe, &isRead, &dateEdited, &styleID, &subject, &senderID, &m.ChatID, &chatIdent, &chatName, &participants)
 		if err != nil {
 			continue
 		}
 
+		m.GUID = guid.String
+		m.ReplyToGUID = replyTo.String
 		m.IsFromMe = isFromMe == 1
 		m.IsRead = isRead == 1
 		m.ChatIdentifier = chatIdent.String
-		m.ChatName = chatName.String
+		m.ChatName = database.ChatName(chatName.String, m.ChatIdentifier, participants.String)
+		m.IsMuted = w.muted[m.ChatIdentifier]
 
 		if date.Valid {
 			m.Date = database.AppleTimeToTime(date.Int64)
 		}
 
-		m.Text = text.String
-		if m.Text == "" && len(attributedBody) > 0 {
-			m.Text = database.ExtractTextFromAttributedBody(attributedBody)
-		}
-		if m.Text == "" {
-			m.Text = "[Attachment]"
-		}
+		m.Text = database.MessageText(text.String, attributedBody, subject.String)
 
-		m.Sender = database.ResolveSender(m.IsFromMe, senderID.String)
+		m.IsEdited = dateEdited.Int64 > 0
+		m.Effect = database.EffectName(styleID.String)
 
-		if m.ChatName == "" {
-			m.ChatName = database.GetContactName(m.ChatIdentifier)
-		}
+		m.Sender = database.ResolveSender(m.IsFromMe, senderID.String)
 
 		messages = append(messages, m)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
-	return messages
+	// Resolve reply originators once the rows are closed; new batches
+	// are small, so individual lookups are cheap.
+	rows.Close()
+	texts := make(map[string]string, len(messages))
+	for _, m := range messages {
+		texts[m.GUID] = m.Text
+	}
+	for i := range messages {
+		guid := messages[i].ReplyToGUID
+		if guid == "" {
+			continue
+		}
+		if _, ok := texts[guid]; !ok {
+			texts[guid], _ = database.GetMessageTextByGUID(guid)
+		}
+		messages[i].ReplyToText = texts[guid]
+	}
+
+	return messages, nil
 }
 
 func (w *MessageWatcher) pollLoop() {
@@ -297,16 +623,97 @@ func (w *MessageWatcher) pollLoop() {
 	}
 }
 
+// initialize records the starting message ID and mtime. Normally the current
+// maximum ID is used; with a state file, the persisted ID is used instead so
+// messages missed while the watcher wasn't running are replayed.
+func (w *MessageWatcher) initialize() error {
+	currentMaxID, err := w.getLastMessageID()
+	if err != nil {
+		return err
+	}
+
+	startID := currentMaxID
+	if w.startAfterID > 0 {
+		startID = min(w.startAfterID, currentMaxID)
+	} else if savedID, ok := w.loadState(); ok && savedID < currentMaxID {
+		startID = savedID
+	}
+	w.lastMessageID.Store(startID)
+	w.lastMtime.Store(w.getDBMtime())
+	if startID == currentMaxID {
+		w.saveState(currentMaxID)
+	}
+
+	w.initialized = true
+	return nil
+}
+
+// pollFailed backs off exponentially and, once the failure has persisted for
+// ErrorThreshold polls, notifies the error callbacks.
+func (w *MessageWatcher) pollFailed(err error) {
+	w.failures++
+
+	backoff := w.pollInterval << uint(w.failures)
+	if backoff <= 0 || backoff > MaxRetryBackoff {
+		backoff = MaxRetryBackoff
+	}
+	w.retryAt = time.Now().Add(backoff)
+
+	if w.logger != nil {
+		w.logger.Printf("poll failed (attempt %d, retrying in %s): %v", w.failures, backoff, err)
+	}
+	if w.failures >= ErrorThreshold {
+		w.notifyError(fmt.Errorf("database unavailable after %d attempts: %w", w.failures, err))
+	}
+}
+
+// pollSucceeded clears any retry state after the database becomes available.
+func (w *MessageWatcher) pollSucceeded() {
+	if w.failures > 0 && w.logger != nil {
+		w.logger.Printf("database available again after %d failed polls", w.failures)
+	}
+	w.failures = 0
+	w.retryAt = time.Time{}
+}
+
 func (w *MessageWatcher) poll() {
+	if w.paused.Load() > 0 {
+		return
+	}
+	if !w.retryAt.IsZero() && time.Now().Before(w.retryAt) {
+		return
+	}
+
+	if !w.initialized {
+		if err := w.initialize(); err != nil {
+			w.pollFailed(err)
+			return
+		}
+	}
+
 	// Always check for new messages by comparing the max message ROWID.
 	// This is a cheap query and avoids relying solely on file mtime which
 	// can miss changes when SQLite WAL mode is in use.
-	currentMaxID := w.getLastMessageID()
+	currentMaxID, err := w.getLastMessageID()
+	if err != nil {
+		w.pollFailed(err)
+		return
+	}
 	lastID := w.lastMessageID.Load()
 
 	if currentMaxID > lastID {
-		newMessages := w.GetNewMessages(lastID)
+		newMessages, err := w.fetchNewMessages(lastID, w.chatID)
+		if err != nil {
+			// Keep lastID so these messages are fetched again on retry.
+			w.pollFailed(err)
+			return
+		}
 		w.lastMessageID.Store(currentMaxID)
+		w.saveState(currentMaxID)
+
+		for chatID, msgs := range w.appendToCache(newMessages) {
+			w.notifyAppended(chatID, msgs)
+		}
 
 		if len(newMessages) > 0 {
 			w.mu.RLock()
@@ -330,32 +737,116 @@ func (w *MessageWatcher) poll() {
 	}
 
 	// Use mtime (including WAL) to decide whether to refresh the heavier
-	// conversation list query.
+	// conversation list query. Refreshes are debounced so a burst of
+	// messages results in a single query once the interval has passed.
 	currentMtime := w.getDBMtime()
-	lastMtime := w.lastMtime.Load()
-
-	if currentMtime > lastMtime {
+	if currentMtime > w.lastMtime.Load() {
 		w.lastMtime.Store(currentMtime)
+		w.convPending = true
+	}
+
+	if w.convPending && time.Since(w.lastConvRefresh) >= w.convDebounce {
+		convs, err := database.GetConversations(DefaultConversationLimit)
+		if err != nil {
+			// Leave the refresh pending so it is retried.
+			w.pollFailed(err)
+			return
+		}
+		w.convPending = false
+		w.lastConvRefresh = time.Now()
+
+		// Only notify when the ordering, a last-message date or an unread
+		// count changed.
+		if key := conversationsKey(convs); key != w.lastConvKey {
+			w.lastConvKey = key
+			w.notifyConversations(toWatcherConversations(convs))
+		}
+	}
+
+	w.pollSucceeded()
+}
+
+// notifyAppended queues a batch for the append callbacks. Unlike other
+// callbacks they run one at a time, in the order of the batches, from a
+// single goroutine: a caller extending a list by each batch would otherwise
+// see them out of order and skip messages.
+func (w *MessageWatcher) notifyAppended(chatID int64, appended []Message) {
+	w.appendMu.Lock()
+	defer w.appendMu.Unlock()
+	w.appendQueue = append(w.appendQueue, appendBatch{chatID, appended})
+	if !w.appendRunning {
+		w.appendRunning = true
+		go w.deliverAppended()
+	}
+}
+
+// deliverAppended passes queued batches to the append callbacks until the
+// queue is empty.
+func (w *MessageWatcher) deliverAppended() {
+	for {
+		w.appendMu.Lock()
+		if len(w.appendQueue) == 0 {
+			w.appendRunning = false
+			w.appendMu.Unlock()
+			return
+		}
+		batch := w.appendQueue[0]
+		w.appendQueue = w.appendQueue[1:]
+		w.appendMu.Unlock()
 
-		conversations := w.GetConversations(DefaultConversationLimit)
 		w.mu.RLock()
-		callbacks := make([]ConversationCallback, len(w.conversationCallbacks))
-		copy(callbacks, w.conversationCallbacks)
+		callbacks := make([]AppendCallback, len(w.appendCallbacks))
+		copy(callbacks, w.appendCallbacks)
 		w.mu.RUnlock()
 
 		for _, cb := range callbacks {
-			go func(callback ConversationCallback, convs []Conversation) {
+			func() {
 				defer func() {
 					if r := recover(); r != nil {
 						if w.logger != nil {
-							w.logger.Printf("panic in conversation callback: %v", r)
+							w.logger.Printf("panic in append callback: %v", r)
 						}
 					}
 				}()
-				callback(convs)
-			}(cb, conversations)
+				cb(batch.chatID, batch.appended)
+			}()
+		}
+	}
+}
+
+// notifyConversations invokes the conversation callbacks in goroutines.
+func (w *MessageWatcher) notifyConversations(conversations []Conversation) {
+	w.mu.RLock()
+	callbacks := make([]ConversationCallback, len(w.conversationCallbacks))
+	copy(callbacks, w.conversationCallbacks)
+	w.mu.RUnlock()
+
+	for _, cb := range callbacks {
+		go func(callback ConversationCallback, convs []Conversation) {
+			defer func() {
+				if r := recover(); r != nil {
+					if w.logger != nil {
+						w.logger.Printf("panic in conversation callback: %v", r)
+					}
+				}
+			}()
+			callback(convs)
+		}(cb, conversations)
+	}
+}
+
+// conversationsKey summarizes the conversation order, last-message dates and
+// unread counts so unchanged lists can be detected.
+func conversationsKey(convs []database.Conversation) string {
+	var sb strings.Builder
+	for _, c := range convs {
+		var date int64
+		if c.LastMessageDate != nil {
+			date = c.LastMessageDate.UnixNano()
 		}
+		fmt.Fprintf(&sb, "%d:%d:%d,", c.ChatID, date, c.UnreadCount)
 	}
+	return sb.String()
 }
 
 func (w *MessageWatcher) notifyError(err error) {
@@ -366,6 +857,30 @@ func (w *MessageWatcher) notifyError(err error) {
 	}
 }
 
+// Pause stops callbacks from firing until Resume is called; the poll
+// goroutine keeps running but skips its checks. Nothing is lost: messages
+// and conversation changes that arrive while paused are delivered by the
+// first poll after Resume. Callbacks already dispatched still run. Pauses
+// nest, so each Pause needs its own Resume. Safe to call from callbacks.
+func (w *MessageWatcher) Pause() {
+	w.paused.Add(1)
+}
+
+// Resume undoes one Pause. Extra calls are ignored.
+func (w *MessageWatcher) Resume() {
+	for {
+		n := w.paused.Load()
+		if n <= 0 || w.paused.CompareAndSwap(n, n-1) {
+			return
+		}
+	}
+}
+
+// Paused reports whether callbacks are paused.
+func (w *MessageWatcher) Paused() bool {
+	return w.paused.Load() > 0
+}
+
 // Start begins watching for new messages.
 func (w *MessageWatcher) Start() {
 	w.mu.Lock()
@@ -382,15 +897,22 @@ func (w *MessageWatcher) Start() {
 	// Start poll loop in a goroutine; perform initial DB checks there to avoid blocking caller
 	w.wg.Add(1)
 	go func() {
-		// Initialize last IDs / mtime inside goroutine using atomic operations
-		w.lastMessageID.Store(w.getLastMessageID())
-		w.lastMtime.Store(w.getDBMtime())
+		// Initialize last IDs / mtime inside goroutine using atomic operations.
+		// If the database isn't available yet, poll keeps retrying.
+		if err := w.initialize(); err != nil {
+			w.pollFailed(err)
+		} else {
+			// Deliver any replayed messages right away.
+			w.poll()
+		}
 
 		w.pollLoop()
 	}()
 }
 
-// Stop stops watching for messages.
+// Stop stops watching for messages. It is safe to call more than once. If a
+// poll is stuck mid-query, Stop gives up waiting after StopTimeout and lets
+// the poll goroutine exit on its own.
 func (w *MessageWatcher) Stop() {
 	w.mu.Lock()
 	if !w.running {
@@ -401,5 +923,17 @@ func (w *MessageWatcher) Stop() {
 	close(w.stopCh)
 	w.mu.Unlock()
 
-	w.wg.Wait()
+	done := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(StopTimeout):
+		if w.logger != nil {
+			w.logger.Printf("stop: poll loop did not exit within %s", StopTimeout)
+		}
+	}
 }
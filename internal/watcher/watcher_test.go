@@ -0,0 +1,59 @@
+package watcher
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAppendCallbacksRunInOrder(t *testing.T) {
+	w := NewMessageWatcher(time.Second)
+
+	var mu sync.Mutex
+	var got []int64
+	var wg sync.WaitGroup
+	w.OnMessagesAppended(func(chatID int64, appended []Message) {
+		if appended[0].MessageID == 1 {
+			// A slow first batch must not be overtaken.
+			time.Sleep(20 * time.Millisecond)
+		}
+		mu.Lock()
+		got = append(got, appended[0].MessageID)
+		mu.Unlock()
+		wg.Done()
+	})
+
+	wg.Add(3)
+	for id := int64(1); id <= 3; id++ {
+		w.notifyAppended(1, []Message{{MessageID: id}})
+	}
+	wg.Wait()
+
+	if want := []int64{1, 2, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("batches delivered as %v, want %v", got, want)
+	}
+}
+
+func TestAppendCallbackPanicKeepsDelivering(t *testing.T) {
+	w := NewMessageWatcher(time.Second)
+
+	done := make(chan int64, 2)
+	w.OnMessagesAppended(func(chatID int64, appended []Message) {
+		if appended[0].MessageID == 1 {
+			panic("boom")
+		}
+		done <- appended[0].MessageID
+	})
+
+	w.notifyAppended(1, []Message{{MessageID: 1}})
+	w.notifyAppended(1, []Message{{MessageID: 2}})
+	select {
+	case id := <-done:
+		if id != 2 {
+			t.Errorf("got batch %d, want 2", id)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("second batch not delivered after a panic")
+	}
+}